@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"player-native/internal/api"
+	"player-native/internal/command"
+	"player-native/internal/media"
+	"player-native/internal/system"
+	"player-native/internal/template"
+)
+
+var errNoSession = errors.New("player is between sessions, try again")
+
+// registerCommands installs the handlers for server commands. Commands
+// act on whichever session is playing when they run. reboot and restart
+// end the process once their result is saved; restart stops the player
+// and relies on the service restarting a non-zero exit.
+func registerCommands(d *command.Dispatcher, client *api.Client, opts runOptions, sh *shared) {
+	d.HandleFinal("reboot", func(ctx context.Context, cmd api.Command) (string, error) {
+		return "rebooting", nil
+	}, func() {
+		if out, err := exec.Command("systemctl", "reboot").CombinedOutput(); err != nil {
+			log.Printf("[command] reboot: %v: %s", err, bytes.TrimSpace(out))
+		}
+	})
+
+	d.HandleFinal("restart", func(ctx context.Context, cmd api.Command) (string, error) {
+		return "restarting", nil
+	}, func() {
+		sh.requestRestart()
+	})
+
+	d.Handle("reload_config", func(ctx context.Context, cmd api.Command) (string, error) {
+		if err := client.ReloadConfig(); err != nil {
+			return "", err
+		}
+		return "config reloaded", nil
+	})
+
+	// switch_template {"template": "<file or built-in>"} replaces
+	// --template until the player restarts.
+	d.Handle("switch_template", func(ctx context.Context, cmd api.Command) (string, error) {
+		var args struct {
+			Template string `json:"template"`
+		}
+		if err := decodeArgs(cmd, &args); err != nil {
+			return "", err
+		}
+		if args.Template == "" {
+			return "", fmt.Errorf("template is required")
+		}
+		next := opts
+		next.templatePath = args.Template
+		tmpl, err := loadTemplate(next)
+		if err != nil {
+			return "", err
+		}
+		s := sh.session()
+		if s == nil {
+			return "", errNoSession
+		}
+
+		sh.mu.Lock()
+		prev := sh.tmplPath
+		sh.tmplPath = args.Template
+		sh.mu.Unlock()
+		if err := s.requestReload(); err != nil {
+			sh.mu.Lock()
+			sh.tmplPath = prev
+			sh.mu.Unlock()
+			return "", err
+		}
+		s.watch(args.Template)
+		return fmt.Sprintf("switching to template %q", tmpl.Name), nil
+	})
+
+	d.Handle("resync", func(ctx context.Context, cmd api.Command) (string, error) {
+		s := sh.session()
+		if s == nil {
+			return "", errNoSession
+		}
+		if s.syncer == nil {
+			return "", fmt.Errorf("content sync is not running")
+		}
+		s.syncer.Refresh()
+		return "sync started", nil
+	})
+
+	d.Handle("screenshot", func(ctx context.Context, cmd api.Command) (string, error) {
+		var buf bytes.Buffer
+		if err := system.Screenshot(&buf); err != nil {
+			return "", fmt.Errorf("screenshot: %w", err)
+		}
+		if err := client.UploadScreenshot(ctx, cmd.ID, buf.Bytes()); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("uploaded %d bytes", buf.Len()), nil
+	})
+
+	// set_volume {"zone": "<id>", "volume": 0-200}; without a zone, every
+	// zone that supports it.
+	d.Handle("set_volume", func(ctx context.Context, cmd api.Command) (string, error) {
+		var args struct {
+			Zone   string `json:"zone"`
+			Volume *int   `json:"volume"`
+		}
+		if err := decodeArgs(cmd, &args); err != nil {
+			return "", err
+		}
+		if args.Volume == nil {
+			return "", fmt.Errorf("volume is required")
+		}
+		s := sh.session()
+		if s == nil {
+			return "", errNoSession
+		}
+		if args.Zone != "" {
+			if err := s.engine.SetVolume(args.Zone, *args.Volume); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("zone %s volume %d%%", args.Zone, *args.Volume), nil
+		}
+		var set []string
+		for _, id := range s.engine.Zones() {
+			if err := s.engine.SetVolume(id, *args.Volume); err != nil {
+				log.Printf("[command] set_volume zone %s: %v", id, err)
+				continue
+			}
+			set = append(set, id)
+		}
+		if len(set) == 0 {
+			return "", fmt.Errorf("no zone supports volume control")
+		}
+		return fmt.Sprintf("volume %d%% on zone(s) %s", *args.Volume, strings.Join(set, ", ")), nil
+	})
+
+	// purge_zone {"zone": "<id>"} deletes the media in the zone's
+	// playlist and schedule window directories, then re-syncs.
+	// Subdirectories are purged only for a recursive zone, and never
+	// when they belong to another zone.
+	d.Handle("purge_zone", func(ctx context.Context, cmd api.Command) (string, error) {
+		var args struct {
+			Zone string `json:"zone"`
+		}
+		if err := decodeArgs(cmd, &args); err != nil {
+			return "", err
+		}
+		s := sh.session()
+		if s == nil {
+			return "", errNoSession
+		}
+
+		var zones []template.Zone
+		recursive := opts.recursive
+		for _, z := range s.engine.Status() {
+			zones = append(zones, z.Zone)
+			if z.ID == args.Zone {
+				recursive = recursive || z.Zone.Recursive
+			}
+		}
+		dirs, keep := zoneDirs(zones, args.Zone)
+		if len(dirs) == 0 {
+			return "", fmt.Errorf("unknown zone %q", args.Zone)
+		}
+
+		removed := 0
+		for _, dir := range dirs {
+			n, err := purgeMedia(dir, recursive, keep)
+			removed += n
+			if err != nil {
+				return fmt.Sprintf("removed %d file(s)", removed), err
+			}
+		}
+		if s.syncer != nil {
+			s.syncer.Refresh()
+		}
+		return fmt.Sprintf("removed %d file(s)", removed), nil
+	})
+}
+
+// decodeArgs unmarshals a command's arguments into v. Commands without
+// arguments leave v as it is.
+func decodeArgs(cmd api.Command, v any) error {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(cmd.Args, v); err != nil {
+		return fmt.Errorf("bad args: %w", err)
+	}
+	return nil
+}
+
+// zoneDirs returns the playlist directories of zone id (its own and its
+// schedule windows') and those of every other zone and window, which a
+// purge must leave alone even when they lie below the zone's own.
+func zoneDirs(zones []template.Zone, id string) (own []string, others map[string]bool) {
+	others = make(map[string]bool)
+	for _, z := range zones {
+		dirs := []string{z.PlaylistDir}
+		if z.Schedule != nil {
+			for _, win := range z.Schedule.Windows {
+				dirs = append(dirs, win.PlaylistDir)
+			}
+		}
+		for _, dir := range dirs {
+			if z.ID == id {
+				own = append(own, filepath.Clean(dir))
+			} else {
+				others[filepath.Clean(dir)] = true
+			}
+		}
+	}
+	for _, dir := range own {
+		delete(others, dir)
+	}
+	return own, others
+}
+
+// purgeMedia deletes the supported media files in dir, and in its
+// subdirectories if recursive, leaving hidden files and directories
+// (such as the sync staging area) and the directories in keep alone.
+func purgeMedia(dir string, recursive bool, keep map[string]bool) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if e.IsDir() {
+			if !recursive || strings.HasPrefix(e.Name(), ".") || keep[filepath.Clean(path)] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(e.Name(), ".") && e.Type().IsRegular() && media.IsSupported(path) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"player-native/internal/schedule"
+	"player-native/internal/template"
+)
+
+// TestPurgeZone: purging the fullscreen-style zone at the playlist root
+// leaves nested zones, schedule windows, hidden and non-media files
+// alone, and descends into subfolders only for a recursive zone.
+func TestPurgeZone(t *testing.T) {
+	for _, recursive := range []bool{false, true} {
+		root := t.TempDir()
+		files := []string{
+			"a.mp4", "notes.txt", ".hidden.mp4",
+			".staging/part.mp4",
+			"sub/b.jpg",
+			"footer/c.mp4",
+			"footer/evening/d.mp4",
+			"breakfast/e.png",
+		}
+		for _, f := range files {
+			path := filepath.Join(root, f)
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		zones := []template.Zone{
+			{ID: "main", PlaylistDir: root, Schedule: &schedule.Schedule{Windows: []schedule.Window{
+				{Name: "breakfast", PlaylistDir: filepath.Join(root, "breakfast")},
+			}}},
+			{ID: "footer", PlaylistDir: filepath.Join(root, "footer"), Schedule: &schedule.Schedule{Windows: []schedule.Window{
+				{Name: "evening", PlaylistDir: filepath.Join(root, "footer", "evening")},
+			}}},
+		}
+		dirs, keep := zoneDirs(zones, "main")
+		removed := 0
+		for _, dir := range dirs {
+			n, err := purgeMedia(dir, recursive, keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			removed += n
+		}
+
+		var left []string
+		filepath.WalkDir(root, func(path string, e os.DirEntry, err error) error {
+			if err == nil && !e.IsDir() {
+				rel, _ := filepath.Rel(root, path)
+				left = append(left, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		slices.Sort(left)
+
+		want := []string{".hidden.mp4", ".staging/part.mp4", "footer/c.mp4", "footer/evening/d.mp4", "notes.txt", "sub/b.jpg"}
+		wantRemoved := 2 // a.mp4 and the breakfast window's e.png
+		if recursive {
+			want = []string{".hidden.mp4", ".staging/part.mp4", "footer/c.mp4", "footer/evening/d.mp4", "notes.txt"}
+			wantRemoved = 3
+		}
+		if removed != wantRemoved || !slices.Equal(left, want) {
+			t.Errorf("recursive=%v: removed %d, left %v; want %d, %v", recursive, removed, left, wantRemoved, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"player-native/internal/playlist"
+	"player-native/internal/vlc"
+)
+
+// zoneFeed owns the playlist watcher for a single zone and pushes its
+// items into the engine. The watched directory can be swapped at
+// runtime, which is how dayparting schedules change a zone's content.
+type zoneFeed struct {
+	mu      sync.Mutex
+	zoneID  string
+	dir     string
+	watcher *playlist.Watcher
+	engine  *vlc.Engine
+	opts    []playlist.Option
+}
+
+// newZoneFeed starts watching dir and loads its current items into
+// the zone. opts apply to every watcher the feed creates.
+func newZoneFeed(engine *vlc.Engine, zoneID, dir string, opts ...playlist.Option) (*zoneFeed, error) {
+	f := &zoneFeed{zoneID: zoneID, engine: engine, opts: opts}
+	if err := f.SetDir(dir); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetDir retargets the feed to a new directory. The new watcher is
+// running before the old one is stopped, so no change is missed.
+func (f *zoneFeed) SetDir(dir string) error {
+	var w *playlist.Watcher
+	w, err := playlist.NewWatcher(dir, func(files []string) {
+		f.mu.Lock()
+		current := f.watcher == w
+		f.mu.Unlock()
+		if !current {
+			return // a late event from a watcher that has been replaced
+		}
+		log.Printf("[main] zone %q playlist changed: %d files", f.zoneID, len(files))
+		f.engine.SetItems(f.zoneID, w.Items())
+	}, f.opts...)
+	if err != nil {
+		return fmt.Errorf("watcher init for zone %s: %w", f.zoneID, err)
+	}
+
+	go func() {
+		if err := w.Start(); err != nil {
+			log.Printf("[main] watcher error for zone %s: %v", f.zoneID, err)
+		}
+	}()
+
+	f.mu.Lock()
+	old := f.watcher
+	f.watcher = w
+	f.dir = dir
+	f.mu.Unlock()
+
+	if old != nil {
+		old.Stop()
+	}
+
+	f.engine.SetItems(f.zoneID, w.Items())
+	return nil
+}
+
+// Dir returns the directory currently feeding the zone.
+func (f *zoneFeed) Dir() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dir
+}
+
+// Stop releases the active watcher.
+func (f *zoneFeed) Stop() {
+	f.mu.Lock()
+	w := f.watcher
+	f.watcher = nil
+	f.mu.Unlock()
+
+	if w != nil {
+		w.Stop()
+	}
+}
@@ -3,18 +3,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
 	"player-native/internal/api"
-	"player-native/internal/playlist"
+	"player-native/internal/command"
+	"player-native/internal/control"
+	"player-native/internal/proofofplay"
 	"player-native/internal/system"
-	"player-native/internal/template"
 	"player-native/internal/vlc"
 
 	"github.com/spf13/cobra"
@@ -35,6 +39,7 @@ func main() {
 	rootCmd.AddCommand(runCmd())
 	rootCmd.AddCommand(versionCmd())
 	rootCmd.AddCommand(checkCmd())
+	rootCmd.AddCommand(templateCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -42,15 +47,11 @@ func main() {
 }
 
 // runCmd is the primary command that starts the playback engine,
-// folder watchers (one per zone), and heartbeat client.
+// folder watchers (one per zone), dayparting scheduler, heartbeat
+// client, real-time channel, server commands, content sync,
+// proof-of-play logging, and optional control API.
 func runCmd() *cobra.Command {
-	var (
-		playlistDir  string
-		configPath   string
-		templatePath string
-		screenW      int
-		screenH      int
-	)
+	var opts runOptions
 
 	cmd := &cobra.Command{
 		Use:   "run",
@@ -59,107 +60,159 @@ func runCmd() *cobra.Command {
 			log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 			log.Printf("n-compasstv %s (built %s)", version, buildTime)
 
-			// --- Load Template ---
-			var tmpl *template.Template
-			if templatePath != "" {
-				var err error
-				tmpl, err = template.LoadFromFile(templatePath)
-				if err != nil {
-					return fmt.Errorf("template load: %w", err)
-				}
-				log.Printf("[main] loaded template %q with %d zone(s)", tmpl.Name, len(tmpl.Zones))
-			} else {
-				tmpl = template.Fullscreen(playlistDir)
-				log.Printf("[main] using default fullscreen template")
+			if err := vlc.CheckBackend(opts.backend); err != nil {
+				return err
 			}
 
-			// Ensure all playlist directories exist.
-			for i := range tmpl.Zones {
-				z := &tmpl.Zones[i]
-				if templatePath == "" {
-					z.PlaylistDir = playlistDir
-				}
-				if err := system.EnsureDir(z.PlaylistDir); err != nil {
-					return fmt.Errorf("playlist dir %s: %w", z.PlaylistDir, err)
-				}
-			}
+			// --- Display ---
+			display := resolveDisplay(cmd, &opts)
 
-			// --- Engine (manages all zones) ---
-			engine, err := vlc.NewEngine(tmpl, screenW, screenH)
+			// --- Load Template ---
+			tmpl, err := loadTemplate(opts)
 			if err != nil {
-				return fmt.Errorf("engine init: %w", err)
-			}
-			defer engine.Release()
-
-			// --- Per-Zone Playlist Watchers ---
-			var watchers []*playlist.Watcher
-			for _, z := range tmpl.Zones {
-				zoneID := z.ID
-				dir := z.PlaylistDir
-
-				w, err := playlist.NewWatcher(dir, func(files []string) {
-					log.Printf("[main] zone %q playlist changed: %d files", zoneID, len(files))
-					engine.SetPlaylist(zoneID, files)
-				})
-				if err != nil {
-					return fmt.Errorf("watcher init for zone %s: %w", zoneID, err)
-				}
-
-				engine.SetPlaylist(zoneID, w.Files())
-
-				go func() {
-					if err := w.Start(); err != nil {
-						log.Printf("[main] watcher error for zone %s: %v", zoneID, err)
-					}
-				}()
-				watchers = append(watchers, w)
+				return err
 			}
-			defer func() {
-				for _, w := range watchers {
-					w.Stop()
-				}
-			}()
 
 			// --- API Client (heartbeats) ---
-			apiClient, err := api.NewClient(configPath, version)
+			var apiOpts []api.Option
+			if opts.requireHTTPS {
+				apiOpts = append(apiOpts, api.WithRequireHTTPS())
+			}
+			apiClient, err := api.NewClient(opts.configPath, version, apiOpts...)
 			if err != nil {
 				log.Printf("[main] api client warning: %v", err)
 			}
+			sh := &shared{api: apiClient, report: &reporter{}, restartCh: make(chan struct{}, 1)}
 			if apiClient != nil {
+				apiClient.SetDisplay(display)
+				apiClient.SetStateFunc(sh.report.state)
 				go apiClient.StartHeartbeat()
 				defer apiClient.Stop()
 			}
 
-			// --- Graceful Shutdown ---
+			// --- Proof-of-Play Log (survives template reloads) ---
+			popLog, err := proofofplay.OpenLog(opts.popDir)
+			if err != nil {
+				log.Printf("[main] proof-of-play disabled: %v", err)
+			} else {
+				defer popLog.Close()
+				sh.popLog = popLog
+				if apiClient != nil {
+					uploader := proofofplay.NewUploader(popLog, apiClient)
+					interval := time.Duration(apiClient.GetConfig().PopInterval) * time.Second
+					go uploader.Start(interval)
+					defer uploader.Stop()
+				}
+			}
+
+			// --- Graceful Shutdown (SIGHUP reloads the template) ---
 			sigCh := make(chan os.Signal, 1)
-			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-			errCh := engine.Play()
+			// --- Server Commands (delivered in heartbeat responses) ---
+			if apiClient != nil {
+				statePath := filepath.Join(filepath.Dir(opts.configPath), "commands.json")
+				dispatcher := command.New(statePath, func() []string { return apiClient.GetConfig().AllowedCommands })
+				registerCommands(dispatcher, apiClient, opts, sh)
+				apiClient.SetCommander(dispatcher)
+				go dispatcher.Start()
+				defer dispatcher.Stop()
+
+				// --- Real-time Channel (commands and content pushes) ---
+				apiClient.OnContentChange(func() {
+					if s := sh.session(); s != nil && s.syncer != nil {
+						s.syncer.Trigger()
+					}
+				})
+				go apiClient.StartChannel()
+			}
+
+			// --- Provisioning (unregistered players show a pairing code) ---
+			if apiClient != nil && !apiClient.Registered() {
+				endpoint := apiClient.GetConfig().Endpoint
+				if endpoint == "" {
+					endpoint = opts.registerURL
+				}
+				if endpoint != "" {
+					ctx, cancel := context.WithCancel(context.Background())
+					defer cancel()
+					reload := func() {
+						select {
+						case sigCh <- syscall.SIGHUP:
+						case <-ctx.Done():
+						}
+					}
+					sh.pair, err = startPairing(ctx, apiClient, endpoint, opts, tmpl, reload)
+					if err != nil {
+						log.Printf("[main] provisioning disabled: %v", err)
+					}
+					tmpl = sh.pair.apply(tmpl)
+				}
+			}
 
-			select {
-			case sig := <-sigCh:
-				log.Printf("[main] received signal: %v — shutting down", sig)
-				engine.Stop()
-			case err := <-errCh:
+			// A session applies template reloads in place; a new one
+			// starts only if that fails.
+			for tmpl != nil {
+				sess, err := newSession(opts, tmpl, sh)
 				if err != nil {
-					log.Printf("[main] zone error: %v", err)
+					return err
 				}
+				tmpl = sess.run(sigCh)
+				sess.close()
 			}
 
 			log.Println("[main] shutdown complete")
+			if sh.restart.Load() {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("restart requested by server")
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVarP(&playlistDir, "playlist", "p", defaultPlaylistDir(), "Path to the media playlist directory")
-	cmd.Flags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "Path to config.json identity file")
-	cmd.Flags().StringVarP(&templatePath, "template", "t", "", "Path to a template JSON file (default: fullscreen)")
-	cmd.Flags().IntVar(&screenW, "screen-width", 1920, "Screen width in pixels (for zone positioning)")
-	cmd.Flags().IntVar(&screenH, "screen-height", 1080, "Screen height in pixels (for zone positioning)")
+	cmd.Flags().StringVarP(&opts.playlistDir, "playlist", "p", defaultPlaylistDir(), "Path to the media playlist directory")
+	cmd.Flags().StringVarP(&opts.configPath, "config", "c", defaultConfigPath(), "Path to config.json identity file")
+	cmd.Flags().StringVarP(&opts.templatePath, "template", "t", "", "Template JSON file or built-in name (default: fullscreen)")
+	cmd.Flags().IntVar(&opts.screenW, "screen-width", 1920, "Screen width in pixels (default: detected, else 1920)")
+	cmd.Flags().IntVar(&opts.screenH, "screen-height", 1080, "Screen height in pixels (default: detected, else 1080)")
+	cmd.Flags().BoolVar(&opts.control, "control", false, "Enable the local HTTP control API")
+	cmd.Flags().StringVar(&opts.controlAddr, "control-addr", control.DefaultAddr, "Listen address for the control API")
+	cmd.Flags().StringVar(&opts.backend, "backend", vlc.DefaultBackend, "Playback backend: "+strings.Join(vlc.BackendNames(), ", ")+" (zones may override)")
+	cmd.Flags().BoolVar(&opts.watchTmpl, "watch-template", true, "Reload the template when its file changes")
+	cmd.Flags().BoolVar(&opts.recursive, "recursive", false, "Include subfolders of every playlist directory")
+	cmd.Flags().StringVar(&opts.stagingDir, "staging-dir", filepath.Join(defaultPlaylistDir(), ".staging"), "Download staging directory for content sync")
+	cmd.Flags().StringVar(&opts.registerURL, "register-endpoint", "", "Server to register with when config.json has no identity")
+	cmd.Flags().BoolVar(&opts.requireHTTPS, "require-https", false, "Refuse to contact a server endpoint that is not https")
+	cmd.Flags().StringVar(&opts.popDir, "pop-dir", defaultPopDir(), "Directory for the proof-of-play log")
 
 	return cmd
 }
 
+// resolveDisplay settles the screen size zones are laid out on. Explicit
+// --screen-width/--screen-height flags win; otherwise the connected
+// display is detected, and the flag defaults are the last resort.
+func resolveDisplay(cmd *cobra.Command, opts *runOptions) system.Display {
+	flags := system.Display{Width: opts.screenW, Height: opts.screenH, Source: system.DisplayFlags}
+	detected, err := system.DetectDisplay()
+
+	switch {
+	case cmd.Flags().Changed("screen-width") || cmd.Flags().Changed("screen-height"):
+		if err == nil && detected.Width == flags.Width && detected.Height == flags.Height {
+			flags.Connector, flags.RefreshHz = detected.Connector, detected.RefreshHz
+		} else if err == nil {
+			log.Printf("[main] screen size %dx%d from flags overrides detected %s", flags.Width, flags.Height, detected)
+		}
+		return flags
+	case err != nil:
+		log.Printf("[main] display detection failed: %v (using %dx%d)", err, flags.Width, flags.Height)
+		return flags
+	}
+
+	opts.screenW, opts.screenH = detected.Width, detected.Height
+	log.Printf("[main] display: %s", detected)
+	return detected
+}
+
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
@@ -181,6 +234,11 @@ func checkCmd() *cobra.Command {
 			fmt.Printf("Disk Usage      : %.1f%%\n", status.DiskUsedPct)
 			fmt.Printf("Disk Free       : %d MB\n", status.DiskFreeBytes/1024/1024)
 			fmt.Printf("Throttled       : %v\n", status.Throttled)
+			if status.Display != nil {
+				fmt.Printf("Display         : %s\n", status.Display)
+			} else {
+				fmt.Printf("Display         : not detected\n")
+			}
 		},
 	}
 }
@@ -200,3 +258,11 @@ func defaultConfigPath() string {
 	}
 	return "/etc/n-compasstv/config.json"
 }
+
+func defaultPopDir() string {
+	if runtime.GOOS == "windows" {
+		exe, _ := os.Executable()
+		return filepath.Join(filepath.Dir(exe), "proof-of-play")
+	}
+	return "/var/log/n-compasstv/proof-of-play"
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"player-native/internal/api"
+	"player-native/internal/system"
+	"player-native/internal/template"
+)
+
+// pairingDirName is the playlist subfolder the pairing screen is written
+// to. Dot-folders are ignored by the playlist watcher, so it never shows
+// up in a zone's own playlist.
+const pairingDirName = ".pairing"
+
+// pairing shows the pairing code full-screen in the template's main
+// zone while the player waits for an admin to claim it.
+type pairing struct {
+	dir string
+
+	mu     sync.Mutex
+	active bool
+}
+
+// startPairing registers the device at endpoint and keeps the current
+// pairing code on screen until it is claimed (or provisioning fails);
+// reload is then called to put the template's own playlist back.
+// Templates must go through apply while pairing is active.
+func startPairing(ctx context.Context, client *api.Client, endpoint string, opts runOptions, tmpl *template.Template, reload func()) (*pairing, error) {
+	hwid, err := system.HardwareID()
+	if err != nil {
+		return nil, fmt.Errorf("hardware id: %w", err)
+	}
+
+	p := &pairing{dir: filepath.Join(opts.playlistDir, pairingDirName), active: true}
+	os.RemoveAll(p.dir)
+	if err := system.EnsureDir(p.dir); err != nil {
+		return nil, err
+	}
+
+	sw, sh := tmpl.Screen(opts.screenW, opts.screenH)
+	r := tmpl.Zones[pairingZone(tmpl)].Rect(sw, sh)
+	w, h := max(r.W, 320), max(r.H, 180)
+	if err := writePairingScreen(p.dir, "", hwid, w, h); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := client.Provision(ctx, endpoint, hwid, func(code string) {
+			if err := writePairingScreen(p.dir, code, hwid, w, h); err != nil {
+				log.Printf("[main] pairing screen: %v", err)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[main] provisioning failed: %v", err)
+		}
+		p.mu.Lock()
+		p.active = false
+		p.mu.Unlock()
+		reload()
+	}()
+	return p, nil
+}
+
+// apply points the main zone of tmpl at the pairing screen while
+// pairing is in progress. It returns tmpl itself otherwise, and is safe
+// to call on a nil pairing.
+func (p *pairing) apply(tmpl *template.Template) *template.Template {
+	if p == nil {
+		return tmpl
+	}
+	p.mu.Lock()
+	active := p.active
+	p.mu.Unlock()
+	if !active {
+		return tmpl
+	}
+
+	out := *tmpl
+	out.Zones = append([]template.Zone(nil), tmpl.Zones...)
+	z := &out.Zones[pairingZone(tmpl)]
+	z.PlaylistDir = p.dir
+	z.Recursive = false
+	z.Schedule = nil
+	return &out
+}
+
+// pairingZone returns the index of the zone that shows the pairing code:
+// the one with ID "main", or else the largest.
+func pairingZone(tmpl *template.Template) int {
+	best, area := 0, -1
+	for i, z := range tmpl.Zones {
+		if z.ID == "main" {
+			return i
+		}
+		r := z.Rect(template.RefWidth, template.RefHeight)
+		if r.W*r.H > area {
+			best, area = i, r.W*r.H
+		}
+	}
+	return best
+}
+
+// writePairingScreen renders the pairing code (or a "connecting" notice
+// while there is none) and the hardware ID as a w x h PNG in dir. Each
+// code gets its own file name so the zone's playlist changes with it.
+func writePairingScreen(dir, code, hwid string, w, h int) error {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0x10, 0x18, 0x20, 0xff}}, image.Point{}, draw.Src)
+
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	if code == "" {
+		drawText(img, "CONNECTING", w/2, h/2, w*8/10, h/6, white)
+	} else {
+		drawText(img, "PAIR THIS SCREEN", w/2, h/5, w*7/10, h/10, white)
+		drawText(img, code, w/2, h/2, w*9/10, h/3, color.RGBA{0xff, 0xc8, 0x2e, 0xff})
+	}
+	drawText(img, hwid, w/2, h*7/8, w*6/10, h/24, color.RGBA{0x90, 0x98, 0xa0, 0xff})
+
+	name := "pairing.png"
+	if code != "" {
+		name = "pairing-" + strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+				return r
+			}
+			return -1
+		}, code) + ".png"
+	}
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return err
+	}
+
+	old, _ := filepath.Glob(filepath.Join(dir, "pairing*.png"))
+	for _, o := range old {
+		if filepath.Base(o) != name {
+			os.Remove(o)
+		}
+	}
+	return nil
+}
+
+// drawText draws s in the built-in block font, centred on (cx, cy) and
+// scaled to the largest whole size that fits maxW x maxH.
+func drawText(img *image.RGBA, s string, cx, cy, maxW, maxH int, c color.Color) {
+	runes := []rune(strings.ToUpper(s))
+	n := len(runes)
+	if n == 0 {
+		return
+	}
+	scale := max(1, min(maxW/(6*n-1), maxH/7))
+	x0 := cx - (6*n-1)*scale/2
+	y0 := cy - 7*scale/2
+
+	src := &image.Uniform{c}
+	for i, ch := range runes {
+		g, ok := glyphs[ch]
+		if !ok {
+			continue
+		}
+		for row, bits := range g {
+			for col, b := range bits {
+				if b != '#' {
+					continue
+				}
+				x := x0 + (i*6+col)*scale
+				y := y0 + row*scale
+				draw.Draw(img, image.Rect(x, y, x+scale, y+scale), src, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+// glyphs is a 5x7 block font covering pairing codes and hardware IDs.
+var glyphs = map[rune][7]string{
+	' ': {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'-': {".....", ".....", ".....", ".###.", ".....", ".....", "....."},
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"####.", "....#", "....#", ".###.", "....#", "....#", "####."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+}
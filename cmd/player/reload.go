@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// templateSettle is how long the template file must be quiet before a
+// change is reloaded, so that editors and copies finish writing first.
+const templateSettle = 500 * time.Millisecond
+
+// templateWatcher calls onChange when the template file is written,
+// replaced or recreated.
+type templateWatcher struct {
+	watcher *fsnotify.Watcher
+	stopCh  chan struct{}
+}
+
+// isTemplateFile reports whether loadTemplate reads path as a file
+// rather than as the name of a built-in layout.
+func isTemplateFile(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// watchTemplate watches path's directory rather than the file itself:
+// editors and deploy tools usually replace the file, which would end a
+// watch on the old inode.
+func watchTemplate(path string, onChange func()) (*templateWatcher, error) {
+	path = filepath.Clean(path)
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(filepath.Dir(path)); err != nil {
+		fw.Close()
+		return nil, err
+	}
+
+	w := &templateWatcher{watcher: fw, stopCh: make(chan struct{})}
+	go w.loop(path, onChange)
+	log.Printf("[main] watching template %s for changes", path)
+	return w, nil
+}
+
+func (w *templateWatcher) loop(path string, onChange func()) {
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != path || !ev.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			settle = time.After(templateSettle)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[main] template watch error: %v", err)
+		case <-settle:
+			settle = nil
+			log.Printf("[main] template %s changed", path)
+			onChange()
+		}
+	}
+}
+
+// Stop ends the watch.
+func (w *templateWatcher) Stop() {
+	select {
+	case <-w.stopCh:
+	default:
+		close(w.stopCh)
+		w.watcher.Close()
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"player-native/internal/api"
+	"player-native/internal/vlc"
+)
+
+// reporter gives heartbeats the state of whichever session is playing.
+// The last error outlives the session it happened in.
+type reporter struct {
+	mu     sync.Mutex
+	engine *vlc.Engine
+	last   vlc.ZoneError
+}
+
+// attach makes e the engine to report on.
+func (r *reporter) attach(e *vlc.Engine) {
+	r.mu.Lock()
+	r.engine = e
+	r.mu.Unlock()
+}
+
+// detach stops reporting on e, keeping its last error.
+func (r *reporter) detach(e *vlc.Engine) {
+	le, failed := e.LastError()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if failed && le.At.After(r.last.At) {
+		r.last = le
+	}
+	if r.engine == e {
+		r.engine = nil
+	}
+}
+
+// state is the api.Client state function.
+func (r *reporter) state() api.PlayerState {
+	r.mu.Lock()
+	e, last := r.engine, r.last
+	r.mu.Unlock()
+
+	var st api.PlayerState
+	if e != nil {
+		st.Template = e.TemplateName()
+		for _, z := range e.Status() {
+			zr := api.ZoneReport{
+				ID:           z.ID,
+				State:        string(z.State),
+				Items:        z.Items,
+				PlaylistHash: z.PlaylistHash,
+				Error:        z.LastError,
+			}
+			if z.Now != nil {
+				zr.Current = z.Now.File
+			}
+			st.Zones = append(st.Zones, zr)
+		}
+		if le, ok := e.LastError(); ok && le.At.After(last.At) {
+			last = le
+		}
+	}
+	if last.Err != nil {
+		st.LastError = fmt.Sprintf("zone %s: %v", last.ZoneID, last.Err)
+		st.LastErrorAt = last.At
+	}
+	return st
+}
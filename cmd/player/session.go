@@ -0,0 +1,434 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"player-native/internal/api"
+	"player-native/internal/content"
+	"player-native/internal/control"
+	"player-native/internal/playlist"
+	"player-native/internal/proofofplay"
+	"player-native/internal/schedule"
+	"player-native/internal/system"
+	"player-native/internal/template"
+	"player-native/internal/vlc"
+)
+
+// runOptions holds the flags of the `run` command.
+type runOptions struct {
+	playlistDir  string
+	configPath   string
+	templatePath string
+	screenW      int
+	screenH      int
+	control      bool
+	controlAddr  string
+	stagingDir   string
+	popDir       string
+	registerURL  string
+	requireHTTPS bool
+	backend      string
+	recursive    bool
+	watchTmpl    bool
+}
+
+// session is one lifetime of the engine, zone feeds, scheduler, content
+// sync and control API. Reloading the template updates the session in
+// place: only the zones that changed are stopped or started. If that
+// fails, the session ends and a new one starts with the new template.
+type session struct {
+	opts      runOptions
+	tmpl      *template.Template
+	engine    *vlc.Engine
+	sched     *schedule.Scheduler
+	syncer    *content.Syncer
+	ctrl      *control.Server
+	tmplWatch *templateWatcher // guarded by mu; see watch
+	reloadCh  chan *template.Template
+	shared    *shared
+
+	mu    sync.Mutex
+	feeds map[string]*zoneFeed
+}
+
+// loadTemplate reads the configured template (a file, or else the name
+// of a built-in layout), or builds the default fullscreen template, and
+// ensures every playlist directory exists.
+func loadTemplate(opts runOptions) (*template.Template, error) {
+	var tmpl *template.Template
+	if opts.templatePath != "" {
+		var err error
+		tmpl, err = template.LoadFromFile(opts.templatePath)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Not a file: try the built-in of that name.
+			if b, berr := template.Builtin(opts.templatePath, opts.playlistDir); berr == nil {
+				tmpl, err = b, nil
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("template load: %w", err)
+		}
+		log.Printf("[main] loaded template %q with %d zone(s)", tmpl.Name, len(tmpl.Zones))
+		for _, w := range tmpl.Diagnose(opts.screenW, opts.screenH).Warnings {
+			log.Printf("[main] template warning: %s", w)
+		}
+	} else {
+		tmpl = template.Fullscreen(opts.playlistDir)
+		log.Printf("[main] using default fullscreen template")
+	}
+
+	// Ensure all playlist directories exist.
+	for i := range tmpl.Zones {
+		z := &tmpl.Zones[i]
+		if z.Backend != "" {
+			if err := vlc.CheckBackend(z.Backend); err != nil {
+				return nil, fmt.Errorf("zone %q: %w", z.ID, err)
+			}
+		}
+		if err := system.EnsureDir(z.PlaylistDir); err != nil {
+			return nil, fmt.Errorf("playlist dir %s: %w", z.PlaylistDir, err)
+		}
+		if z.Schedule != nil {
+			for _, win := range z.Schedule.Windows {
+				if err := system.EnsureDir(win.PlaylistDir); err != nil {
+					return nil, fmt.Errorf("playlist dir %s: %w", win.PlaylistDir, err)
+				}
+			}
+		}
+	}
+
+	return tmpl, nil
+}
+
+// shared is what outlives sessions. Any field may be nil.
+type shared struct {
+	api    *api.Client      // server client, for content sync
+	popLog *proofofplay.Log // receives play events
+	pair   *pairing         // applied to reloaded templates
+	report *reporter        // told about each session's engine
+
+	mu       sync.Mutex
+	current  *session    // the playing session, for server commands
+	tmplPath string      // replaces --template when set by the server
+	restart  atomic.Bool // exit non-zero so that systemd restarts us
+
+	restartCh chan struct{} // buffered; stops the playing or next session
+}
+
+// requestRestart makes the player exit non-zero, so that the service
+// starts it again. It does not block, even between sessions.
+func (sh *shared) requestRestart() {
+	sh.restart.Store(true)
+	select {
+	case sh.restartCh <- struct{}{}:
+	default:
+	}
+}
+
+// session returns the playing session, or nil between sessions.
+func (sh *shared) session() *session {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.current
+}
+
+// setSession records s as the playing session or, when it stops
+// playing, forgets it if it is still current.
+func (sh *shared) setSession(s *session, playing bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if playing {
+		sh.current = s
+	} else if sh.current == s {
+		sh.current = nil
+	}
+}
+
+// templateOpts returns opts with the template the server switched to,
+// if any.
+func (sh *shared) templateOpts(opts runOptions) runOptions {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.tmplPath != "" {
+		opts.templatePath = sh.tmplPath
+	}
+	return opts
+}
+
+// syncTargets maps content manifest zone keys to directories: each zone
+// ID maps to its playlist directory and "zone/window" to the directory
+// of each named schedule window.
+func syncTargets(tmpl *template.Template) map[string]string {
+	targets := make(map[string]string)
+	for _, z := range tmpl.Zones {
+		targets[z.ID] = z.PlaylistDir
+		if z.Schedule == nil {
+			continue
+		}
+		for _, win := range z.Schedule.Windows {
+			if win.Name != "" {
+				targets[z.ID+"/"+win.Name] = win.PlaylistDir
+			}
+		}
+	}
+	return targets
+}
+
+// newSession builds the engine and per-zone feeds for tmpl and starts
+// the scheduler, content sync (when an API client is available) and,
+// if enabled, the control API.
+func newSession(opts runOptions, tmpl *template.Template, sh *shared) (*session, error) {
+	s := &session{
+		opts:     opts,
+		tmpl:     tmpl,
+		shared:   sh,
+		feeds:    make(map[string]*zoneFeed),
+		reloadCh: make(chan *template.Template, 1),
+	}
+
+	// --- Engine (manages all zones) ---
+	factory, err := vlc.NewBackendFactory(opts.backend)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := vlc.NewEngine(tmpl, opts.screenW, opts.screenH, vlc.WithBackendFactory(factory))
+	if err != nil {
+		return nil, fmt.Errorf("engine init: %w", err)
+	}
+	s.engine = engine
+	if sh.popLog != nil {
+		engine.OnPlayEvent(sh.popLog.Record)
+	}
+
+	// --- Per-Zone Playlist Feeds (watcher + dayparting) ---
+	s.sched = schedule.NewScheduler(s.switchDir)
+	for _, z := range tmpl.Zones {
+		if err := s.addFeed(z); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+	go s.sched.Start()
+
+	// --- Template Hot Reload ---
+	s.watch(sh.templateOpts(opts).templatePath)
+
+	// --- Content Sync ---
+	hooks := control.Hooks{Reload: s.requestReload}
+	if sh.api != nil {
+		s.syncer = content.NewSyncer(sh.api, opts.stagingDir, syncTargets(tmpl))
+		interval := time.Duration(sh.api.GetConfig().SyncInterval) * time.Second
+		go s.syncer.Start(interval)
+		hooks.Sync = func() error {
+			s.syncer.Trigger()
+			return nil
+		}
+	}
+
+	// --- Control API (opt-in) ---
+	if opts.control {
+		s.ctrl = control.NewServer(opts.controlAddr, version, engine, hooks)
+		go func() {
+			if err := s.ctrl.Start(); err != nil {
+				log.Printf("[main] control server error: %v", err)
+			}
+		}()
+	}
+
+	return s, nil
+}
+
+// addFeed starts the playlist feed for a zone, registering its
+// schedule first so that the feed opens the active directory.
+func (s *session) addFeed(z template.Zone) error {
+	dir := z.PlaylistDir
+	if z.Schedule != nil {
+		var err error
+		dir, err = s.sched.Add(z.ID, z.PlaylistDir, z.Schedule)
+		if err != nil {
+			return fmt.Errorf("schedule init: %w", err)
+		}
+	}
+
+	var watchOpts []playlist.Option
+	if s.opts.recursive || z.Recursive {
+		watchOpts = append(watchOpts, playlist.WithRecursive())
+	}
+
+	f, err := newZoneFeed(s.engine, z.ID, dir, watchOpts...)
+	if err != nil {
+		s.sched.Remove(z.ID)
+		return err
+	}
+	s.mu.Lock()
+	s.feeds[z.ID] = f
+	s.mu.Unlock()
+	return nil
+}
+
+// dropFeed stops a zone's feed and schedule.
+func (s *session) dropFeed(zoneID string) {
+	s.sched.Remove(zoneID)
+	s.mu.Lock()
+	f := s.feeds[zoneID]
+	delete(s.feeds, zoneID)
+	s.mu.Unlock()
+	if f != nil {
+		f.Stop()
+	}
+}
+
+// run plays until a signal, a fatal zone error, or a reload that could
+// not be applied in place. It returns the template to restart with, or
+// nil to exit.
+func (s *session) run(sigCh <-chan os.Signal) *template.Template {
+	errCh := s.engine.Play()
+	if s.shared.report != nil {
+		s.shared.report.attach(s.engine)
+	}
+	s.shared.setSession(s, true)
+
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				if err := s.requestReload(); err != nil {
+					log.Printf("[main] reload: %v", err)
+				}
+				continue
+			}
+			log.Printf("[main] received signal: %v — shutting down", sig)
+			s.engine.Stop()
+		case <-s.shared.restartCh:
+			log.Println("[main] restart requested by server — shutting down")
+			s.engine.Stop()
+		case err := <-errCh:
+			if err != nil {
+				log.Printf("[main] zone error: %v", err)
+			}
+		case tmpl := <-s.reloadCh:
+			log.Printf("[main] reloading template %q", tmpl.Name)
+			if err := s.apply(tmpl); err != nil {
+				log.Printf("[main] in-place reload failed: %v — restarting all zones", err)
+				s.engine.Stop()
+				return tmpl
+			}
+			continue
+		}
+		return nil
+	}
+}
+
+// apply switches the session to tmpl. The engine replaces only the
+// zones that changed, and their feeds and schedules follow; unchanged
+// zones keep playing.
+func (s *session) apply(tmpl *template.Template) error {
+	c, err := s.engine.UpdateTemplate(tmpl)
+	if err != nil {
+		return err
+	}
+	if c.Empty() {
+		log.Printf("[main] template %q unchanged", tmpl.Name)
+	}
+
+	stale := append(append(c.Removed, c.Replaced...), c.Content...)
+	for _, z := range stale {
+		s.dropFeed(z.ID)
+	}
+	fresh := append(append(c.Added, c.Replaced...), c.Content...)
+	for _, z := range fresh {
+		if err := s.addFeed(z); err != nil {
+			return err
+		}
+	}
+
+	s.tmpl = tmpl
+	if s.syncer != nil {
+		s.syncer.SetTargets(syncTargets(tmpl))
+	}
+	return nil
+}
+
+// watch points the template watcher at path, or stops it when path is
+// not a template file (unset, or the name of a built-in layout).
+func (s *session) watch(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tmplWatch != nil {
+		s.tmplWatch.Stop()
+		s.tmplWatch = nil
+	}
+	if !s.opts.watchTmpl || !isTemplateFile(path) {
+		return
+	}
+	w, err := watchTemplate(path, func() {
+		if err := s.requestReload(); err != nil {
+			log.Printf("[main] template change ignored: %v", err)
+		}
+	})
+	if err != nil {
+		log.Printf("[main] template watch disabled: %v", err)
+		return
+	}
+	s.tmplWatch = w
+}
+
+// requestReload re-reads the template and, if it is valid, queues it
+// for run() to apply.
+func (s *session) requestReload() error {
+	tmpl, err := loadTemplate(s.shared.templateOpts(s.opts))
+	if err != nil {
+		return err
+	}
+	tmpl = s.shared.pair.apply(tmpl)
+	select {
+	case s.reloadCh <- tmpl:
+	default:
+		return fmt.Errorf("reload already pending")
+	}
+	return nil
+}
+
+// switchDir is the scheduler callback that retargets a zone's feed.
+func (s *session) switchDir(zoneID, dir string) {
+	s.mu.Lock()
+	f, ok := s.feeds[zoneID]
+	s.mu.Unlock()
+	if ok {
+		if err := f.SetDir(dir); err != nil {
+			log.Printf("[main] schedule switch for zone %s: %v", zoneID, err)
+		}
+	}
+}
+
+// close releases everything the session started, in reverse order.
+func (s *session) close() {
+	s.shared.setSession(s, false)
+	s.watch("")
+	if s.ctrl != nil {
+		s.ctrl.Stop()
+	}
+	if s.syncer != nil {
+		s.syncer.Stop()
+	}
+	if s.sched != nil {
+		s.sched.Stop()
+	}
+	for _, f := range s.feeds {
+		f.Stop()
+	}
+	if s.engine != nil {
+		if s.shared.report != nil {
+			s.shared.report.detach(s.engine)
+		}
+		s.engine.Release()
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"player-native/internal/template"
+	"player-native/internal/vlc"
+
+	"github.com/spf13/cobra"
+)
+
+// templateCmd groups the commands for checking and inspecting layout
+// templates without starting playback.
+func templateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Validate, preview and export layout templates",
+	}
+	cmd.AddCommand(templateValidateCmd())
+	cmd.AddCommand(templateListCmd())
+	cmd.AddCommand(templateExportCmd())
+	cmd.AddCommand(templatePreviewCmd())
+	return cmd
+}
+
+func templateValidateCmd() *cobra.Command {
+	var (
+		screenW, screenH int
+		asJSON           bool
+	)
+	cmd := &cobra.Command{
+		Use:   "validate <file>",
+		Short: "Check a template for errors and warnings",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tmpl, err := template.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			d := tmpl.Diagnose(screenW, screenH)
+			for _, z := range tmpl.Zones {
+				if z.Backend == "" {
+					continue
+				}
+				if err := vlc.CheckBackend(z.Backend); err != nil {
+					d.Errors = append(d.Errors, template.Diagnostic{
+						Code:    "unknown-backend",
+						Zones:   []string{z.ID},
+						Message: fmt.Sprintf("zone %q: %v", z.ID, err),
+					})
+				}
+			}
+
+			if asJSON {
+				out, _ := json.MarshalIndent(d, "", "  ")
+				fmt.Println(string(out))
+			} else {
+				for _, e := range d.Errors {
+					fmt.Printf("error   [%s] %s\n", e.Code, e.Message)
+				}
+				for _, w := range d.Warnings {
+					fmt.Printf("warning [%s] %s\n", w.Code, w.Message)
+				}
+				fmt.Printf("%s: %d zone(s), %d error(s), %d warning(s) at %dx%d\n",
+					args[0], len(tmpl.Zones), len(d.Errors), len(d.Warnings), screenW, screenH)
+			}
+			if len(d.Errors) > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("template %s is invalid", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&screenW, "width", 1920, "Screen width in pixels")
+	cmd.Flags().IntVar(&screenH, "height", 1080, "Screen height in pixels")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the findings as JSON")
+	return cmd
+}
+
+func templateListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the built-in templates",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, name := range template.BuiltinNames() {
+				tmpl, _ := template.Builtin(name, defaultPlaylistDir())
+				fmt.Printf("%-18s %d zone(s):", name, len(tmpl.Zones))
+				for _, z := range tmpl.Zones {
+					fmt.Printf(" %s (%gx%g%%)", z.ID, z.Width, z.Height)
+				}
+				fmt.Println()
+			}
+		},
+	}
+}
+
+func templateExportCmd() *cobra.Command {
+	var (
+		playlistDir string
+		outPath     string
+	)
+	cmd := &cobra.Command{
+		Use:   "export <builtin>",
+		Short: "Write a built-in template as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tmpl, err := template.Builtin(args[0], playlistDir)
+			if err != nil {
+				return err
+			}
+			out, err := json.MarshalIndent(tmpl, "", "  ")
+			if err != nil {
+				return err
+			}
+			out = append(out, '\n')
+			if outPath == "" {
+				_, err = os.Stdout.Write(out)
+				return err
+			}
+			return os.WriteFile(outPath, out, 0644)
+		},
+	}
+	cmd.Flags().StringVarP(&playlistDir, "playlist", "p", defaultPlaylistDir(), "Playlist root for the zone directories")
+	cmd.Flags().StringVarP(&outPath, "output", "o", "", "Output file (default: stdout)")
+	return cmd
+}
+
+func templatePreviewCmd() *cobra.Command {
+	var (
+		screenW, screenH int
+		cols             int
+		pngPath          string
+	)
+	cmd := &cobra.Command{
+		Use:   "preview <file|builtin>",
+		Short: "Draw a template's zones for a screen size",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if screenW <= 0 || screenH <= 0 || cols < 8 {
+				return fmt.Errorf("invalid preview size %dx%d (%d columns)", screenW, screenH, cols)
+			}
+			tmpl, err := template.ReadFile(args[0])
+			if errors.Is(err, fs.ErrNotExist) {
+				// Not a file: try the built-in of that name.
+				if b, berr := template.Builtin(args[0], defaultPlaylistDir()); berr == nil {
+					tmpl, err = b, nil
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			// Drawn as the viewer sees it; positions are on the panel.
+			w, h := tmpl.Screen(screenW, screenH)
+			if tmpl.Rotation != 0 {
+				fmt.Printf("%s at %dx%d (rotated %d° on a %dx%d panel)\n", tmpl.Name, w, h, tmpl.Rotation, screenW, screenH)
+			} else {
+				fmt.Printf("%s at %dx%d\n", tmpl.Name, w, h)
+			}
+			fmt.Print(tmpl.ASCII(w, h, cols))
+			for _, z := range tmpl.Stacked() {
+				r := tmpl.PanelRect(z, screenW, screenH)
+				fmt.Printf("  %-12s %5dx%-5d at %d,%d  zindex %d\n", z.ID, r.W, r.H, r.X, r.Y, z.Zindex)
+			}
+
+			if pngPath == "" {
+				return nil
+			}
+			f, err := os.Create(pngPath)
+			if err != nil {
+				return err
+			}
+			if err := tmpl.PNG(f, w, h); err != nil {
+				f.Close()
+				return fmt.Errorf("write %s: %w", pngPath, err)
+			}
+			return f.Close()
+		},
+	}
+	cmd.Flags().IntVar(&screenW, "width", 1920, "Panel width in pixels")
+	cmd.Flags().IntVar(&screenH, "height", 1080, "Panel height in pixels")
+	cmd.Flags().IntVar(&cols, "cols", 72, "Width of the text diagram in characters")
+	cmd.Flags().StringVar(&pngPath, "png", "", "Also write the diagram as a PNG image")
+	return cmd
+}
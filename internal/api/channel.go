@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Real-time channel message types.
+const (
+	MessageCommands       = "commands"
+	MessageContentChanged = "content_changed"
+)
+
+// Message is a real-time notification from the server. Commands are
+// set for MessageCommands.
+type Message struct {
+	Type     string    `json:"type"`
+	Commands []Command `json:"commands,omitempty"`
+}
+
+// pollResponse is the body of a long-poll response.
+type pollResponse struct {
+	Messages []Message `json:"messages"`
+}
+
+// signedMessage is a Message as sent over the WebSocket. Seq counts the
+// messages on the connection from 1, and Signature is
+// SignMessage(key, handshake nonce, Seq, Message).
+type signedMessage struct {
+	Seq       int64           `json:"seq"`
+	Message   json.RawMessage `json:"message"`
+	Signature string          `json:"signature"`
+}
+
+// maxMessage bounds a received message, like heartbeat responses.
+const maxMessage = 1 << 20
+
+// Channel timing. A WebSocket that has sent nothing, not even a pong,
+// for two ping intervals is considered dead. While long-polling, the
+// client tries a WebSocket again every wsRetry.
+const (
+	DefaultPingInterval = 20 * time.Second
+	minChannelRetry     = time.Second
+	maxChannelRetry     = 2 * time.Minute
+	pollWait            = 30 * time.Second
+	wsRetry             = 10 * time.Minute
+)
+
+// channelTiming holds the channel intervals; tests shorten them.
+type channelTiming struct {
+	ping     time.Duration
+	retryMin time.Duration
+	retryMax time.Duration
+	pollWait time.Duration
+	wsRetry  time.Duration
+}
+
+var defaultChannelTiming = channelTiming{
+	ping:     DefaultPingInterval,
+	retryMin: minChannelRetry,
+	retryMax: maxChannelRetry,
+	pollWait: pollWait,
+	wsRetry:  wsRetry,
+}
+
+// errNoUpgrade means the server answered the channel request without
+// switching to WebSocket, so long-polling is tried instead.
+var errNoUpgrade = errors.New("server did not upgrade to websocket")
+
+// OnContentChange sets the function called when the server announces
+// new content. It runs on the channel goroutine and must not block.
+func (c *Client) OnContentChange(fn func()) {
+	c.mu.Lock()
+	c.onContent = fn
+	c.mu.Unlock()
+}
+
+// StartChannel keeps a real-time channel to the server open, so that
+// commands and content notifications arrive at once rather than with
+// the next heartbeat or sync poll. It uses a WebSocket at
+// {endpoint}/channel and falls back to long-polling
+// {endpoint}/channel/poll if the server does not upgrade. Lost
+// connections are retried with exponential backoff and jitter. It
+// waits while the player is unregistered, reconnects when the config
+// changes, and blocks until Stop() is called.
+func (c *Client) StartChannel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.stopCh
+		cancel()
+	}()
+
+	// The config is read below; a load before now is no change.
+	select {
+	case <-c.cfgCh:
+	default:
+	}
+
+	log.Println("[api] channel started")
+	attempt := 0
+	for {
+		cfg := c.GetConfig()
+		if cfg.Endpoint == "" || cfg.ID == "" {
+			select {
+			case <-ctx.Done():
+				log.Println("[api] channel stopped")
+				return
+			case <-c.cfgCh:
+				continue
+			}
+		}
+
+		connected, err := c.runChannel(ctx)
+		if ctx.Err() != nil {
+			log.Println("[api] channel stopped")
+			return
+		}
+		if connected {
+			attempt = 0
+		}
+		if err == nil {
+			continue // reconnect at once, e.g. to retry WebSocket
+		}
+
+		wait := retryDelay(attempt, c.chTiming.retryMin, c.chTiming.retryMax)
+		attempt++
+		log.Printf("[api] channel: %v (reconnecting in %s)", err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			log.Println("[api] channel stopped")
+			return
+		case <-c.cfgCh:
+			attempt = 0
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryDelay is the wait before reconnect attempt n (from 0). It
+// doubles from lo up to hi, less a random part of up to half, so that
+// players that lost the server together do not return together.
+func retryDelay(n int, lo, hi time.Duration) time.Duration {
+	d := hi
+	if n < 32 && lo<<n < hi {
+		d = lo << n
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// runChannel runs one connection until it fails. connected reports
+// whether it worked for a while, which resets the backoff; a nil error
+// asks for an immediate reconnect.
+func (c *Client) runChannel(ctx context.Context) (connected bool, err error) {
+	cfg := c.GetConfig()
+	ws, nonce, err := c.dialWebSocket(ctx)
+	if errors.Is(err, errNoUpgrade) {
+		log.Printf("[api] channel: %v, long-polling", err)
+		cli := c.channelHTTPClient()
+		defer cli.CloseIdleConnections()
+		return c.longPoll(ctx, cli)
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Println("[api] channel connected (websocket)")
+	start := time.Now()
+	err = c.serveWebSocket(ctx, ws, cfg, nonce)
+	return time.Since(start) >= c.chTiming.ping, err
+}
+
+// channelHTTPClient returns a client for long-polling. Its only timeout
+// is for the response headers, which the server may hold back for
+// pollWait.
+func (c *Client) channelHTTPClient() *http.Client {
+	c.mu.RLock()
+	tc := c.tls
+	c.mu.RUnlock()
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if tc != nil {
+		t.TLSClientConfig = tc.Clone()
+	}
+	t.ResponseHeaderTimeout = c.chTiming.pollWait + 15*time.Second
+	return &http.Client{Transport: t}
+}
+
+// dialWebSocket performs the signed WebSocket handshake, over TLS for
+// an https endpoint. It returns the handshake request's nonce, which
+// the server's message signatures are bound to.
+func (c *Client) dialWebSocket(ctx context.Context) (*websocket.Conn, string, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, "/channel", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	u := *req.URL
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+
+	c.mu.RLock()
+	tc := c.tls
+	c.mu.RUnlock()
+	d := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+	}
+	if tc != nil {
+		d.TLSClientConfig = tc.Clone()
+	}
+
+	ws, resp, err := d.DialContext(ctx, u.String(), req.Header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, "", fmt.Errorf("%w (%d)", errNoUpgrade, resp.StatusCode)
+		}
+		return nil, "", fmt.Errorf("websocket: %w", err)
+	}
+	ws.SetReadLimit(maxMessage)
+	return ws, req.Header.Get(HeaderNonce), nil
+}
+
+// serveWebSocket reads messages until the connection fails, pinging
+// the server to detect a connection that died silently. A config
+// change that affects the connection closes it and returns nil.
+func (c *Client) serveWebSocket(ctx context.Context, ws *websocket.Conn, cfg Config, nonce string) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	// Anything from the server, a pong included, shows it is alive.
+	timeout := 2 * c.chTiming.ping
+	alive := func() { ws.SetReadDeadline(time.Now().Add(timeout)) }
+	alive()
+	ws.SetPongHandler(func(string) error {
+		alive()
+		return nil
+	})
+
+	reason := make(chan error, 1)
+	closeWith := func(code int) {
+		msg := websocket.FormatCloseMessage(code, "")
+		ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		ws.Close()
+	}
+	go func() {
+		ticker := time.NewTicker(c.chTiming.ping)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				closeWith(websocket.CloseGoingAway)
+				return
+			case <-c.cfgCh:
+				if !sameChannel(cfg, c.GetConfig()) {
+					log.Println("[api] channel: config changed, reconnecting")
+					reason <- nil
+					closeWith(websocket.CloseNormalClosure)
+					return
+				}
+			case <-ticker.C:
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.chTiming.ping)); err != nil {
+					ws.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	var seq int64
+	for {
+		typ, msg, err := ws.ReadMessage()
+		if err != nil {
+			ws.Close()
+			select {
+			case r := <-reason:
+				return r
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return fmt.Errorf("nothing received within %s", timeout)
+			}
+			return fmt.Errorf("websocket: %w", err)
+		}
+		alive()
+		if typ == websocket.TextMessage {
+			c.deliver(msg, cfg.Key, nonce, &seq)
+		}
+	}
+}
+
+// sameChannel reports whether a config change leaves the channel's
+// server and credentials as they were.
+func sameChannel(a, b Config) bool {
+	return a.Endpoint == b.Endpoint && a.ID == b.ID && a.Key == b.Key &&
+		a.ClientCert == b.ClientCert && a.ClientKey == b.ClientKey && a.CABundle == b.CABundle
+}
+
+// longPoll polls until a request fails, the config changes or it is
+// time to try a WebSocket again.
+func (c *Client) longPoll(ctx context.Context, cli *http.Client) (connected bool, err error) {
+	retryWS := time.Now().Add(c.chTiming.wsRetry)
+	for time.Now().Before(retryWS) {
+		select {
+		case <-c.cfgCh:
+			return connected, nil
+		default:
+		}
+
+		start := time.Now()
+		msgs, err := c.poll(ctx, cli)
+		if err != nil {
+			return connected, err
+		}
+		if !connected {
+			log.Println("[api] channel connected (long-poll)")
+			connected = true
+		}
+		for _, m := range msgs {
+			c.handleMessage(m)
+		}
+
+		// A server that answers at once must not make this a busy loop.
+		if len(msgs) == 0 && time.Since(start) < c.chTiming.retryMin {
+			select {
+			case <-ctx.Done():
+				return connected, ctx.Err()
+			case <-time.After(c.chTiming.retryMin):
+			}
+		}
+	}
+	return connected, nil
+}
+
+// poll makes one long-poll request. The server answers with messages
+// as soon as it has any, or 204 No Content after waiting.
+func (c *Client) poll(ctx context.Context, cli *http.Client) ([]Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.chTiming.pollWait+30*time.Second)
+	defer cancel()
+
+	key := c.GetConfig().Key
+	wait := strconv.Itoa(int(c.chTiming.pollWait.Seconds()))
+	req, err := c.NewRequest(ctx, http.MethodGet, "/channel/poll?wait="+wait, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("long-poll: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("long-poll: server returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessage))
+	if err != nil {
+		return nil, fmt.Errorf("long-poll: %w", err)
+	}
+	if err := verifyResponse(key, req.Header.Get(HeaderNonce), resp.Header.Get(HeaderServerSignature), body); err != nil {
+		return nil, fmt.Errorf("long-poll: %w", err)
+	}
+	var pr pollResponse
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("long-poll: %w", err)
+	}
+	return pr.Messages, nil
+}
+
+// deliver handles a message received over the WebSocket. Messages that
+// are not signed with key, or repeat an earlier sequence number, are
+// dropped; seq is the last one accepted.
+func (c *Client) deliver(data []byte, key, nonce string, seq *int64) {
+	var sm signedMessage
+	if err := json.Unmarshal(data, &sm); err != nil {
+		log.Printf("[api] channel message: %v", err)
+		return
+	}
+	if sm.Seq <= *seq || verifyResponse(key, messageNonce(nonce, sm.Seq), sm.Signature, sm.Message) != nil {
+		log.Printf("[api] channel message %d: %v, ignored", sm.Seq, ErrUnsignedResponse)
+		return
+	}
+	*seq = sm.Seq
+
+	var m Message
+	if err := json.Unmarshal(sm.Message, &m); err != nil {
+		log.Printf("[api] channel message: %v", err)
+		return
+	}
+	c.handleMessage(m)
+}
+
+// handleMessage passes a message to the commander or the content
+// change function.
+func (c *Client) handleMessage(m Message) {
+	c.mu.RLock()
+	cm, onContent := c.commander, c.onContent
+	c.mu.RUnlock()
+
+	switch m.Type {
+	case MessageCommands:
+		if cm != nil && len(m.Commands) > 0 {
+			cm.Dispatch(m.Commands)
+		}
+	case MessageContentChanged:
+		log.Println("[api] channel: content changed")
+		if onContent != nil {
+			onContent()
+		}
+	default:
+		log.Printf("[api] channel: unknown message type %q ignored", m.Type)
+	}
+}
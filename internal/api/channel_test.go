@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// chanCommander passes dispatched commands to a channel.
+type chanCommander chan Command
+
+func (ch chanCommander) Dispatch(cmds []Command) {
+	for _, cmd := range cmds {
+		ch <- cmd
+	}
+}
+func (ch chanCommander) Results() []CommandResult { return nil }
+func (ch chanCommander) Ack(ids []string)         {}
+
+// wsServer is the server side of a channel WebSocket.
+type wsServer struct {
+	ws    *websocket.Conn
+	key   string
+	nonce string
+	seq   int64
+}
+
+func acceptWebSocket(w http.ResponseWriter, r *http.Request, key string) (*wsServer, error) {
+	ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &wsServer{ws: ws, key: key, nonce: r.Header.Get(HeaderNonce)}, nil
+}
+
+// send writes msg as the next signed message.
+func (s *wsServer) send(msg string) error {
+	s.seq++
+	return s.sendAs(s.seq, s.key, msg)
+}
+
+// sendAs writes msg with sequence number seq, signed with key.
+func (s *wsServer) sendAs(seq int64, key, msg string) error {
+	return s.ws.WriteJSON(signedMessage{
+		Seq:       seq,
+		Message:   json.RawMessage(msg),
+		Signature: SignMessage(key, s.nonce, seq, []byte(msg)),
+	})
+}
+
+// startChannel runs c's channel with short timings until the test ends.
+func startChannel(t *testing.T, c *Client) {
+	t.Helper()
+	c.chTiming = channelTiming{
+		ping:     50 * time.Millisecond,
+		retryMin: 10 * time.Millisecond,
+		retryMax: 100 * time.Millisecond,
+		pollWait: time.Second,
+		wsRetry:  time.Minute,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		c.StartChannel()
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		c.Stop()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Error("channel did not stop")
+		}
+	})
+}
+
+// TestChannelWebSocket: commands and content notifications arrive over
+// a signed WebSocket within a second, forged and replayed messages are
+// dropped, and a server that stops answering pings is dropped and
+// reconnected to.
+func TestChannelWebSocket(t *testing.T) {
+	var conns atomic.Int32
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Verify(r, nil, "secret", time.Now()); err != nil || r.URL.Path != "/channel" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s, err := acceptWebSocket(w, r, "secret")
+		if err != nil {
+			return
+		}
+		defer s.ws.Close()
+
+		if conns.Add(1) == 1 {
+			s.send(`{"type":"commands","commands":[{"id":"c1","name":"resync"}]}`)
+			s.sendAs(2, "not-the-key", `{"type":"commands","commands":[{"id":"forged","name":"reboot"}]}`)
+			s.sendAs(1, "secret", `{"type":"commands","commands":[{"id":"replayed","name":"reboot"}]}`)
+			s.send(`{"type":"content_changed"}`)
+			<-release // silent: pings go unanswered
+			return
+		}
+		s.send(`{"type":"content_changed"}`)
+		for {
+			if _, _, err := s.ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(writeConfig(t, Config{ID: "p1", Key: "secret", Endpoint: srv.URL}), "test")
+	cmds := make(chanCommander, 1)
+	changes := make(chan struct{}, 2)
+	c.SetCommander(cmds)
+	c.OnContentChange(func() { changes <- struct{}{} })
+	start := time.Now()
+	startChannel(t, c)
+
+	select {
+	case cmd := <-cmds:
+		if cmd.ID != "c1" || cmd.Name != "resync" {
+			t.Errorf("unexpected command %+v", cmd)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("command took %s to arrive", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no command delivered")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d content notification(s) over %d connection(s), want 2", i, conns.Load())
+		}
+	}
+	select {
+	case cmd := <-cmds:
+		t.Errorf("unsigned command %+v dispatched", cmd)
+	default:
+	}
+}
+
+// TestChannelLongPoll: a server without WebSocket support is long-polled
+// with signed requests, and only signed responses are acted on.
+func TestChannelLongPoll(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Verify(r, nil, "secret", time.Now()); err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/channel/poll" || r.URL.Query().Get("wait") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		switch polls.Add(1) {
+		case 1:
+			w.Write([]byte(`{"messages":[{"type":"commands","commands":[{"id":"unsigned","name":"reboot"}]}]}`))
+			return
+		case 2:
+			reply := []byte(`{"messages":[{"type":"commands","commands":[{"id":"c2","name":"screenshot"}]}]}`)
+			w.Header().Set(HeaderServerSignature, SignResponse("secret", r.Header.Get(HeaderNonce), reply))
+			w.Write(reply)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(writeConfig(t, Config{ID: "p1", Key: "secret", Endpoint: srv.URL}), "test")
+	cmds := make(chanCommander, 1)
+	c.SetCommander(cmds)
+	startChannel(t, c)
+
+	select {
+	case cmd := <-cmds:
+		if cmd.ID != "c2" {
+			t.Errorf("unexpected command %+v", cmd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no command delivered")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for polls.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := polls.Load(); n < 4 {
+		t.Errorf("polling stopped after %d request(s)", n)
+	}
+}
+
+// TestRetryDelay: delays double up to the cap and keep at least half
+// of their nominal value.
+func TestRetryDelay(t *testing.T) {
+	lo, hi := time.Second, time.Minute
+	for n, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute} {
+		for i := 0; i < 20; i++ {
+			if d := retryDelay(n, lo, hi); d < want/2 || d > want {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", n, d, want/2, want)
+			}
+		}
+	}
+	if d := retryDelay(100, lo, hi); d < hi/2 || d > hi {
+		t.Errorf("attempt 100: delay %s outside [%s, %s]", d, hi/2, hi)
+	}
+}
@@ -4,34 +4,96 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
+
+	"player-native/internal/system"
 )
 
 // Config mirrors the legacy Node.js config.json identity structure.
+// SyncInterval controls content manifest polling: 0 uses the default,
+// a negative value disables polling (push-triggered sync only).
+// PopInterval is how often proof-of-play records are uploaded.
+// ClientCert and ClientKey (PEM files) enable mutual TLS; CABundle adds
+// trusted CAs for a server with a private certificate. AllowedCommands
+// lists the server commands the player may run ("*" for all).
 type Config struct {
-	ID       string `json:"id"`
-	Key      string `json:"key"`
-	Name     string `json:"name"`
-	Endpoint string `json:"endpoint"`
-	Interval int    `json:"heartbeat_interval_sec"`
+	ID           string `json:"id"`
+	Key          string `json:"key"`
+	Name         string `json:"name"`
+	Endpoint     string `json:"endpoint"`
+	Interval     int    `json:"heartbeat_interval_sec"`
+	SyncInterval int    `json:"content_sync_interval_sec,omitempty"`
+	PopInterval  int    `json:"proof_of_play_interval_sec,omitempty"`
+	ClientCert   string `json:"tls_client_cert,omitempty"`
+	ClientKey    string `json:"tls_client_key,omitempty"`
+	CABundle     string `json:"tls_ca_bundle,omitempty"`
+
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
 }
 
+// ErrUnregistered is returned for server requests when the player has
+// no endpoint or ID configured.
+var ErrUnregistered = errors.New("player not registered: missing endpoint or id")
+
+// HeartbeatSchema is the version of the Heartbeat payload. Version 1
+// has the identity, uptime and build fields; version 2 adds health,
+// player state, addresses and display; version 3 adds command results.
+// Versions only ever add fields, so a server written for an older one
+// can ignore the rest.
+const HeartbeatSchema = 3
+
 // Heartbeat is the payload sent to the remote server on each tick.
 type Heartbeat struct {
+	Schema    int     `json:"schema"`
 	ID        string  `json:"id"`
-	Key       string  `json:"key"`
 	Timestamp string  `json:"timestamp"`
 	Uptime    float64 `json:"uptime_sec"`
 	Version   string  `json:"version"`
 	Arch      string  `json:"arch"`
 	OS        string  `json:"os"`
+
+	// Schema 2.
+	Health      *system.HealthStatus `json:"health,omitempty"`
+	Template    string               `json:"template,omitempty"`
+	Zones       []ZoneReport         `json:"zones,omitempty"`
+	LastError   string               `json:"last_error,omitempty"`
+	LastErrorAt string               `json:"last_error_at,omitempty"`
+	Addresses   []system.Addr        `json:"addresses,omitempty"`
+	Display     *system.Display      `json:"display,omitempty"`
+
+	// Schema 3.
+	CommandResults []CommandResult `json:"command_results,omitempty"`
+}
+
+// ZoneReport is a zone's playback state in a heartbeat. State is one of
+// idle, playing, paused, error or stopped.
+type ZoneReport struct {
+	ID           string `json:"id"`
+	State        string `json:"state"`
+	Current      string `json:"current,omitempty"`
+	Items        int    `json:"items"`
+	PlaylistHash string `json:"playlist_hash,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// PlayerState is what the player is doing, as reported in heartbeats.
+type PlayerState struct {
+	Template    string
+	Zones       []ZoneReport
+	LastError   string
+	LastErrorAt time.Time
 }
 
 // Client manages the heartbeat loop and server communication.
@@ -42,7 +104,28 @@ type Client struct {
 	version string
 	startAt time.Time
 	httpCli *http.Client
+	tls     *tls.Config
 	stopCh  chan struct{}
+	display *system.Display
+	state   func() PlayerState
+	health  func() system.HealthStatus
+
+	commander Commander
+	onContent func()
+	cfgCh     chan struct{} // signalled when the config is (re)loaded
+	chTiming  channelTiming
+
+	requireHTTPS bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRequireHTTPS refuses to talk to an endpoint that is not https,
+// so that a misconfigured endpoint cannot leak heartbeats or content
+// requests in the clear.
+func WithRequireHTTPS() Option {
+	return func(c *Client) { c.requireHTTPS = true }
 }
 
 // DefaultConfigPath is the standard location for the player identity file.
@@ -50,14 +133,23 @@ const DefaultConfigPath = "/etc/player/config.json"
 
 // NewClient creates an API client by loading the config from the given path.
 // If the file does not exist, the client starts in "unregistered" mode
-// and will log warnings on each heartbeat attempt until configured.
-func NewClient(cfgPath, version string) (*Client, error) {
+// with the default intervals and will log warnings on each heartbeat
+// attempt until configured (see Provision).
+func NewClient(cfgPath, version string, opts ...Option) (*Client, error) {
 	c := &Client{
+		cfg:     withDefaults(Config{}),
 		cfgPath: cfgPath,
 		version: version,
 		startAt: time.Now(),
-		httpCli: &http.Client{Timeout: 10 * time.Second},
+		httpCli: newHTTPClient(nil),
 		stopCh:  make(chan struct{}),
+		health:  system.RunHealthCheck,
+
+		cfgCh:    make(chan struct{}, 1),
+		chTiming: defaultChannelTiming,
+	}
+	for _, o := range opts {
+		o(c)
 	}
 
 	if err := c.loadConfig(); err != nil {
@@ -67,7 +159,8 @@ func NewClient(cfgPath, version string) (*Client, error) {
 	return c, nil
 }
 
-// loadConfig reads and parses the config.json file.
+// loadConfig reads and parses the config.json file. A config whose TLS
+// files cannot be loaded is rejected as a whole.
 func (c *Client) loadConfig() error {
 	data, err := os.ReadFile(c.cfgPath)
 	if err != nil {
@@ -79,33 +172,67 @@ func (c *Client) loadConfig() error {
 		return fmt.Errorf("parse config: %w", err)
 	}
 
-	if cfg.Interval <= 0 {
-		cfg.Interval = 60 // default 60s heartbeat
+	tc, err := tlsConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
 	}
 
 	c.mu.Lock()
-	c.cfg = cfg
+	c.cfg = withDefaults(cfg)
+	c.tls = tc
+	c.httpCli = newHTTPClient(tc)
 	c.mu.Unlock()
 
-	log.Printf("[api] loaded config: id=%s endpoint=%s interval=%ds", cfg.ID, cfg.Endpoint, cfg.Interval)
+	select {
+	case c.cfgCh <- struct{}{}:
+	default:
+	}
+
+	log.Printf("[api] loaded config: id=%s endpoint=%s interval=%ds mtls=%v", cfg.ID, cfg.Endpoint, cfg.Interval, tc != nil && tc.Certificates != nil)
 	return nil
 }
 
+// withDefaults fills in the intervals a config leaves unset.
+func withDefaults(cfg Config) Config {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 60 // default 60s heartbeat
+	}
+	if cfg.SyncInterval == 0 {
+		cfg.SyncInterval = 300 // default 5 min content sync
+	}
+	if cfg.PopInterval <= 0 {
+		cfg.PopInterval = 300 // default 5 min proof-of-play upload
+	}
+	return cfg
+}
+
 // ReloadConfig re-reads the config from disk. Safe to call at runtime.
 func (c *Client) ReloadConfig() error {
 	return c.loadConfig()
 }
 
+// SetDisplay records the display the player renders to, reported with
+// every heartbeat.
+func (c *Client) SetDisplay(d system.Display) {
+	c.mu.Lock()
+	c.display = &d
+	c.mu.Unlock()
+}
+
+// SetStateFunc sets the function that reports the player's state for
+// each heartbeat. It must be safe to call from the heartbeat goroutine.
+func (c *Client) SetStateFunc(fn func() PlayerState) {
+	c.mu.Lock()
+	c.state = fn
+	c.mu.Unlock()
+}
+
 // StartHeartbeat begins the periodic heartbeat loop.
-// It blocks until Stop() is called.
+// It blocks until Stop() is called. The interval is read from the
+// config on every tick, so one set by a claim or a reload takes effect
+// without a restart.
 func (c *Client) StartHeartbeat() {
-	c.mu.RLock()
-	interval := time.Duration(c.cfg.Interval) * time.Second
-	if interval == 0 {
-		interval = 60 * time.Second
-	}
-	c.mu.RUnlock()
-
+	interval := c.heartbeatInterval()
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -121,39 +248,48 @@ func (c *Client) StartHeartbeat() {
 			return
 		case <-ticker.C:
 			c.sendHeartbeat()
+			if d := c.heartbeatInterval(); d != interval {
+				interval = d
+				ticker.Reset(interval)
+				log.Printf("[api] heartbeat interval now %s", interval)
+			}
 		}
 	}
 }
 
+// heartbeatInterval returns the configured heartbeat interval.
+func (c *Client) heartbeatInterval() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cfg.Interval <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.cfg.Interval) * time.Second
+}
+
 // sendHeartbeat constructs and POSTs a heartbeat to the configured endpoint.
 func (c *Client) sendHeartbeat() {
 	c.mu.RLock()
 	cfg := c.cfg
 	c.mu.RUnlock()
-
 	if cfg.Endpoint == "" || cfg.ID == "" {
 		log.Println("[api] heartbeat skipped: missing endpoint or id")
 		return
 	}
 
-	hb := Heartbeat{
-		ID:        cfg.ID,
-		Key:       cfg.Key,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Uptime:    time.Since(c.startAt).Seconds(),
-		Version:   c.version,
-		Arch:      runtime.GOARCH,
-		OS:        runtime.GOOS,
-	}
-
+	hb := c.heartbeat(cfg)
 	body, err := json.Marshal(hb)
 	if err != nil {
 		log.Printf("[api] heartbeat marshal error: %v", err)
 		return
 	}
 
-	url := fmt.Sprintf("%s/heartbeat", cfg.Endpoint)
-	resp, err := c.httpCli.Post(url, "application/json", bytes.NewReader(body))
+	req, err := c.NewRequest(context.Background(), http.MethodPost, "/heartbeat", body)
+	if err != nil {
+		log.Printf("[api] heartbeat: %v", err)
+		return
+	}
+	resp, err := c.Do(req)
 	if err != nil {
 		log.Printf("[api] heartbeat POST failed: %v", err)
 		return
@@ -166,6 +302,121 @@ func (c *Client) sendHeartbeat() {
 	}
 
 	log.Printf("[api] heartbeat sent OK (%d)", resp.StatusCode)
+
+	c.mu.RLock()
+	cm := c.commander
+	c.mu.RUnlock()
+	if cm != nil && len(hb.CommandResults) > 0 {
+		ids := make([]string, len(hb.CommandResults))
+		for i, r := range hb.CommandResults {
+			ids[i] = r.ID
+		}
+		cm.Ack(ids)
+	}
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		log.Printf("[api] heartbeat response: %v", err)
+		return
+	}
+	c.dispatch(cm, reply, cfg.Key, req.Header.Get(HeaderNonce), resp.Header.Get(HeaderServerSignature))
+}
+
+// heartbeat gathers the heartbeat payload for cfg.
+func (c *Client) heartbeat(cfg Config) Heartbeat {
+	c.mu.RLock()
+	display, state, health, cm := c.display, c.state, c.health, c.commander
+	c.mu.RUnlock()
+
+	hb := Heartbeat{
+		Schema:    HeartbeatSchema,
+		ID:        cfg.ID,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Uptime:    time.Since(c.startAt).Seconds(),
+		Version:   c.version,
+		Arch:      runtime.GOARCH,
+		OS:        runtime.GOOS,
+		Display:   display,
+	}
+	if health != nil {
+		h := health()
+		hb.Health = &h
+	}
+	if state != nil {
+		st := state()
+		hb.Template = st.Template
+		hb.Zones = st.Zones
+		hb.LastError = st.LastError
+		if !st.LastErrorAt.IsZero() {
+			hb.LastErrorAt = st.LastErrorAt.UTC().Format(time.RFC3339)
+		}
+	}
+	if cm != nil {
+		hb.CommandResults = cm.Results()
+	}
+	if addrs, err := system.Addresses(); err == nil {
+		hb.Addresses = addrs
+	} else {
+		log.Printf("[api] heartbeat: addresses: %v", err)
+	}
+	return hb
+}
+
+// NewRequest builds a signed request for {endpoint}{path}. The body is
+// part of the signature, so it must not be changed afterwards.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	c.mu.RLock()
+	cfg := c.cfg
+	c.mu.RUnlock()
+
+	if cfg.Endpoint == "" || cfg.ID == "" {
+		return nil, ErrUnregistered
+	}
+	if err := c.checkScheme(cfg.Endpoint); err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	url := strings.TrimRight(cfg.Endpoint, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	signRequest(req, cfg.ID, cfg.Key, body, time.Now())
+	return req, nil
+}
+
+// checkScheme enforces WithRequireHTTPS for endpoint.
+func (c *Client) checkScheme(endpoint string) error {
+	if c.requireHTTPS && !strings.HasPrefix(strings.ToLower(endpoint), "https://") {
+		return fmt.Errorf("%w: %s", ErrInsecureEndpoint, endpoint)
+	}
+	return nil
+}
+
+// Do sends a request built by NewRequest.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	c.mu.RLock()
+	cli := c.httpCli
+	c.mu.RUnlock()
+	return cli.Do(req)
+}
+
+// TLSConfig returns a copy of the TLS settings from the config, or nil
+// if it sets none, for other HTTP clients that talk to the endpoint.
+func (c *Client) TLSConfig() *tls.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.tls == nil {
+		return nil
+	}
+	return c.tls.Clone()
 }
 
 // GetConfig returns the current configuration (thread-safe).
@@ -175,7 +426,7 @@ func (c *Client) GetConfig() Config {
 	return c.cfg
 }
 
-// Stop halts the heartbeat loop.
+// Stop halts the heartbeat loop and the real-time channel.
 func (c *Client) Stop() {
 	close(c.stopCh)
 }
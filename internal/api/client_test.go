@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"player-native/internal/system"
+)
+
+// TestHeartbeatPayload: a heartbeat carries the schema, the health
+// check, the player state from SetStateFunc and the addresses.
+func TestHeartbeatPayload(t *testing.T) {
+	got := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- body
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(writeConfig(t, Config{ID: "p1", Key: "k", Endpoint: srv.URL}), "1.2.3")
+	health := system.HealthStatus{DiskUsedPct: 42.5, DiskFreeBytes: 1 << 30, CPUTempC: 61, Throttled: true}
+	c.health = func() system.HealthStatus { return health }
+	failedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	zones := []ZoneReport{
+		{ID: "main", State: "playing", Current: "/media/main/a.mp4", Items: 3, PlaylistHash: "abc"},
+		{ID: "ticker", State: "error", Items: 0, Error: "no items"},
+	}
+	c.SetStateFunc(func() PlayerState {
+		return PlayerState{Template: "split", Zones: zones, LastError: "zone ticker: no items", LastErrorAt: failedAt}
+	})
+
+	c.sendHeartbeat()
+	var body []byte
+	select {
+	case body = <-got:
+	default:
+		t.Fatal("no heartbeat received")
+	}
+
+	var hb Heartbeat
+	if err := json.Unmarshal(body, &hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.Schema != HeartbeatSchema || hb.Schema < 2 || hb.ID != "p1" || hb.Version != "1.2.3" {
+		t.Errorf("identity: %+v", hb)
+	}
+	if hb.Health == nil || hb.Health.DiskUsedPct != 42.5 || hb.Health.CPUTempC != 61 || !hb.Health.Throttled {
+		t.Errorf("health: %+v", hb.Health)
+	}
+	if hb.Template != "split" || !reflect.DeepEqual(hb.Zones, zones) {
+		t.Errorf("state: template %q, zones %+v", hb.Template, hb.Zones)
+	}
+	if hb.LastError != "zone ticker: no items" || hb.LastErrorAt != "2026-03-01T12:00:00Z" {
+		t.Errorf("last error: %q at %q", hb.LastError, hb.LastErrorAt)
+	}
+	want, err := system.Addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hb.Addresses, want) {
+		t.Errorf("addresses: got %+v, want %+v", hb.Addresses, want)
+	}
+
+	// The wire names are what servers read.
+	var raw map[string]json.RawMessage
+	json.Unmarshal(body, &raw)
+	for _, key := range []string{"schema", "health", "zones", "last_error", "last_error_at"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("missing %q in %s", key, body)
+		}
+	}
+}
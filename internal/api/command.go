@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// Command is an instruction from the server, delivered in a heartbeat
+// response. The ID makes it idempotent: a player runs each ID at most
+// once, however often the server repeats it.
+type Command struct {
+	ID   string          `json:"id"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// Command result statuses.
+const (
+	CommandOK       = "ok"
+	CommandFailed   = "failed"
+	CommandRejected = "rejected" // not allowed by the config, or unknown
+)
+
+// CommandResult reports the outcome of a command in a later heartbeat.
+type CommandResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+	At     string `json:"at"` // RFC 3339
+}
+
+// heartbeatResponse is the (optional) body of a heartbeat response.
+type heartbeatResponse struct {
+	Commands []Command `json:"commands"`
+}
+
+// Commander runs server commands and keeps their results until a
+// heartbeat has delivered them.
+type Commander interface {
+	// Dispatch queues commands from a heartbeat response. It must not
+	// block.
+	Dispatch(cmds []Command)
+	// Results returns the results the server has not yet received.
+	Results() []CommandResult
+	// Ack drops the results with the given IDs once the server has
+	// accepted a heartbeat carrying them.
+	Ack(ids []string)
+}
+
+// SetCommander sets what runs commands from heartbeat responses.
+// Without one, commands are ignored.
+func (c *Client) SetCommander(cm Commander) {
+	c.mu.Lock()
+	c.commander = cm
+	c.mu.Unlock()
+}
+
+// UploadScreenshot sends a PNG screenshot taken for a command to
+// {endpoint}/screenshot.
+func (c *Client) UploadScreenshot(ctx context.Context, commandID string, png []byte) error {
+	req, err := c.NewRequest(ctx, http.MethodPost, "/screenshot?command_id="+url.QueryEscape(commandID), png)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "image/png")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("screenshot upload: server returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// dispatch hands the commands in a heartbeat response body to the
+// commander. An empty body carries none. The body must be signed for
+// the heartbeat request's nonce (see SignResponse); otherwise anyone
+// between the player and a plain-http endpoint could inject commands.
+func (c *Client) dispatch(cm Commander, body []byte, key, nonce, sig string) {
+	if cm == nil || len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+	var hr heartbeatResponse
+	if err := json.Unmarshal(body, &hr); err != nil {
+		log.Printf("[api] heartbeat response: %v", err)
+		return
+	}
+	if len(hr.Commands) == 0 {
+		return
+	}
+	if err := verifyResponse(key, nonce, sig, body); err != nil {
+		log.Printf("[api] heartbeat response: %v, %d command(s) ignored", err, len(hr.Commands))
+		return
+	}
+	cm.Dispatch(hr.Commands)
+}
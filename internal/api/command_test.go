@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+type fakeCommander struct {
+	got     []Command
+	results []CommandResult
+	acked   []string
+}
+
+func (f *fakeCommander) Dispatch(cmds []Command)  { f.got = append(f.got, cmds...) }
+func (f *fakeCommander) Results() []CommandResult { return f.results }
+func (f *fakeCommander) Ack(ids []string)         { f.acked = append(f.acked, ids...) }
+
+// TestHeartbeatCommands: signed commands in a heartbeat response reach
+// the commander, and results are acknowledged once a heartbeat carrying
+// them was accepted.
+func TestHeartbeatCommands(t *testing.T) {
+	var sent []Heartbeat
+	status := http.StatusOK
+	signKey := "k"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hb Heartbeat
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &hb)
+		sent = append(sent, hb)
+		reply := []byte(`{"commands":[{"id":"c1","name":"set_volume","args":{"volume":50}}]}`)
+		if signKey != "" {
+			w.Header().Set(HeaderServerSignature, SignResponse(signKey, r.Header.Get(HeaderNonce), reply))
+		}
+		w.WriteHeader(status)
+		w.Write(reply)
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(writeConfig(t, Config{ID: "p1", Key: "k", Endpoint: srv.URL}), "test")
+	cm := &fakeCommander{results: []CommandResult{{ID: "c0", Name: "reboot", Status: CommandOK}}}
+	c.SetCommander(cm)
+
+	// A rejected heartbeat neither delivers nor acknowledges anything.
+	status = http.StatusInternalServerError
+	c.sendHeartbeat()
+	if len(cm.got) != 0 || len(cm.acked) != 0 {
+		t.Fatalf("failed heartbeat dispatched %v, acked %v", cm.got, cm.acked)
+	}
+
+	// Unsigned or forged commands are ignored.
+	status = http.StatusOK
+	for _, signKey = range []string{"", "not-the-key"} {
+		c.sendHeartbeat()
+		if len(cm.got) != 0 {
+			t.Fatalf("commands signed with %q dispatched: %v", signKey, cm.got)
+		}
+	}
+
+	signKey = "k"
+	cm.acked = nil
+	c.sendHeartbeat()
+	if len(sent) != 4 || sent[3].Schema != HeartbeatSchema || len(sent[3].CommandResults) != 1 {
+		t.Fatalf("expected the result in a schema %d heartbeat, got %+v", HeartbeatSchema, sent)
+	}
+	if !slices.Equal(cm.acked, []string{"c0"}) {
+		t.Errorf("acked %v, want [c0]", cm.acked)
+	}
+	if len(cm.got) != 1 || cm.got[0].ID != "c1" || string(cm.got[0].Args) != `{"volume":50}` {
+		t.Errorf("dispatched %+v", cm.got)
+	}
+}
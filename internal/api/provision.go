@@ -0,0 +1,257 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"player-native/internal/system"
+)
+
+// Provisioning timing. The server may ask for a different poll interval;
+// failed requests are retried with backoff up to maxRegisterBackoff.
+const (
+	DefaultRegisterPoll = 5 * time.Second
+	maxRegisterBackoff  = time.Minute
+)
+
+// ProvisionSecretFile is the file, next to config.json, that holds the
+// install's provisioning secret.
+const ProvisionSecretFile = "provision.secret"
+
+// registerRequest is the body POSTed to {endpoint}/register. Secret is
+// random per install: the server binds it to the hardware ID on the
+// first request and hands the claimed key only to requests that carry
+// it, so knowing the (displayed) hardware ID is not enough.
+type registerRequest struct {
+	HardwareID string          `json:"hardware_id"`
+	Secret     string          `json:"secret"`
+	Version    string          `json:"version"`
+	Arch       string          `json:"arch"`
+	OS         string          `json:"os"`
+	Display    *system.Display `json:"display,omitempty"`
+}
+
+// Registration is the server's answer to a register request. Until an
+// admin claims the device it carries the pairing code to show; once
+// claimed it carries the player's identity.
+type Registration struct {
+	PairingCode string `json:"pairing_code"`
+	PollSec     int    `json:"poll_interval_sec,omitempty"`
+	Claimed     bool   `json:"claimed"`
+	ID          string `json:"id,omitempty"`
+	Key         string `json:"key,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// Registered reports whether the player has an endpoint and ID.
+func (c *Client) Registered() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cfg.Endpoint != "" && c.cfg.ID != ""
+}
+
+// Register announces the device to endpoint under its hardware ID. The
+// server answers with the same pairing code for repeated requests until
+// the code expires or the device is claimed.
+func (c *Client) Register(ctx context.Context, endpoint, hwid string) (Registration, error) {
+	c.mu.RLock()
+	display := c.display
+	c.mu.RUnlock()
+
+	secret, err := c.provisionSecret()
+	if err != nil {
+		return Registration{}, err
+	}
+	body, err := json.Marshal(registerRequest{
+		HardwareID: hwid,
+		Secret:     secret,
+		Version:    c.version,
+		Arch:       runtime.GOARCH,
+		OS:         runtime.GOOS,
+		Display:    display,
+	})
+	if err != nil {
+		return Registration{}, err
+	}
+
+	if err := c.checkScheme(endpoint); err != nil {
+		return Registration{}, err
+	}
+	url := strings.TrimRight(endpoint, "/") + "/register"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Registration{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return Registration{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Registration{}, fmt.Errorf("register: server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var reg Registration
+	if err := json.NewDecoder(resp.Body).Decode(&reg); err != nil {
+		return Registration{}, fmt.Errorf("register: parse response: %w", err)
+	}
+	if reg.Claimed && (reg.ID == "" || reg.Key == "") {
+		return Registration{}, errors.New("register: claimed without an id and key")
+	}
+	if !reg.Claimed && reg.PairingCode == "" {
+		return Registration{}, errors.New("register: no pairing code")
+	}
+	return reg, nil
+}
+
+// Provision registers the device at endpoint and polls until an admin
+// claims it, calling onCode whenever a new pairing code is issued. The
+// claimed identity and endpoint are then written to the config file and
+// loaded. It returns early only if ctx is cancelled or the endpoint is
+// refused by WithRequireHTTPS.
+func (c *Client) Provision(ctx context.Context, endpoint, hwid string, onCode func(code string)) error {
+	log.Printf("[api] provisioning as %s via %s", hwid, endpoint)
+
+	var code string
+	backoff := DefaultRegisterPoll
+	for {
+		reg, err := c.Register(ctx, endpoint, hwid)
+		wait := DefaultRegisterPoll
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, ErrInsecureEndpoint) {
+				return err
+			}
+			log.Printf("[api] %v (retry in %s)", err, backoff)
+			wait = backoff
+			backoff = min(backoff*2, maxRegisterBackoff)
+		case reg.Claimed:
+			if err := c.saveIdentity(endpoint, reg); err != nil {
+				return err
+			}
+			log.Printf("[api] claimed as %s", reg.ID)
+			return c.loadConfig()
+		default:
+			backoff = DefaultRegisterPoll
+			if reg.PollSec > 0 {
+				wait = time.Duration(reg.PollSec) * time.Second
+			}
+			if reg.PairingCode != code {
+				code = reg.PairingCode
+				log.Printf("[api] pairing code %s", code)
+				onCode(code)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// provisionSecret returns the install's provisioning secret, creating
+// it on first use.
+func (c *Client) provisionSecret() (string, error) {
+	path := filepath.Join(filepath.Dir(c.cfgPath), ProvisionSecretFile)
+	data, err := os.ReadFile(path)
+	if err == nil && len(bytes.TrimSpace(data)) > 0 {
+		return string(bytes.TrimSpace(data)), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("provisioning secret: %w", err)
+	}
+
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("provisioning secret: %w", err)
+	}
+	secret := hex.EncodeToString(b[:])
+	if err := writeFileAtomic(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("provisioning secret: %w", err)
+	}
+	return secret, nil
+}
+
+// saveIdentity adds the claimed identity to the config file, keeping
+// any other settings it already has, and replaces the file atomically
+// so that a crash never leaves a truncated config behind.
+func (c *Client) saveIdentity(endpoint string, reg Registration) error {
+	// Merge into the raw fields, so keys Config does not know survive.
+	fields := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(c.cfgPath); err == nil {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("parse config: %w", err)
+		}
+	}
+	set := func(key, value string) {
+		fields[key], _ = json.Marshal(value)
+	}
+	set("id", reg.ID)
+	set("key", reg.Key)
+	if reg.Name != "" {
+		set("name", reg.Name)
+	}
+	var cur string
+	if json.Unmarshal(fields["endpoint"], &cur) != nil || cur == "" {
+		set("endpoint", endpoint)
+	}
+
+	data, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.cfgPath, append(data, '\n'), 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and
+// renames it into place.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestProvision: the player shows the pairing code until the server
+// reports it claimed, sending the same install secret each time, then
+// writes the identity into config.json while keeping the settings
+// already there, including keys the player does not know.
+func TestProvision(t *testing.T) {
+	polls := 0
+	var secret string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req registerRequest
+		if r.URL.Path != "/register" || json.NewDecoder(r.Body).Decode(&req) != nil || req.HardwareID != "rpi-abc" || len(req.Secret) < 32 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// The first request binds the secret; only it may claim.
+		if secret == "" {
+			secret = req.Secret
+		}
+		if req.Secret != secret {
+			http.Error(w, "wrong secret", http.StatusForbidden)
+			return
+		}
+		polls++
+		if polls < 3 {
+			json.NewEncoder(w).Encode(Registration{PairingCode: "7KQ2XD", PollSec: 1})
+			return
+		}
+		json.NewEncoder(w).Encode(Registration{Claimed: true, ID: "player-001", Key: "secret", Name: "Lobby"})
+	}))
+	defer srv.Close()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(cfgPath, []byte(`{"heartbeat_interval_sec": 30, "legacy_display": {"rotate": 90}}`), 0600)
+	c, _ := NewClient(cfgPath, "test")
+	if c.Registered() {
+		t.Fatal("client without an id reports registered")
+	}
+
+	var codes []string
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.Provision(ctx, srv.URL, "rpi-abc", func(code string) { codes = append(codes, code) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != 1 || codes[0] != "7KQ2XD" {
+		t.Errorf("expected the code to be shown once, got %v", codes)
+	}
+	if !c.Registered() {
+		t.Error("client not registered after the claim")
+	}
+	cfg := c.GetConfig()
+	if cfg.ID != "player-001" || cfg.Key != "secret" || cfg.Endpoint != srv.URL || cfg.Interval != 30 {
+		t.Errorf("unexpected config after the claim: %+v", cfg)
+	}
+	if info, err := os.Stat(cfgPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("config not written with mode 0600: %v %v", info, err)
+	}
+	var legacy struct {
+		Display struct{ Rotate int } `json:"legacy_display"`
+	}
+	data, _ := os.ReadFile(cfgPath)
+	if json.Unmarshal(data, &legacy) != nil || legacy.Display.Rotate != 90 {
+		t.Errorf("unknown key lost from config: %s", data)
+	}
+
+	// The secret is kept for the install, readable only by the player.
+	secretPath := filepath.Join(filepath.Dir(cfgPath), ProvisionSecretFile)
+	if info, err := os.Stat(secretPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("secret not written with mode 0600: %v %v", info, err)
+	}
+	if again, err := c.provisionSecret(); err != nil || again != secret {
+		t.Errorf("secret changed after provisioning: %q, %v", again, err)
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, cfg Config) string {
+	t.Helper()
+	data, _ := json.Marshal(cfg)
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestSignedHeartbeat: heartbeats carry a verifiable signature and never
+// the key itself, and an altered, stale or nonce-less request fails
+// verification.
+func TestSignedHeartbeat(t *testing.T) {
+	got := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := Verify(r, body, "secret", time.Now())
+		if err == nil && strings.Contains(string(body)+fmt.Sprint(r.Header), "secret") {
+			err = errors.New("key sent on the wire")
+		}
+		if err == nil && Verify(r, append(body, ' '), "secret", time.Now()) == nil {
+			err = errors.New("altered body verified")
+		}
+		if err == nil && Verify(r, body, "secret", time.Now().Add(2*MaxSkew)) != ErrStale {
+			err = errors.New("stale request verified")
+		}
+		if err == nil {
+			bare := r.Clone(r.Context())
+			bare.Header.Del(HeaderNonce)
+			bare.Header.Set(HeaderSignature, Sign("secret", r.Method, r.URL.RequestURI(), r.Header.Get(HeaderTimestamp), "", body))
+			if Verify(bare, body, "secret", time.Now()) != ErrBadSignature {
+				err = errors.New("request without a nonce verified")
+			}
+		}
+		got <- err
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(writeConfig(t, Config{ID: "p1", Key: "secret", Endpoint: srv.URL}), "test")
+	c.sendHeartbeat()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatal("no heartbeat received")
+	}
+}
+
+// TestRequireHTTPS: with WithRequireHTTPS a plain-HTTP endpoint is
+// refused before anything is sent.
+func TestRequireHTTPS(t *testing.T) {
+	c, _ := NewClient(writeConfig(t, Config{ID: "p1", Key: "k", Endpoint: "http://api.example.com"}), "test", WithRequireHTTPS())
+	if _, err := c.NewRequest(context.Background(), http.MethodGet, "/content", nil); !errors.Is(err, ErrInsecureEndpoint) {
+		t.Errorf("expected ErrInsecureEndpoint, got %v", err)
+	}
+	if _, err := c.Register(context.Background(), "http://api.example.com", "rpi-1"); !errors.Is(err, ErrInsecureEndpoint) {
+		t.Errorf("expected ErrInsecureEndpoint from Register, got %v", err)
+	}
+}
+
+// TestMutualTLS: the client trusts a private server CA from the bundle
+// and presents its certificate to a server that requires one.
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM := selfSigned(t)
+	os.WriteFile(filepath.Join(dir, "client.crt"), certPEM, 0600)
+	os.WriteFile(filepath.Join(dir, "client.key"), keyPEM, 0600)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(certPEM)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	os.WriteFile(filepath.Join(dir, "ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+
+	cfg := Config{ID: "p1", Key: "k", Endpoint: srv.URL, CABundle: filepath.Join(dir, "ca.pem")}
+	c, _ := NewClient(writeConfig(t, cfg), "test", WithRequireHTTPS())
+	req, _ := c.NewRequest(context.Background(), http.MethodGet, "/content", nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("server accepted a client without a certificate")
+	}
+
+	cfg.ClientCert, cfg.ClientKey = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	c, _ = NewClient(writeConfig(t, cfg), "test", WithRequireHTTPS())
+	req, _ = c.NewRequest(context.Background(), http.MethodGet, "/content", nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+// selfSigned returns a PEM certificate and key for client authentication.
+func selfSigned(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "p1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrInsecureEndpoint is returned for requests to a plain-HTTP endpoint
+// when the client was created WithRequireHTTPS.
+var ErrInsecureEndpoint = errors.New("endpoint is not https")
+
+// tlsConfig builds the TLS settings for cfg: a client certificate for
+// mutual TLS and a CA bundle trusted in addition to the system roots.
+// It returns nil when cfg uses neither.
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	if cfg.ClientCert == "" && cfg.ClientKey == "" && cfg.CABundle == "" {
+		return nil, nil
+	}
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, errors.New("tls_client_cert and tls_client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("ca bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca bundle %s: no certificates found", cfg.CABundle)
+		}
+		tc.RootCAs = pool
+	}
+	return tc, nil
+}
+
+// newHTTPClient returns the client for API requests, using tc when it
+// is not nil.
+func newHTTPClient(tc *tls.Config) *http.Client {
+	cli := &http.Client{Timeout: 10 * time.Second}
+	if tc != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tc
+		cli.Transport = t
+	}
+	return cli
+}
@@ -0,0 +1,285 @@
+// Package command runs the management commands a server sends in
+// heartbeat responses. Each command ID runs at most once, only commands
+// the config allows are run, and results are kept on disk until a
+// heartbeat has delivered them, so a reboot does not lose them.
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"player-native/internal/api"
+)
+
+// Limits. maxSeen bounds the remembered command IDs; a server must not
+// repeat an ID older than the last maxSeen commands.
+const (
+	maxSeen        = 256
+	queueSize      = 32
+	DefaultTimeout = 2 * time.Minute
+)
+
+// errStopped is the result of a queued command that never ran because
+// the player stopped first.
+var errStopped = errors.New("player stopped before the command ran")
+
+// Handler runs a command and returns its output.
+type Handler func(ctx context.Context, cmd api.Command) (string, error)
+
+type handler struct {
+	run  Handler
+	then func() // after a successful result is saved
+}
+
+// state is what the dispatcher keeps on disk.
+type state struct {
+	Seen    []string            `json:"seen"` // oldest first
+	Results []api.CommandResult `json:"results"`
+}
+
+// Dispatcher queues commands and runs them one at a time. It
+// implements api.Commander.
+type Dispatcher struct {
+	path     string
+	allowed  func() []string
+	handlers map[string]handler
+	timeout  time.Duration
+	now      func() time.Time
+
+	queue  chan api.Command
+	stopCh chan struct{}
+
+	mu      sync.Mutex
+	state   state
+	stopped bool
+}
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithTimeout bounds how long a single command may run.
+func WithTimeout(d time.Duration) Option {
+	return func(dp *Dispatcher) { dp.timeout = d }
+}
+
+// New creates a dispatcher that keeps its state in statePath and runs
+// only the commands named by allowed, which is consulted for every
+// command so that a config reload takes effect at once. "*" allows all.
+func New(statePath string, allowed func() []string, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		path:     statePath,
+		allowed:  allowed,
+		handlers: make(map[string]handler),
+		timeout:  DefaultTimeout,
+		now:      time.Now,
+		queue:    make(chan api.Command, queueSize),
+		stopCh:   make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(d)
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err == nil {
+		err = json.Unmarshal(data, &d.state)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[command] state %s unreadable, starting fresh: %v", statePath, err)
+	}
+	return d
+}
+
+// Handle registers the handler for a command name.
+func (d *Dispatcher) Handle(name string, h Handler) {
+	d.handlers[name] = handler{run: h}
+}
+
+// HandleFinal registers a handler for a command that ends the process,
+// such as a reboot: then is called only after the handler succeeded and
+// its result is saved, so that the result is reported after the restart.
+func (d *Dispatcher) HandleFinal(name string, h Handler, then func()) {
+	d.handlers[name] = handler{run: h, then: then}
+}
+
+// Dispatch queues new commands. Repeated IDs are ignored; commands that
+// are not allowed or not known are rejected without running. Once Stop
+// was called nothing is queued or marked seen, so the server repeats
+// the commands after the restart.
+func (d *Dispatcher) Dispatch(cmds []api.Command) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		log.Printf("[command] stopping, %d command(s) deferred", len(cmds))
+		return
+	}
+
+	for _, cmd := range cmds {
+		if cmd.ID == "" {
+			log.Printf("[command] %q without an id ignored", cmd.Name)
+			continue
+		}
+		if slices.Contains(d.state.Seen, cmd.ID) {
+			continue
+		}
+
+		_, known := d.handlers[cmd.Name]
+		switch {
+		case !d.isAllowed(cmd.Name):
+			d.markSeen(cmd.ID)
+			d.addResult(cmd, "", fmt.Errorf("%q is not in allowed_commands", cmd.Name), api.CommandRejected)
+		case !known:
+			d.markSeen(cmd.ID)
+			d.addResult(cmd, "", fmt.Errorf("unknown command %q", cmd.Name), api.CommandRejected)
+		default:
+			select {
+			case d.queue <- cmd:
+				d.markSeen(cmd.ID)
+				log.Printf("[command] queued %s (%s)", cmd.Name, cmd.ID)
+			default:
+				// Not marked seen: the server repeats it until it runs.
+				log.Printf("[command] queue full, %s (%s) deferred", cmd.Name, cmd.ID)
+			}
+		}
+	}
+	d.saveLocked()
+}
+
+// Results returns the results not yet acknowledged, oldest first.
+func (d *Dispatcher) Results() []api.CommandResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.state.Results)
+}
+
+// Ack drops the results with the given IDs.
+func (d *Dispatcher) Ack(ids []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state.Results = slices.DeleteFunc(d.state.Results, func(r api.CommandResult) bool {
+		return slices.Contains(ids, r.ID)
+	})
+	d.saveLocked()
+}
+
+// Start runs queued commands until Stop is called.
+func (d *Dispatcher) Start() {
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case cmd := <-d.queue:
+			d.mu.Lock()
+			stopped := d.stopped
+			if stopped {
+				d.dropLocked(cmd)
+				d.saveLocked()
+			}
+			d.mu.Unlock()
+			if !stopped {
+				d.run(cmd)
+			}
+		}
+	}
+}
+
+// Stop ends the Start loop. A running command finishes; queued commands
+// that have not started fail with errStopped, so that the server learns
+// of them, as they are remembered as seen and will not run again.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	d.stopped = true
+	close(d.stopCh)
+
+	for {
+		select {
+		case cmd := <-d.queue:
+			d.dropLocked(cmd)
+		default:
+			d.saveLocked()
+			return
+		}
+	}
+}
+
+// dropLocked records that a queued command will not run.
+func (d *Dispatcher) dropLocked(cmd api.Command) {
+	log.Printf("[command] %s (%s) dropped: %v", cmd.Name, cmd.ID, errStopped)
+	d.addResult(cmd, "", errStopped, api.CommandFailed)
+}
+
+func (d *Dispatcher) run(cmd api.Command) {
+	h := d.handlers[cmd.Name]
+	log.Printf("[command] running %s (%s)", cmd.Name, cmd.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	out, err := h.run(ctx, cmd)
+	cancel()
+
+	status := api.CommandOK
+	if err != nil {
+		status = api.CommandFailed
+		log.Printf("[command] %s (%s) failed: %v", cmd.Name, cmd.ID, err)
+	}
+	d.mu.Lock()
+	d.addResult(cmd, out, err, status)
+	d.saveLocked()
+	d.mu.Unlock()
+
+	if err == nil && h.then != nil {
+		h.then()
+	}
+}
+
+func (d *Dispatcher) isAllowed(name string) bool {
+	allowed := d.allowed()
+	return slices.Contains(allowed, "*") || slices.Contains(allowed, name)
+}
+
+func (d *Dispatcher) markSeen(id string) {
+	d.state.Seen = append(d.state.Seen, id)
+	if n := len(d.state.Seen) - maxSeen; n > 0 {
+		d.state.Seen = slices.Delete(d.state.Seen, 0, n)
+	}
+}
+
+func (d *Dispatcher) addResult(cmd api.Command, out string, err error, status string) {
+	r := api.CommandResult{
+		ID:     cmd.ID,
+		Name:   cmd.Name,
+		Status: status,
+		Output: out,
+		At:     d.now().UTC().Format(time.RFC3339),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	d.state.Results = append(d.state.Results, r)
+}
+
+// saveLocked writes the state file atomically. Failing to save is
+// logged: the commands still run, but a restart may repeat them.
+func (d *Dispatcher) saveLocked() {
+	data, err := json.Marshal(d.state)
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(d.path), "."+filepath.Base(d.path)+".tmp")
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, d.path)
+		}
+	}
+	if err != nil {
+		log.Printf("[command] save state: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"player-native/internal/api"
+)
+
+// waitResults polls until d has n results.
+func waitResults(t *testing.T, d *Dispatcher, n int) []api.CommandResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if r := d.Results(); len(r) >= n {
+			return r
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d result(s), got %+v", n, d.Results())
+	return nil
+}
+
+// TestDispatch: commands run once per ID, only if allowed, and results
+// survive a restart until acknowledged.
+func TestDispatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json")
+	allowed := []string{"set_volume", "reboot", "unknown_but_allowed"}
+
+	runs := 0
+	rebooted := make(chan struct{})
+	d := New(path, func() []string { return allowed })
+	d.Handle("set_volume", func(ctx context.Context, cmd api.Command) (string, error) {
+		runs++
+		if string(cmd.Args) == `{"volume":999}` {
+			return "", errors.New("volume out of range")
+		}
+		return "volume set", nil
+	})
+	d.HandleFinal("reboot", func(ctx context.Context, cmd api.Command) (string, error) {
+		return "rebooting", nil
+	}, func() {
+		if len(d.Results()) != 5 {
+			t.Error("reboot ran before its result was saved")
+		}
+		close(rebooted)
+	})
+	go d.Start()
+	defer d.Stop()
+
+	d.Dispatch([]api.Command{
+		{ID: "1", Name: "set_volume", Args: []byte(`{"volume":80}`)},
+		{ID: "1", Name: "set_volume", Args: []byte(`{"volume":80}`)},
+		{ID: "2", Name: "set_volume", Args: []byte(`{"volume":999}`)},
+		{ID: "3", Name: "purge_zone"},
+		{ID: "4", Name: "unknown_but_allowed"},
+	})
+	waitResults(t, d, 4)
+	d.Dispatch([]api.Command{{ID: "1", Name: "set_volume"}, {ID: "5", Name: "reboot"}})
+	select {
+	case <-rebooted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reboot not run")
+	}
+
+	want := map[string]string{"1": api.CommandOK, "2": api.CommandFailed, "3": api.CommandRejected, "4": api.CommandRejected, "5": api.CommandOK}
+	got := d.Results()
+	if len(got) != len(want) || runs != 2 {
+		t.Fatalf("expected 5 results from 2 volume runs, got %d runs: %+v", runs, got)
+	}
+	for _, r := range got {
+		if r.Status != want[r.ID] {
+			t.Errorf("command %s: status %s, want %s (%s)", r.ID, r.Status, want[r.ID], r.Error)
+		}
+	}
+
+	// A new dispatcher (after the reboot) still has the results and
+	// still ignores the IDs it ran.
+	d.Ack([]string{"1", "2", "3", "4"})
+	d2 := New(path, func() []string { return allowed })
+	if r := d2.Results(); len(r) != 1 || r[0].ID != "5" {
+		t.Fatalf("expected the reboot result after a restart, got %+v", r)
+	}
+	d2.Dispatch([]api.Command{{ID: "5", Name: "reboot"}})
+	if len(d2.queue) != 0 {
+		t.Error("a command was queued again after a restart")
+	}
+}
+
+// TestStop: commands still queued at Stop fail rather than vanish, and
+// commands dispatched afterwards are left for the server to repeat.
+func TestStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json")
+	started, release := make(chan struct{}), make(chan struct{})
+	d := New(path, func() []string { return []string{"*"} })
+	d.Handle("wait", func(ctx context.Context, cmd api.Command) (string, error) {
+		close(started)
+		<-release
+		return "done", nil
+	})
+	noop := func(ctx context.Context, cmd api.Command) (string, error) { return "", nil }
+	d.Handle("noop", noop)
+	go d.Start()
+
+	d.Dispatch([]api.Command{{ID: "1", Name: "wait"}, {ID: "2", Name: "noop"}, {ID: "3", Name: "noop"}})
+	<-started
+	d.Stop()
+	close(release)
+	d.Dispatch([]api.Command{{ID: "4", Name: "noop"}})
+
+	want := map[string]string{"1": api.CommandOK, "2": api.CommandFailed, "3": api.CommandFailed}
+	got := waitResults(t, d, 3)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), got)
+	}
+	for _, r := range got {
+		if r.Status != want[r.ID] {
+			t.Errorf("command %s: status %s, want %s (%s)", r.ID, r.Status, want[r.ID], r.Error)
+		}
+	}
+
+	d2 := New(path, func() []string { return []string{"*"} })
+	d2.Handle("noop", noop)
+	d2.Dispatch([]api.Command{{ID: "4", Name: "noop"}})
+	if len(d2.queue) != 1 {
+		t.Error("a command dispatched after Stop was not queued after a restart")
+	}
+}
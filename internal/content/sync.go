@@ -0,0 +1,691 @@
+// Package content synchronizes zone playlist directories with a
+// server-side content manifest. Files are downloaded into a staging
+// area (resuming partial downloads), verified against their SHA-256
+// checksum, and only then moved into place, so playlist watchers never
+// see incomplete files.
+package content
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"player-native/internal/api"
+	"player-native/internal/media"
+)
+
+// Manifest is the server's description of the content each zone
+// should hold.
+//
+//	{
+//	  "version": "2026-10-01T12:00:00Z",
+//	  "files": [
+//	    {"zone": "main", "name": "promo.mp4", "url": "https://cdn.example.com/promo.mp4", "sha256": "…"},
+//	    {"zone": "main/breakfast", "name": "menu.jpg", "url": "/media/menu.jpg", "sha256": "…"}
+//	  ]
+//	}
+//
+// Zone is a sync target key (see Syncer); relative URLs are resolved
+// against the API endpoint.
+type Manifest struct {
+	Version string `json:"version"`
+	Files   []File `json:"files"`
+}
+
+// File is one entry in the content manifest.
+type File struct {
+	Zone   string `json:"zone"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Result summarizes a sync pass.
+type Result struct {
+	Version    string `json:"version"`
+	Downloaded int    `json:"downloaded"`
+	Placed     int    `json:"placed"`
+	Unchanged  int    `json:"unchanged"`
+	Removed    int    `json:"removed"`
+	Failed     int    `json:"failed"`
+}
+
+// fileHash caches the checksum of a placed file so unchanged files are
+// not re-hashed on every pass.
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// Syncer polls the content manifest and applies it to local
+// directories. Targets maps manifest zone keys to directories.
+type Syncer struct {
+	api         *api.Client
+	httpCli     *http.Client  // for downloads; rebuilt each pass, see Sync
+	idleTimeout time.Duration // cancels a download that stops receiving
+	stagingDir  string
+	targets     map[string]string
+
+	mu      sync.Mutex // serializes sync passes
+	hashes  map[string]fileHash
+	managed map[string]bool // files the syncer placed; only these are pruned
+	etag    string
+
+	pendingMu sync.Mutex
+	pending   map[string]string // targets to use from the next pass
+	refresh   bool              // ignore the cached manifest next pass
+
+	triggerCh chan struct{}
+	stopCh    chan struct{}
+}
+
+// NewSyncer creates a syncer that stages downloads in stagingDir and
+// writes into the directories named by targets.
+func NewSyncer(client *api.Client, stagingDir string, targets map[string]string) *Syncer {
+	return &Syncer{
+		api:         client,
+		idleTimeout: downloadIdleTimeout,
+		stagingDir:  stagingDir,
+		targets:     targets,
+		hashes:      make(map[string]fileHash),
+		managed:     loadManaged(stagingDir),
+		triggerCh:   make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// newHTTPClient returns the client for downloads, with the TLS settings
+// the API client has now: a CA bundle or client certificate changed by
+// a config reload applies from the next pass.
+func (s *Syncer) newHTTPClient() *http.Client {
+	// No overall timeout: 4K files can take a long time to download.
+	// A stall after the headers is caught by the idle watchdog in fetch.
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 30 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+			TLSClientConfig:       s.api.TLSConfig(), // files served by the endpoint itself
+		},
+	}
+}
+
+// downloadIdleTimeout is how long a download may receive nothing
+// before it is cancelled and left for the next pass to resume.
+const downloadIdleTimeout = 60 * time.Second
+
+// managedFile lists, in the staging directory, the files the syncer
+// placed, so that a restart does not forget what it may prune.
+const managedFile = "managed.json"
+
+func loadManaged(stagingDir string) map[string]bool {
+	managed := make(map[string]bool)
+	data, err := os.ReadFile(filepath.Join(stagingDir, managedFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[sync] %s: %v", managedFile, err)
+		}
+		return managed
+	}
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		log.Printf("[sync] %s: %v", managedFile, err)
+	}
+	for _, p := range paths {
+		managed[p] = true
+	}
+	return managed
+}
+
+// saveManaged writes the managed file list atomically.
+func (s *Syncer) saveManaged() {
+	paths := make([]string, 0, len(s.managed))
+	for p := range s.managed {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	data, _ := json.Marshal(paths)
+
+	path := filepath.Join(s.stagingDir, managedFile)
+	tmp := path + ".tmp"
+	err := os.WriteFile(tmp, data, 0644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.Printf("[sync] save %s: %v", managedFile, err)
+	}
+}
+
+// Start runs a sync immediately and then every interval (or when
+// Trigger is called). An interval <= 0 disables polling, leaving only
+// triggered syncs. It blocks until Stop() is called.
+func (s *Syncer) Start(interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		log.Printf("[sync] started (every %s)", interval)
+		s.runOnce()
+	} else {
+		log.Println("[sync] started (push only)")
+	}
+
+	for {
+		select {
+		case <-s.stopCh:
+			log.Println("[sync] stopped")
+			return
+		case <-tick:
+			s.runOnce()
+		case <-s.triggerCh:
+			s.runOnce()
+		}
+	}
+}
+
+// Trigger requests an immediate sync from the Start loop.
+func (s *Syncer) Trigger() {
+	select {
+	case s.triggerCh <- struct{}{}:
+	default:
+	}
+}
+
+// Refresh requests an immediate sync that applies the manifest in full
+// even if the server reports it unchanged, restoring files removed
+// from the target directories since the last pass.
+func (s *Syncer) Refresh() {
+	s.pendingMu.Lock()
+	s.refresh = true
+	s.pendingMu.Unlock()
+	s.Trigger()
+}
+
+// Stop halts the sync loop.
+func (s *Syncer) Stop() {
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+}
+
+func (s *Syncer) runOnce() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	defer cancel()
+
+	res, err := s.Sync(ctx)
+	if errors.Is(err, api.ErrUnregistered) {
+		log.Println("[sync] skipped: missing endpoint or id")
+		return
+	}
+	if err != nil {
+		log.Printf("[sync] failed: %v", err)
+		return
+	}
+	log.Printf("[sync] version=%q downloaded=%d placed=%d unchanged=%d removed=%d failed=%d",
+		res.Version, res.Downloaded, res.Placed, res.Unchanged, res.Removed, res.Failed)
+}
+
+// SetTargets replaces the zone directories, e.g. after a template
+// reload. It does not wait for a running pass: the next pass uses the
+// new targets and re-applies the manifest even if it is unchanged.
+func (s *Syncer) SetTargets(targets map[string]string) {
+	s.pendingMu.Lock()
+	s.pending = targets
+	s.pendingMu.Unlock()
+	s.Trigger()
+}
+
+// Sync fetches the manifest and applies it. Files that fail to download
+// or verify are counted in Result.Failed and left as they were; files
+// no longer listed are removed only when every listed file succeeded,
+// so a partial failure never leaves a zone emptier than before.
+func (s *Syncer) Sync(ctx context.Context) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pendingMu.Lock()
+	if s.pending != nil {
+		s.targets = s.pending
+		s.pending = nil
+		s.etag = "" // the manifest must be applied to the new targets
+	}
+	if s.refresh {
+		s.refresh = false
+		s.etag = ""
+	}
+	s.pendingMu.Unlock()
+
+	if s.httpCli != nil {
+		s.httpCli.CloseIdleConnections()
+	}
+	s.httpCli = s.newHTTPClient()
+
+	m, etag, err := s.fetchManifest(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	if m == nil {
+		return Result{Version: "unchanged"}, nil
+	}
+
+	res := Result{Version: m.Version}
+	if err := os.MkdirAll(s.stagingDir, 0755); err != nil {
+		return res, fmt.Errorf("staging dir: %w", err)
+	}
+
+	wanted := make(map[string]bool) // absolute paths the manifest keeps
+	bySum := make(map[string][]File)
+	var order []string
+	for _, f := range m.Files {
+		dest, err := s.destination(f)
+		if errors.Is(err, errUnknownZone) {
+			// Content for a zone this template does not have: not an
+			// error that a retry could fix.
+			log.Printf("[sync] skipping %s/%s: %v", f.Zone, f.Name, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("[sync] skipping %s/%s: %v", f.Zone, f.Name, err)
+			res.Failed++
+			continue
+		}
+		wanted[dest] = true
+
+		if s.matches(dest, f.SHA256) {
+			// Already there, by an earlier pass or by hand: the
+			// manifest now manages it.
+			s.managed[dest] = true
+			res.Unchanged++
+			continue
+		}
+		sum, _ := checksum(f.SHA256) // validated by destination
+		if _, ok := bySum[sum]; !ok {
+			order = append(order, sum)
+		}
+		bySum[sum] = append(bySum[sum], f)
+	}
+
+	for _, sum := range order {
+		files := bySum[sum]
+		staged, err := s.download(ctx, files[0])
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			log.Printf("[sync] download %s: %v", files[0].Name, err)
+			res.Failed += len(files)
+			continue
+		}
+		res.Downloaded++
+
+		for _, f := range files {
+			dest, _ := s.destination(f)
+			if err := place(staged, dest); err != nil {
+				log.Printf("[sync] place %s: %v", dest, err)
+				res.Failed++
+				continue
+			}
+			s.remember(dest, sum)
+			s.managed[dest] = true
+			res.Placed++
+			log.Printf("[sync] placed %s", dest)
+		}
+		os.Remove(staged)
+	}
+
+	// Only a fully applied manifest is cached; after failures the next
+	// pass must fetch it again to retry.
+	if res.Failed == 0 {
+		res.Removed = s.prune(wanted)
+		s.etag = etag
+	}
+	s.saveManaged()
+
+	return res, nil
+}
+
+// fetchManifest GETs {endpoint}/content along with its ETag. It returns
+// a nil manifest when the server reports it unchanged since the last
+// fully applied pass.
+func (s *Syncer) fetchManifest(ctx context.Context) (*Manifest, string, error) {
+	req, err := s.api.NewRequest(ctx, http.MethodGet, "/content", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("manifest request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("manifest response: %d", resp.StatusCode)
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, "", fmt.Errorf("parse manifest: %w", err)
+	}
+	return &m, resp.Header.Get("ETag"), nil
+}
+
+// errUnknownZone marks a manifest entry for a zone with no target.
+var errUnknownZone = errors.New("unknown zone")
+
+// destination validates a manifest entry and returns where it belongs.
+func (s *Syncer) destination(f File) (string, error) {
+	dir, ok := s.targets[f.Zone]
+	if !ok {
+		return "", fmt.Errorf("%w %q", errUnknownZone, f.Zone)
+	}
+	if f.Name == "" || f.Name != filepath.Base(f.Name) || strings.HasPrefix(f.Name, ".") {
+		return "", fmt.Errorf("invalid file name %q", f.Name)
+	}
+	if !media.IsSupported(f.Name) {
+		return "", fmt.Errorf("unsupported media type %q", f.Name)
+	}
+	if _, err := checksum(f.SHA256); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, f.Name), nil
+}
+
+// checksum validates a manifest SHA-256 and returns it as lower-case
+// hex. It names files in the staging area, so anything but a digest,
+// such as a relative path, is rejected.
+func checksum(raw string) (string, error) {
+	b, err := hex.DecodeString(raw)
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid sha256 %q", raw)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// matches reports whether path already holds content with checksum sum.
+func (s *Syncer) matches(path, sum string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if h, ok := s.hashes[path]; ok && h.size == info.Size() && h.modTime.Equal(info.ModTime()) {
+		return strings.EqualFold(h.sum, sum)
+	}
+	got, err := hashFile(path)
+	if err != nil {
+		return false
+	}
+	s.hashes[path] = fileHash{size: info.Size(), modTime: info.ModTime(), sum: got}
+	return strings.EqualFold(got, sum)
+}
+
+func (s *Syncer) remember(path, sum string) {
+	if info, err := os.Stat(path); err == nil {
+		s.hashes[path] = fileHash{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	}
+}
+
+// download fetches f into the staging area, resuming a previous partial
+// download if one exists, and verifies its checksum. It returns the
+// path of the verified staged file.
+func (s *Syncer) download(ctx context.Context, f File) (string, error) {
+	sum, err := checksum(f.SHA256)
+	if err != nil {
+		return "", err
+	}
+	staged := filepath.Join(s.stagingDir, sum)
+	part := staged + ".part"
+
+	// A previous pass may have finished the download but not placed it.
+	if got, err := hashFile(staged); err == nil && got == sum {
+		return staged, nil
+	}
+
+	src, err := s.resolve(f.URL)
+	if err != nil {
+		return "", err
+	}
+
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	err = s.fetch(ctx, f.Name, src, part, offset)
+	if errors.Is(err, errBadRange) {
+		log.Printf("[sync] %s: %v, restarting", f.Name, err)
+		os.Remove(part)
+		err = s.fetch(ctx, f.Name, src, part, 0)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	got, err := hashFile(part)
+	if err != nil {
+		return "", err
+	}
+	if got != sum {
+		os.Remove(part)
+		return "", fmt.Errorf("checksum mismatch: got %s, want %s", got, sum)
+	}
+
+	if err := os.Rename(part, staged); err != nil {
+		return "", err
+	}
+	return staged, nil
+}
+
+// errBadRange marks a partial response that does not continue the
+// staged part file.
+var errBadRange = errors.New("partial response does not start at the resume offset")
+
+// errStalled is the cause of a download cancelled by the idle watchdog.
+var errStalled = errors.New("no data received")
+
+// fetch GETs src into part, asking for the bytes from offset on when
+// part already holds that many. A download that receives nothing for
+// s.idleTimeout is cancelled; what arrived so far stays in part for
+// the next pass to resume.
+func (s *Syncer) fetch(ctx context.Context, name, src, part string, offset int64) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	watchdog := time.AfterFunc(s.idleTimeout, func() { cancel(errStalled) })
+	defer watchdog.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := s.httpCli.Do(req)
+	if err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, errStalled) {
+			return fmt.Errorf("download stalled: %w", cause)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var start int64
+		cr := resp.Header.Get("Content-Range")
+		if _, err := fmt.Sscanf(cr, "bytes %d-", &start); err != nil || start != offset {
+			return fmt.Errorf("%w: Content-Range %q, offset %d", errBadRange, cr, offset)
+		}
+		flags |= os.O_APPEND
+		log.Printf("[sync] resuming %s at %d bytes", name, offset)
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is already complete (or corrupt); the
+		// caller verifies it.
+		return nil
+	default:
+		return fmt.Errorf("download response: %d", resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(out, &idleReader{r: resp.Body, watchdog: watchdog, timeout: s.idleTimeout})
+	closeErr := out.Close()
+	if copyErr != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, errStalled) {
+			copyErr = cause
+		}
+		return fmt.Errorf("download interrupted: %w", copyErr)
+	}
+	return closeErr
+}
+
+// idleReader pushes the download watchdog back whenever data arrives.
+type idleReader struct {
+	r        io.Reader
+	watchdog *time.Timer
+	timeout  time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.watchdog.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// resolve makes a manifest URL absolute, relative to the API endpoint.
+func (s *Syncer) resolve(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %q: %w", raw, err)
+	}
+	if u.IsAbs() {
+		return raw, nil
+	}
+	base, err := url.Parse(strings.TrimRight(s.api.GetConfig().Endpoint, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// prune removes the files the syncer placed in the target directories
+// that are no longer wanted. Files it did not place, such as ones
+// copied in by hand, are never touched.
+func (s *Syncer) prune(wanted map[string]bool) int {
+	dirs := make(map[string]bool)
+	for _, dir := range s.targets {
+		dirs[filepath.Clean(dir)] = true
+	}
+
+	removed := 0
+	for path := range s.managed {
+		if wanted[path] || !dirs[filepath.Dir(path)] {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[sync] remove %s: %v", path, err)
+			continue
+		} else if err == nil {
+			removed++
+			log.Printf("[sync] removed %s", path)
+		}
+		delete(s.managed, path)
+		delete(s.hashes, path)
+	}
+	return removed
+}
+
+// place atomically installs staged at dest. The file is first linked or
+// copied next to dest under a hidden temporary name, then renamed, so
+// the destination directory only ever sees a complete file.
+func place(staged, dest string) error {
+	tmp := filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+".sync")
+	os.Remove(tmp)
+
+	if err := os.Link(staged, tmp); err != nil {
+		if err := copyFile(staged, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,391 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"player-native/internal/api"
+)
+
+// contentServer serves a mutable manifest at /content and file bodies
+// at /media/{name}, recording Range headers.
+type contentServer struct {
+	mu       sync.Mutex
+	manifest Manifest
+	bodies   map[string][]byte
+	ranges   []string
+}
+
+func (cs *contentServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /content", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Player-ID") != "p1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		json.NewEncoder(w).Encode(cs.manifest)
+	})
+	mux.HandleFunc("GET /media/{name}", func(w http.ResponseWriter, r *http.Request) {
+		cs.mu.Lock()
+		body, ok := cs.bodies[r.PathValue("name")]
+		if rg := r.Header.Get("Range"); rg != "" {
+			cs.ranges = append(cs.ranges, rg)
+		}
+		cs.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, r.PathValue("name"), time.Time{}, bytes.NewReader(body))
+	})
+	return mux
+}
+
+func (cs *contentServer) set(files ...File) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.manifest = Manifest{Version: fmt.Sprint(len(files)), Files: files}
+}
+
+func sum(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func newTestSyncer(t *testing.T, endpoint string, targets map[string]string) *Syncer {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	cfg := fmt.Sprintf(`{"id": "p1", "key": "k", "endpoint": %q}`, endpoint)
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := api.NewClient(cfgPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSyncer(client, filepath.Join(t.TempDir(), "staging"), targets)
+}
+
+// TestSyncDownloadsAndPrunes covers the full cycle: download, verify,
+// place into the zone directory, then remove files no longer listed.
+func TestSyncDownloadsAndPrunes(t *testing.T) {
+	a := []byte("video-a")
+	b := []byte("image-b")
+	cs := &contentServer{bodies: map[string][]byte{"a.mp4": a, "b.jpg": b}}
+	ts := httptest.NewServer(cs.handler())
+	defer ts.Close()
+
+	mainDir := t.TempDir()
+	sideDir := t.TempDir()
+	os.WriteFile(filepath.Join(mainDir, "playlist.json"), []byte(`{"items": []}`), 0644)
+
+	s := newTestSyncer(t, ts.URL, map[string]string{"main": mainDir, "side": sideDir})
+
+	cs.set(
+		File{Zone: "main", Name: "a.mp4", URL: "/media/a.mp4", SHA256: sum(a)},
+		File{Zone: "side", Name: "a.mp4", URL: ts.URL + "/media/a.mp4", SHA256: sum(a)},
+		File{Zone: "main", Name: "b.jpg", URL: "/media/b.jpg", SHA256: sum(b)},
+	)
+
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Downloaded != 2 || res.Placed != 3 || res.Failed != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	for _, p := range []string{filepath.Join(mainDir, "a.mp4"), filepath.Join(mainDir, "b.jpg"), filepath.Join(sideDir, "a.mp4")} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s: %v", p, err)
+		}
+	}
+
+	// Second pass with the same manifest changes nothing.
+	res, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Unchanged != 3 || res.Downloaded != 0 {
+		t.Fatalf("expected all unchanged, got %+v", res)
+	}
+
+	// Drop b.jpg from the manifest; it should be removed, the playlist
+	// manifest left alone.
+	cs.set(
+		File{Zone: "main", Name: "a.mp4", URL: "/media/a.mp4", SHA256: sum(a)},
+		File{Zone: "side", Name: "a.mp4", URL: "/media/a.mp4", SHA256: sum(a)},
+	)
+	res, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 1 {
+		t.Fatalf("expected 1 removal, got %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(mainDir, "b.jpg")); !os.IsNotExist(err) {
+		t.Error("expected b.jpg to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(mainDir, "playlist.json")); err != nil {
+		t.Error("playlist.json should not be pruned")
+	}
+}
+
+// TestSyncPrunesOnlyManaged: files copied in by hand are never pruned,
+// the syncer remembers what it placed across a restart, and content for
+// a zone the template lacks does not hold back pruning.
+func TestSyncPrunesOnlyManaged(t *testing.T) {
+	a := []byte("video-a")
+	cs := &contentServer{bodies: map[string][]byte{"a.mp4": a}}
+	ts := httptest.NewServer(cs.handler())
+	defer ts.Close()
+
+	mainDir := t.TempDir()
+	manual := filepath.Join(mainDir, "manual.mp4")
+	os.WriteFile(manual, []byte("copied by hand"), 0644)
+	targets := map[string]string{"main": mainDir}
+
+	s := newTestSyncer(t, ts.URL, targets)
+	cs.set(
+		File{Zone: "main", Name: "a.mp4", URL: "/media/a.mp4", SHA256: sum(a)},
+		File{Zone: "lobby", Name: "a.mp4", URL: "/media/a.mp4", SHA256: sum(a)},
+	)
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Placed != 1 || res.Failed != 0 || res.Removed != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	// After a restart, a.mp4 is still known to be the syncer's.
+	s = NewSyncer(s.api, s.stagingDir, targets)
+	cs.set()
+	res, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 1 {
+		t.Fatalf("expected a.mp4 to be removed, got %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(mainDir, "a.mp4")); !os.IsNotExist(err) {
+		t.Error("a.mp4 not pruned")
+	}
+	if _, err := os.Stat(manual); err != nil {
+		t.Errorf("hand-copied file pruned: %v", err)
+	}
+}
+
+// TestSyncChecksumMismatch ensures corrupt downloads are never placed
+// and that nothing is pruned on a failed pass.
+func TestSyncChecksumMismatch(t *testing.T) {
+	cs := &contentServer{bodies: map[string][]byte{"a.mp4": []byte("tampered")}}
+	ts := httptest.NewServer(cs.handler())
+	defer ts.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "old.mp4"), []byte("old"), 0644)
+
+	s := newTestSyncer(t, ts.URL, map[string]string{"main": dir})
+	cs.set(File{Zone: "main", Name: "a.mp4", URL: "/media/a.mp4", SHA256: sum([]byte("original"))})
+
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Failed != 1 || res.Placed != 0 || res.Removed != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.mp4")); !os.IsNotExist(err) {
+		t.Error("corrupt file should not be placed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.mp4")); err != nil {
+		t.Error("old content should survive a failed sync")
+	}
+}
+
+// TestSyncResumesPartialDownload pre-seeds a partial staging file and
+// checks that only the remainder is requested.
+func TestSyncResumesPartialDownload(t *testing.T) {
+	body := []byte(strings.Repeat("0123456789", 100))
+	cs := &contentServer{bodies: map[string][]byte{"big.mp4": body}}
+	ts := httptest.NewServer(cs.handler())
+	defer ts.Close()
+
+	dir := t.TempDir()
+	s := newTestSyncer(t, ts.URL, map[string]string{"main": dir})
+	os.MkdirAll(s.stagingDir, 0755)
+	os.WriteFile(filepath.Join(s.stagingDir, sum(body)+".part"), body[:400], 0644)
+
+	cs.set(File{Zone: "main", Name: "big.mp4", URL: "/media/big.mp4", SHA256: sum(body)})
+
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Placed != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(cs.ranges) != 1 || cs.ranges[0] != "bytes=400-" {
+		t.Fatalf("expected resume from byte 400, got %v", cs.ranges)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "big.mp4"))
+	if !bytes.Equal(got, body) {
+		t.Fatal("resumed file content mismatch")
+	}
+}
+
+// TestSyncRejectsUnsafeNames ensures manifest names cannot escape the
+// zone directory.
+func TestSyncRejectsUnsafeNames(t *testing.T) {
+	s := &Syncer{targets: map[string]string{"main": "/p"}}
+	valid := strings.Repeat("a", 64)
+	for _, name := range []string{"../x.mp4", "sub/x.mp4", ".hidden.mp4", "x.txt", ""} {
+		if _, err := s.destination(File{Zone: "main", Name: name, SHA256: valid}); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+	if _, err := s.destination(File{Zone: "other", Name: "x.mp4", SHA256: valid}); err == nil {
+		t.Error("expected unknown zone to be rejected")
+	}
+}
+
+// TestSyncRejectsUnsafeChecksums ensures a manifest checksum, which
+// names the staging file, cannot point outside the staging directory.
+func TestSyncRejectsUnsafeChecksums(t *testing.T) {
+	a := []byte("video-a")
+	cs := &contentServer{bodies: map[string][]byte{"a.mp4": a}}
+	ts := httptest.NewServer(cs.handler())
+	defer ts.Close()
+
+	dir := t.TempDir()
+	s := newTestSyncer(t, ts.URL, map[string]string{"main": dir})
+
+	traversal := strings.Repeat("../", 18) + "./dev/zero" // 64 characters
+	for _, bad := range []string{traversal, strings.Repeat("g", 64), sum(a)[:62] + "/."} {
+		if _, err := s.destination(File{Zone: "main", Name: "a.mp4", SHA256: bad}); err == nil {
+			t.Errorf("expected sha256 %q to be rejected", bad)
+		}
+	}
+
+	cs.set(
+		File{Zone: "main", Name: "a.mp4", URL: "/media/a.mp4", SHA256: traversal},
+		File{Zone: "main", Name: "b.mp4", URL: "/media/a.mp4", SHA256: strings.ToUpper(sum(a))},
+	)
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Failed != 1 || res.Placed != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.mp4")); err != nil {
+		t.Errorf("upper-case checksum should be accepted: %v", err)
+	}
+}
+
+// TestSyncAbortsStalledDownload checks that a server which stops
+// sending without closing the connection does not block the pass, and
+// that the bytes received are kept for resuming.
+func TestSyncAbortsStalledDownload(t *testing.T) {
+	body := []byte(strings.Repeat("0123456789", 100))
+	cs := &contentServer{}
+	release := make(chan struct{})
+	defer close(release)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /content", cs.handler())
+	mux.HandleFunc("GET /media/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.Write(body[:300])
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	s := newTestSyncer(t, ts.URL, map[string]string{"main": dir})
+	s.idleTimeout = 100 * time.Millisecond
+	cs.set(File{Zone: "main", Name: "big.mp4", URL: "/media/big.mp4", SHA256: sum(body)})
+
+	done := make(chan Result, 1)
+	go func() {
+		res, err := s.Sync(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		if res.Failed != 1 || res.Placed != 0 {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled download blocked the sync pass")
+	}
+	info, err := os.Stat(filepath.Join(s.stagingDir, sum(body)+".part"))
+	if err != nil || info.Size() != 300 {
+		t.Fatalf("expected 300 bytes kept for resuming, got %v, %v", info, err)
+	}
+}
+
+// TestSyncRestartsOnMismatchedRange checks that a partial response
+// which does not continue the part file is not appended to it.
+func TestSyncRestartsOnMismatchedRange(t *testing.T) {
+	body := []byte(strings.Repeat("0123456789", 100))
+	cs := &contentServer{}
+	var ranges []string
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /content", cs.handler())
+	mux.HandleFunc("GET /media/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if rg := r.Header.Get("Range"); rg != "" {
+			// Ignores the requested start and sends everything.
+			ranges = append(ranges, rg)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(body)-1, len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write(body)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	s := newTestSyncer(t, ts.URL, map[string]string{"main": dir})
+	os.MkdirAll(s.stagingDir, 0755)
+	os.WriteFile(filepath.Join(s.stagingDir, sum(body)+".part"), body[:400], 0644)
+	cs.set(File{Zone: "main", Name: "big.mp4", URL: "/media/big.mp4", SHA256: sum(body)})
+
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Placed != 1 || res.Failed != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=400-" {
+		t.Fatalf("expected one resume attempt, got %v", ranges)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "big.mp4"))
+	if !bytes.Equal(got, body) {
+		t.Fatal("restarted file content mismatch")
+	}
+}
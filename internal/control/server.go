@@ -0,0 +1,202 @@
+// Package control exposes a local HTTP API for inspecting and driving
+// a running player: zone status, playlists, health, and playback
+// actions. It is intended for on-site debugging and local integrations
+// and binds to localhost unless configured otherwise.
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"player-native/internal/media"
+	"player-native/internal/system"
+	"player-native/internal/vlc"
+)
+
+// DefaultAddr is the default listen address for the control API.
+const DefaultAddr = "127.0.0.1:8686"
+
+// Player is the set of engine operations exposed over HTTP.
+// *vlc.Engine satisfies it.
+type Player interface {
+	TemplateName() string
+	Status() []vlc.ZoneStatus
+	Playlist(zoneID string) ([]media.Item, error)
+	Skip(zoneID string) error
+	RestartZone(zoneID string) error
+	Pause(zoneID string) error
+	Resume(zoneID string) error
+	SetVolume(zoneID string, percent int) error
+}
+
+// Hooks are player-level actions outside the engine. Each should return
+// quickly; the work itself may complete asynchronously. A nil hook makes
+// its endpoint report 501.
+type Hooks struct {
+	Reload func() error // re-read the template
+	Sync   func() error // start a content sync pass
+}
+
+// Server is the control API HTTP server.
+type Server struct {
+	player  Player
+	hooks   Hooks
+	version string
+	health  func() system.HealthStatus
+	srv     *http.Server
+}
+
+// NewServer creates a control server for the given player.
+func NewServer(addr, version string, player Player, hooks Hooks) *Server {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	s := &Server{
+		player:  player,
+		hooks:   hooks,
+		version: version,
+		health:  system.RunHealthCheck,
+	}
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the HTTP handler with all control routes registered.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /zones", s.handleZones)
+	mux.HandleFunc("GET /zones/{id}", s.handleZone)
+	mux.HandleFunc("GET /zones/{id}/playlist", s.handlePlaylist)
+	mux.HandleFunc("POST /zones/{id}/next", s.zoneAction(s.player.Skip))
+	mux.HandleFunc("POST /zones/{id}/restart", s.zoneAction(s.player.RestartZone))
+	mux.HandleFunc("POST /zones/{id}/pause", s.zoneAction(s.player.Pause))
+	mux.HandleFunc("POST /zones/{id}/resume", s.zoneAction(s.player.Resume))
+	mux.HandleFunc("POST /zones/{id}/volume", s.handleVolume)
+	mux.HandleFunc("POST /reload", s.hookAction(s.hooks.Reload, "reloading"))
+	mux.HandleFunc("POST /sync", s.hookAction(s.hooks.Sync, "syncing"))
+	return mux
+}
+
+// Start listens and serves until Stop() is called.
+func (s *Server) Start() error {
+	log.Printf("[control] listening on http://%s", s.srv.Addr)
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Stop gracefully shuts the server down.
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		log.Printf("[control] shutdown error: %v", err)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":  s.version,
+		"template": s.player.TemplateName(),
+		"zones":    s.player.Status(),
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.health())
+}
+
+func (s *Server) handleZones(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.player.Status())
+}
+
+func (s *Server) handleZone(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, st := range s.player.Status() {
+		if st.ID == id {
+			writeJSON(w, http.StatusOK, st)
+			return
+		}
+	}
+	writeError(w, vlc.ErrZoneNotFound)
+}
+
+func (s *Server) handlePlaylist(w http.ResponseWriter, r *http.Request) {
+	items, err := s.player.Playlist(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) zoneAction(fn func(zoneID string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(r.PathValue("id")); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// handleVolume sets a zone's volume from a {"volume": 0-200} body.
+func (s *Server) handleVolume(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Volume *int `json:"volume"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Volume == nil || *req.Volume < 0 || *req.Volume > 200 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": `expected {"volume": 0-200}`})
+		return
+	}
+	if err := s.player.SetVolume(r.PathValue("id"), *req.Volume); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) hookAction(fn func() error, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if fn == nil {
+			writeError(w, vlc.ErrUnsupported)
+			return
+		}
+		if err := fn(); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": status})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[control] response encode error: %v", err)
+	}
+}
+
+// writeError maps engine errors onto HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, vlc.ErrZoneNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, vlc.ErrUnsupported):
+		code = http.StatusNotImplemented
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,192 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"player-native/internal/media"
+	"player-native/internal/system"
+	"player-native/internal/vlc"
+)
+
+// stubPlayer records actions and serves canned status for one zone.
+type stubPlayer struct {
+	actions []string
+}
+
+func (p *stubPlayer) TemplateName() string { return "test" }
+
+func (p *stubPlayer) Status() []vlc.ZoneStatus {
+	return []vlc.ZoneStatus{{ID: "main", State: vlc.StatePlaying, Items: 2}}
+}
+
+func (p *stubPlayer) Playlist(zoneID string) ([]media.Item, error) {
+	if zoneID != "main" {
+		return nil, vlc.ErrZoneNotFound
+	}
+	return media.ItemsFromPaths([]string{"/p/a.mp4", "/p/b.jpg"}), nil
+}
+
+func (p *stubPlayer) Skip(zoneID string) error {
+	return vlc.ErrUnsupported
+}
+
+func (p *stubPlayer) RestartZone(zoneID string) error { return p.record("restart", zoneID) }
+func (p *stubPlayer) Pause(zoneID string) error       { return p.record("pause", zoneID) }
+func (p *stubPlayer) Resume(zoneID string) error      { return p.record("resume", zoneID) }
+
+func (p *stubPlayer) SetVolume(zoneID string, percent int) error {
+	return p.record(fmt.Sprintf("volume %d", percent), zoneID)
+}
+
+func (p *stubPlayer) record(action, zoneID string) error {
+	if zoneID != "main" {
+		return vlc.ErrZoneNotFound
+	}
+	p.actions = append(p.actions, action)
+	return nil
+}
+
+func newTestServer(t *testing.T, p Player, hooks Hooks) *httptest.Server {
+	t.Helper()
+	s := NewServer("", "test", p, hooks)
+	s.health = func() system.HealthStatus { return system.HealthStatus{CPUTempC: 42} }
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestStatusAndPlaylist verifies the read-only endpoints.
+func TestStatusAndPlaylist(t *testing.T) {
+	ts := newTestServer(t, &stubPlayer{}, Hooks{})
+
+	resp, err := http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status struct {
+		Template string           `json:"template"`
+		Zones    []vlc.ZoneStatus `json:"zones"`
+	}
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if status.Template != "test" || len(status.Zones) != 1 || status.Zones[0].State != vlc.StatePlaying {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	resp, err = http.Get(ts.URL + "/zones/main/playlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []media.Item
+	json.NewDecoder(resp.Body).Decode(&items)
+	resp.Body.Close()
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %v", items)
+	}
+
+	resp, err = http.Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health system.HealthStatus
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if health.CPUTempC != 42 {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+}
+
+// TestZoneActions checks action routing and error status codes.
+func TestZoneActions(t *testing.T) {
+	p := &stubPlayer{}
+	ts := newTestServer(t, p, Hooks{})
+
+	cases := []struct {
+		path string
+		code int
+	}{
+		{"/zones/main/pause", http.StatusOK},
+		{"/zones/main/resume", http.StatusOK},
+		{"/zones/main/restart", http.StatusOK},
+		{"/zones/main/next", http.StatusNotImplemented},
+		{"/zones/nope/pause", http.StatusNotFound},
+		{"/reload", http.StatusNotImplemented},
+		{"/sync", http.StatusNotImplemented},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(ts.URL+c.path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s: expected %d, got %d", c.path, c.code, resp.StatusCode)
+		}
+	}
+
+	if len(p.actions) != 3 || p.actions[0] != "pause" || p.actions[2] != "restart" {
+		t.Errorf("unexpected actions: %v", p.actions)
+	}
+
+	resp, err := http.Get(ts.URL + "/zones/main/pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET on action to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+// TestVolume checks body validation on the volume endpoint.
+func TestVolume(t *testing.T) {
+	p := &stubPlayer{}
+	ts := newTestServer(t, p, Hooks{})
+
+	cases := []struct {
+		path, body string
+		code       int
+	}{
+		{"/zones/main/volume", `{"volume": 80}`, http.StatusOK},
+		{"/zones/main/volume", `{"volume": 300}`, http.StatusBadRequest},
+		{"/zones/main/volume", `{}`, http.StatusBadRequest},
+		{"/zones/main/volume", `nope`, http.StatusBadRequest},
+		{"/zones/nope/volume", `{"volume": 0}`, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(ts.URL+c.path, "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s %s: expected %d, got %d", c.path, c.body, c.code, resp.StatusCode)
+		}
+	}
+	if len(p.actions) != 1 || p.actions[0] != "volume 80" {
+		t.Errorf("unexpected actions: %v", p.actions)
+	}
+}
+
+// TestReload verifies the reload hook is invoked.
+func TestReload(t *testing.T) {
+	called := false
+	ts := newTestServer(t, &stubPlayer{}, Hooks{Reload: func() error {
+		called = true
+		return nil
+	}})
+
+	resp, err := http.Post(ts.URL+"/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || !called {
+		t.Fatalf("expected 202 and reload call, got %d called=%v", resp.StatusCode, called)
+	}
+}
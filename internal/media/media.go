@@ -5,6 +5,7 @@ package media
 import (
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Type represents the kind of media file.
@@ -73,3 +74,31 @@ func IsSupported(path string) bool {
 // DefaultImageDuration is how long (in seconds) an image is displayed
 // before advancing to the next item in the playlist.
 const DefaultImageDuration = 10
+
+// Item is a single playlist entry together with its per-item playback
+// settings. A zero Duration means "use the default": images show for
+// DefaultImageDuration and videos play to the end.
+type Item struct {
+	Path     string            `json:"path"`
+	Duration time.Duration     `json:"duration,omitempty"`
+	Mute     bool              `json:"mute,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ItemsFromPaths wraps plain file paths as items with default settings.
+func ItemsFromPaths(paths []string) []Item {
+	items := make([]Item, len(paths))
+	for i, p := range paths {
+		items[i] = Item{Path: p}
+	}
+	return items
+}
+
+// Paths returns the file path of each item, in order.
+func Paths(items []Item) []string {
+	paths := make([]string, len(items))
+	for i, it := range items {
+		paths[i] = it.Path
+	}
+	return paths
+}
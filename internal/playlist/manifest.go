@@ -0,0 +1,176 @@
+package playlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"player-native/internal/media"
+)
+
+// ManifestName is the optional per-directory file that defines playlist
+// order and per-item settings. When present it takes precedence over
+// the alphabetical directory listing.
+const ManifestName = "playlist.json"
+
+// Manifest is the on-disk playlist.json structure.
+//
+//	{
+//	  "shuffle": false,
+//	  "loop": 1,
+//	  "items": [
+//	    {"file": "intro.mp4"},
+//	    {"file": "promo.jpg", "duration_sec": 5, "repeat": 2},
+//	    {"file": "ad.mp4", "mute": true, "enabled": false},
+//	    {"file": "spring-campaign"}
+//	  ]
+//	}
+//
+// Shuffle and Loop apply to the directory's resolved list: Shuffle mixes
+// it in a stable pseudo-random order (unchanged across rescans until the
+// contents change) and Loop plays it that many times in a row. They are
+// mostly useful for subfolder sub-playlists in recursive mode. A manifest
+// without an "items" key keeps the directory listing and only applies
+// these settings.
+type Manifest struct {
+	Shuffle bool           `json:"shuffle,omitempty"`
+	Loop    int            `json:"loop,omitempty"`
+	Items   []ManifestItem `json:"items"`
+}
+
+// ManifestItem describes one playlist entry. File is relative to the
+// directory containing the manifest; in recursive mode it may name a
+// subfolder, which expands to that folder's sub-playlist.
+type ManifestItem struct {
+	File     string            `json:"file"`
+	Duration float64           `json:"duration_sec,omitempty"`
+	Mute     bool              `json:"mute,omitempty"`
+	Repeat   int               `json:"repeat,omitempty"`
+	Enabled  *bool             `json:"enabled,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// IsEnabled reports whether the item should be played. Items are
+// enabled unless explicitly disabled.
+func (mi ManifestItem) IsEnabled() bool {
+	return mi.Enabled == nil || *mi.Enabled
+}
+
+// LoadManifest reads and parses a playlist.json file.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	return &m, nil
+}
+
+// Resolve expands the manifest into a playable item list for dir.
+// Disabled items, missing files and unsupported extensions are skipped;
+// repeated items are expanded in place.
+func (m *Manifest) Resolve(dir string) []media.Item {
+	return m.resolve(dir, nil, nil)
+}
+
+// resolve is Resolve with an optional readiness filter, used by the
+// watcher to hold back files that are still being written, and an
+// optional expand func that turns a subfolder entry into its items.
+func (m *Manifest) resolve(dir string, ready func(path string) bool, expand func(dir string) []media.Item) []media.Item {
+	var items []media.Item
+	for _, mi := range m.Items {
+		if !mi.IsEnabled() {
+			continue
+		}
+
+		rel := filepath.Clean(filepath.FromSlash(mi.File))
+		if mi.File == "" || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			log.Printf("[watcher] manifest: ignoring invalid path %q", mi.File)
+			continue
+		}
+		if isIgnoredName(filepath.Base(rel)) {
+			log.Printf("[watcher] manifest: ignoring hidden or temporary file %q", mi.File)
+			continue
+		}
+
+		path := filepath.Join(dir, rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Printf("[watcher] manifest: skipping missing file %q", mi.File)
+			continue
+		}
+		if info.IsDir() {
+			if expand == nil {
+				log.Printf("[watcher] manifest: ignoring folder %q (recursive mode is off)", mi.File)
+				continue
+			}
+			sub := expand(path)
+			for i := 0; i < max(mi.Repeat, 1); i++ {
+				items = append(items, sub...)
+			}
+			continue
+		}
+		if !media.IsSupported(rel) {
+			log.Printf("[watcher] manifest: ignoring unsupported file %q", mi.File)
+			continue
+		}
+		if ready != nil && !ready(path) {
+			log.Printf("[watcher] manifest: %q is still being written", mi.File)
+			continue
+		}
+
+		item := media.Item{
+			Path:     path,
+			Duration: time.Duration(mi.Duration * float64(time.Second)),
+			Mute:     mi.Mute,
+			Metadata: mi.Metadata,
+		}
+
+		for i := 0; i < max(mi.Repeat, 1); i++ {
+			items = append(items, item)
+		}
+	}
+
+	return m.arrange(items, dir)
+}
+
+// arrange applies the manifest's Shuffle and Loop settings to items.
+func (m *Manifest) arrange(items []media.Item, dir string) []media.Item {
+	if m.Shuffle {
+		stableShuffle(items, dir)
+	}
+	if m.Loop > 1 {
+		once := items
+		for i := 1; i < m.Loop; i++ {
+			items = append(items, once...)
+		}
+	}
+	return items
+}
+
+// stableShuffle permutes items with a seed derived from the directory
+// and its contents, so a rescan of unchanged content yields the same
+// order and does not restart playback.
+func stableShuffle(items []media.Item, dir string) {
+	h := fnv.New64a()
+	h.Write([]byte(dir))
+	for _, it := range items {
+		h.Write([]byte{0})
+		h.Write([]byte(it.Path))
+	}
+	rng := rand.New(rand.NewPCG(h.Sum64(), 0))
+	rng.Shuffle(len(items), func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+	})
+}
@@ -0,0 +1,115 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestManifestOrderAndSettings verifies that a playlist.json manifest
+// overrides alphabetical order and carries per-item settings.
+func TestManifestOrderAndSettings(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range []string{"a.mp4", "b.jpg", "c.mp4", "d.mp4"} {
+		os.WriteFile(filepath.Join(dir, f), []byte("test"), 0644)
+	}
+	manifest := `{
+		"items": [
+			{"file": "c.mp4", "mute": true},
+			{"file": "b.jpg", "duration_sec": 2.5, "repeat": 2},
+			{"file": "d.mp4", "enabled": false},
+			{"file": "missing.mp4"},
+			{"file": "../escape.mp4"},
+			{"file": "a.mp4", "metadata": {"campaign": "spring"}}
+		]
+	}`
+	os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0644)
+
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	items := w.Items()
+	expected := []string{"c.mp4", "b.jpg", "b.jpg", "a.mp4"}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, got %d: %v", len(expected), len(items), items)
+	}
+	for i, name := range expected {
+		if items[i].Path != filepath.Join(dir, name) {
+			t.Errorf("index %d: expected %s, got %s", i, name, items[i].Path)
+		}
+	}
+
+	if !items[0].Mute {
+		t.Error("expected c.mp4 to be muted")
+	}
+	if items[1].Duration != 2500*time.Millisecond {
+		t.Errorf("expected b.jpg duration 2.5s, got %s", items[1].Duration)
+	}
+	if items[3].Metadata["campaign"] != "spring" {
+		t.Errorf("expected metadata on a.mp4, got %v", items[3].Metadata)
+	}
+}
+
+// TestManifestInvalidFallsBack ensures a malformed manifest falls back
+// to the alphabetical directory listing.
+func TestManifestInvalidFallsBack(t *testing.T) {
+	dir := t.TempDir()
+
+	os.WriteFile(filepath.Join(dir, "b.mp4"), []byte("test"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.mp4"), []byte("test"), 0644)
+	os.WriteFile(filepath.Join(dir, ManifestName), []byte("{not json"), 0644)
+
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	got := w.Files()
+	if len(got) != 2 || got[0] != filepath.Join(dir, "a.mp4") {
+		t.Fatalf("expected alphabetical fallback, got %v", got)
+	}
+}
+
+// TestWatcherReloadsManifest verifies that rewriting playlist.json
+// triggers the onChange callback with the new order.
+func TestWatcherReloadsManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	os.WriteFile(filepath.Join(dir, "a.mp4"), []byte("test"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.mp4"), []byte("test"), 0644)
+	manifestPath := filepath.Join(dir, ManifestName)
+	os.WriteFile(manifestPath, []byte(`{"items": [{"file": "a.mp4"}, {"file": "b.mp4"}]}`), 0644)
+
+	changed := make(chan []string, 4)
+	w, err := NewWatcher(dir, func(files []string) {
+		changed <- files
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go w.Start()
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(manifestPath, []byte(`{"items": [{"file": "b.mp4"}]}`), 0644)
+
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case files := <-changed:
+			if len(files) == 1 && files[0] == filepath.Join(dir, "b.mp4") {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for manifest reload")
+		}
+	}
+}
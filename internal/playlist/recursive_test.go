@@ -0,0 +1,211 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, n := range names {
+		p := filepath.Join(dir, n)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func relPaths(dir string, files []string) []string {
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i], _ = filepath.Rel(dir, f)
+		out[i] = filepath.ToSlash(out[i])
+	}
+	return out
+}
+
+// TestRecursiveWalkOrder verifies subfolders are expanded in name order
+// alongside files and hidden folders are skipped.
+func TestRecursiveWalkOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"00-intro.mp4",
+		"01-spring/b.mp4",
+		"01-spring/a.jpg",
+		"02-summer/deep/x.mp4",
+		"02-summer/y.mp4",
+		"99-outro.mp4",
+		".staging/hidden.mp4",
+	)
+
+	w, err := NewWatcher(dir, nil, WithRecursive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	got := relPaths(dir, w.Files())
+	want := []string{
+		"00-intro.mp4",
+		"01-spring/a.jpg",
+		"01-spring/b.mp4",
+		"02-summer/deep/x.mp4",
+		"02-summer/y.mp4",
+		"99-outro.mp4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+// TestRecursiveSubPlaylistSettings checks per-folder manifests: a
+// manifest listing folders, a settings-only manifest with loop, and a
+// stable shuffle.
+func TestRecursiveSubPlaylistSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"main.mp4",
+		"ads/a.mp4", "ads/b.mp4",
+		"promo/1.jpg", "promo/2.jpg", "promo/3.jpg", "promo/4.jpg", "promo/5.jpg",
+	)
+	os.WriteFile(filepath.Join(dir, ManifestName),
+		[]byte(`{"items": [{"file": "ads"}, {"file": "main.mp4"}, {"file": "promo"}]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "ads", ManifestName), []byte(`{"loop": 2}`), 0644)
+	os.WriteFile(filepath.Join(dir, "promo", ManifestName), []byte(`{"shuffle": true}`), 0644)
+
+	w, err := NewWatcher(dir, nil, WithRecursive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	got := relPaths(dir, w.Files())
+	if len(got) != 10 {
+		t.Fatalf("expected 10 items, got %v", got)
+	}
+	if !reflect.DeepEqual(got[:5], []string{"ads/a.mp4", "ads/b.mp4", "ads/a.mp4", "ads/b.mp4", "main.mp4"}) {
+		t.Fatalf("unexpected looped ads order: %v", got[:5])
+	}
+
+	promo := got[5:]
+	seen := map[string]bool{}
+	for _, p := range promo {
+		seen[p] = true
+	}
+	if len(seen) != 5 {
+		t.Fatalf("shuffle lost or duplicated items: %v", promo)
+	}
+
+	// A rescan of unchanged content must keep the same shuffled order.
+	w.scan()
+	if again := relPaths(dir, w.Files()); !reflect.DeepEqual(again, got) {
+		t.Fatalf("shuffle not stable across rescans: %v vs %v", got, again)
+	}
+}
+
+// TestNonRecursiveIgnoresFolderItems ensures folder entries in a
+// manifest are skipped unless recursive mode is on.
+func TestNonRecursiveIgnoresFolderItems(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.mp4", "sub/b.mp4")
+	os.WriteFile(filepath.Join(dir, ManifestName),
+		[]byte(`{"items": [{"file": "sub"}, {"file": "a.mp4"}]}`), 0644)
+
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	if got := relPaths(dir, w.Files()); !reflect.DeepEqual(got, []string{"a.mp4"}) {
+		t.Fatalf("expected only a.mp4, got %v", got)
+	}
+}
+
+// TestRecursiveWatchesNewSubfolder verifies that files in a subfolder
+// created after startup are picked up.
+func TestRecursiveWatchesNewSubfolder(t *testing.T) {
+	dir := t.TempDir()
+	staging := t.TempDir()
+
+	changed := make(chan []string, 8)
+	w, err := NewWatcher(dir, func(files []string) {
+		changed <- files
+	}, WithRecursive(), WithDebounce(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go w.Start()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	os.Mkdir(filepath.Join(dir, "campaign"), 0755)
+	time.Sleep(300 * time.Millisecond)
+
+	src := filepath.Join(staging, "clip.mp4")
+	os.WriteFile(src, []byte("data"), 0644)
+	os.Rename(src, filepath.Join(dir, "campaign", "clip.mp4"))
+
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case files := <-changed:
+			if len(files) == 1 && files[0] == filepath.Join(dir, "campaign", "clip.mp4") {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for file in new subfolder")
+		}
+	}
+}
+
+// TestRecursiveNewSubfolderWaitsForCopy verifies that a file still
+// being written into a subfolder created after startup is held back:
+// the folder had no watch while the copy began, so the missing write
+// events must not make the file look atomically moved in.
+func TestRecursiveNewSubfolderWaitsForCopy(t *testing.T) {
+	dir := t.TempDir()
+	clip := filepath.Join(dir, "campaign", "clip.mp4")
+
+	listed := make(chan int64, 8) // size of clip.mp4 when it was listed
+	w, err := NewWatcher(dir, func(files []string) {
+		if info, err := os.Stat(clip); err == nil && len(files) == 1 {
+			listed <- info.Size()
+		}
+	}, WithRecursive(), WithDebounce(50*time.Millisecond), WithSettle(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.Start()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// As scp -r does: create the folder, then copy into it.
+	os.Mkdir(filepath.Dir(clip), 0755)
+	f, err := os.Create(clip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	chunk := make([]byte, 1<<20)
+	f.Write(chunk)
+	time.Sleep(300 * time.Millisecond)
+	f.Write(chunk)
+	f.Close()
+
+	select {
+	case size := <-listed:
+		if size != 2<<20 {
+			t.Fatalf("listed before the copy finished: %d bytes", size)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the copied file")
+	}
+}
@@ -0,0 +1,141 @@
+package playlist
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// tempSuffixes are extensions used by copy tools for in-progress files.
+var tempSuffixes = []string{
+	".part",
+	".partial",
+	".tmp",
+	".temp",
+	".rsync",
+	".filepart",
+	".crdownload",
+	".download",
+	".swp",
+}
+
+// isIgnoredName reports whether a file name belongs to a hidden or
+// in-progress file that must never be played or trigger a rescan.
+func isIgnoredName(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, suf := range tempSuffixes {
+		if strings.HasSuffix(lower, suf) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileState is the last observed size/mtime of a media file.
+type fileState struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // when this size/mtime was first observed
+	stable  bool
+}
+
+// stabilityTracker decides when a file has finished being written.
+//
+// A file is ready when its size and mtime have been unchanged for the
+// settle period and no write events arrived during it. A file that
+// appears already non-empty without any write events was renamed or
+// moved into place atomically and is ready immediately, but only in a
+// directory that was already watched: a folder found by this scan had
+// no watch yet, so missing write events prove nothing about its files.
+//
+// The tracker is only used from the watcher's scan path, which runs on
+// a single goroutine, so it needs no locking.
+type stabilityTracker struct {
+	settle   time.Duration
+	trustAll bool // initial scan: everything on disk is assumed complete
+
+	files  map[string]*fileState
+	writes map[string]time.Time // last write event per path
+	seen   map[string]bool      // paths observed during the current scan
+	wait   bool                 // some file was held back in the current scan
+}
+
+func newStabilityTracker(settle time.Duration) *stabilityTracker {
+	return &stabilityTracker{
+		settle: settle,
+		files:  make(map[string]*fileState),
+		writes: make(map[string]time.Time),
+	}
+}
+
+// noteWrite records a write event for path. It reports whether the
+// file was previously considered stable, i.e. is being rewritten in
+// place and must be re-evaluated.
+func (t *stabilityTracker) noteWrite(path string, at time.Time) bool {
+	t.writes[path] = at
+	st, ok := t.files[path]
+	if !ok || !st.stable {
+		return false
+	}
+	st.stable = false
+	return true
+}
+
+func (t *stabilityTracker) beginScan() {
+	t.seen = make(map[string]bool)
+	t.wait = false
+}
+
+// endScan forgets files that no longer exist.
+func (t *stabilityTracker) endScan() {
+	for path := range t.files {
+		if !t.seen[path] {
+			delete(t.files, path)
+			delete(t.writes, path)
+		}
+	}
+}
+
+// pending reports whether the last scan held back any file.
+func (t *stabilityTracker) pending() bool {
+	return t.wait
+}
+
+// ready reports whether path can be played as of now. watched tells
+// whether write events for path's directory were being delivered.
+func (t *stabilityTracker) ready(path string, now time.Time, watched bool) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	t.seen[path] = true
+
+	st, ok := t.files[path]
+	changed := !ok || st.size != info.Size() || !st.modTime.Equal(info.ModTime())
+	lastWrite, written := t.writes[path]
+
+	if changed {
+		st = &fileState{size: info.Size(), modTime: info.ModTime(), since: now}
+		t.files[path] = st
+		if t.trustAll || (watched && !written && info.Size() > 0) {
+			st.stable = true
+			return true
+		}
+		t.wait = true
+		return false
+	}
+
+	if st.stable {
+		return true
+	}
+	if now.Sub(st.since) >= t.settle && (!written || now.Sub(lastWrite) >= t.settle) {
+		st.stable = true
+		delete(t.writes, path)
+		return true
+	}
+	t.wait = true
+	return false
+}
@@ -0,0 +1,162 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestScanIgnoresTempAndHiddenFiles ensures in-progress copies and
+// dotfiles never reach the playlist.
+func TestScanIgnoresTempAndHiddenFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range []string{"ok.mp4", ".hidden.mp4", "copy.mp4.part", "upload.MP4.TMP", "sync.mp4.rsync", "backup.mp4~"} {
+		os.WriteFile(filepath.Join(dir, f), []byte("test"), 0644)
+	}
+
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	got := w.Files()
+	if len(got) != 1 || got[0] != filepath.Join(dir, "ok.mp4") {
+		t.Fatalf("expected only ok.mp4, got %v", got)
+	}
+}
+
+// TestWatcherHoldsBackGrowingFile simulates a slow copy and verifies the
+// file only joins the playlist after writes stop for the settle period.
+func TestWatcherHoldsBackGrowingFile(t *testing.T) {
+	dir := t.TempDir()
+	settle := 300 * time.Millisecond
+
+	var mu sync.Mutex
+	var calls [][]string
+	changed := make(chan struct{}, 4)
+
+	w, err := NewWatcher(dir, func(files []string) {
+		mu.Lock()
+		calls = append(calls, files)
+		mu.Unlock()
+		changed <- struct{}{}
+	}, WithDebounce(50*time.Millisecond), WithSettle(settle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go w.Start()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	path := filepath.Join(dir, "big.mp4")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		f.Write([]byte("chunk"))
+		time.Sleep(100 * time.Millisecond)
+	}
+	f.Close()
+	writesDone := time.Now()
+
+	select {
+	case <-changed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for stable file")
+	}
+
+	if time.Since(writesDone) < settle-50*time.Millisecond {
+		t.Fatalf("file released %s after last write, before settle period", time.Since(writesDone))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 1 || len(calls[0]) != 1 {
+		t.Fatalf("expected a single callback with 1 file (after %s), got %v", time.Since(start), calls)
+	}
+	info, _ := os.Stat(path)
+	if info.Size() != 40 {
+		t.Fatalf("unexpected final size %d", info.Size())
+	}
+}
+
+// TestWatcherAcceptsAtomicRename verifies that a temp file renamed into
+// place is picked up without waiting for the settle period.
+func TestWatcherAcceptsAtomicRename(t *testing.T) {
+	dir := t.TempDir()
+	settle := 2 * time.Second
+
+	changed := make(chan []string, 4)
+	w, err := NewWatcher(dir, func(files []string) {
+		changed <- files
+	}, WithDebounce(50*time.Millisecond), WithSettle(settle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go w.Start()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	tmp := filepath.Join(dir, "clip.mp4.part")
+	os.WriteFile(tmp, []byte("complete"), 0644)
+	time.Sleep(100 * time.Millisecond)
+	os.Rename(tmp, filepath.Join(dir, "clip.mp4"))
+
+	select {
+	case files := <-changed:
+		if len(files) != 1 || files[0] != filepath.Join(dir, "clip.mp4") {
+			t.Fatalf("unexpected files: %v", files)
+		}
+	case <-time.After(settle):
+		t.Fatal("renamed file was not accepted before the settle period")
+	}
+}
+
+// TestWatcherDebouncesBurst ensures many files added at once produce a
+// single onChange call.
+func TestWatcherDebouncesBurst(t *testing.T) {
+	dir := t.TempDir()
+	staging := t.TempDir()
+
+	changed := make(chan []string, 16)
+	w, err := NewWatcher(dir, func(files []string) {
+		changed <- files
+	}, WithDebounce(200*time.Millisecond), WithSettle(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go w.Start()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// Move complete files in, as a sync tool would.
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4", "d.jpg", "e.png"} {
+		src := filepath.Join(staging, name)
+		os.WriteFile(src, []byte("data"), 0644)
+		os.Rename(src, filepath.Join(dir, name))
+	}
+
+	select {
+	case files := <-changed:
+		if len(files) != 5 {
+			t.Fatalf("expected 5 files in one callback, got %v", files)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for callback")
+	}
+
+	select {
+	case files := <-changed:
+		t.Fatalf("unexpected second callback: %v", files)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -1,38 +1,85 @@
 // Package playlist provides real-time folder monitoring for media
 // directories, maintaining a sorted queue of media files that zone
-// engines consume for playback.
+// engines consume for playback. A playlist.json manifest in the
+// directory, when present, overrides the alphabetical order.
+//
+// Files still being copied in are held back until they are stable, so
+// engines never receive partially written media.
 package playlist
 
 import (
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
+	"reflect"
 	"sync"
+	"time"
 
 	"player-native/internal/media"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// Default timings for event handling. See WithDebounce and WithSettle.
+const (
+	DefaultDebounce = 250 * time.Millisecond
+	DefaultSettle   = 1500 * time.Millisecond
+)
+
 // OnChangeFunc is a callback invoked when the playlist changes.
-// It receives the updated sorted list of absolute file paths.
+// It receives the updated list of absolute file paths, in play order.
 type OnChangeFunc func(files []string)
 
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithDebounce sets the quiet period after a file event before the
+// directory is rescanned. Bursts of events within the period produce a
+// single rescan and at most one OnChangeFunc call.
+func WithDebounce(d time.Duration) Option {
+	return func(w *Watcher) { w.debounce = d }
+}
+
+// WithSettle sets how long a newly written file's size and mtime must
+// stay unchanged (with no further writes) before it joins the playlist.
+// Files renamed or moved into the directory in one step skip the wait.
+func WithSettle(d time.Duration) Option {
+	return func(w *Watcher) { w.settle = d }
+}
+
+// WithRecursive enables walking subdirectories. Subfolders are played
+// in sorted order alongside files, each folder may carry its own
+// playlist.json (a sub-playlist with its own order, shuffle and loop
+// settings), and new subfolders are watched as they appear. Hidden
+// directories are skipped.
+func WithRecursive() Option {
+	return func(w *Watcher) { w.recursive = true }
+}
+
+// maxDepth bounds recursion in case of deeply nested or looping trees.
+const maxDepth = 8
+
 // Watcher monitors a directory for file system events and maintains
 // a sorted list of playable media files (videos and images).
 type Watcher struct {
 	mu       sync.RWMutex
 	dir      string
-	files    []string
+	items    []media.Item
 	watcher  *fsnotify.Watcher
 	onChange OnChangeFunc
 	stopCh   chan struct{}
+
+	debounce  time.Duration
+	settle    time.Duration
+	recursive bool
+	tracker   *stabilityTracker
+	dirs      []string        // directories visited by the last scan
+	watched   map[string]bool // directories registered with fsnotify
 }
 
 // NewWatcher creates a new Watcher for the given directory.
 // The onChange callback fires whenever the file list changes.
-func NewWatcher(dir string, onChange OnChangeFunc) (*Watcher, error) {
+func NewWatcher(dir string, onChange OnChangeFunc, opts ...Option) (*Watcher, error) {
 	fw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -43,59 +90,187 @@ func NewWatcher(dir string, onChange OnChangeFunc) (*Watcher, error) {
 		watcher:  fw,
 		onChange: onChange,
 		stopCh:   make(chan struct{}),
+		debounce: DefaultDebounce,
+		settle:   DefaultSettle,
+		watched:  make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(w)
 	}
+	w.tracker = newStabilityTracker(w.settle)
 
-	// Perform initial scan before starting the watch loop.
+	// Perform initial scan before starting the watch loop. Files that
+	// already exist at startup are trusted to be complete.
+	w.tracker.trustAll = true
 	w.scan()
+	w.tracker.trustAll = false
 
 	return w, nil
 }
 
-// scan reads the directory and builds the sorted file list.
-func (w *Watcher) scan() {
-	entries, err := os.ReadDir(w.dir)
+// scan reads the directory and builds the item list, preferring the
+// playlist manifest when one exists and parses cleanly. Files that are
+// not yet stable are left out. It reports whether the list changed.
+func (w *Watcher) scan() bool {
+	now := time.Now()
+	w.tracker.beginScan()
+	defer w.tracker.endScan()
+
+	w.dirs = w.dirs[:0]
+	items := w.collect(w.dir, 0, func(path string) bool {
+		return w.tracker.ready(path, now, w.watched[filepath.Dir(path)])
+	})
+
+	log.Printf("[watcher] scanned %d media files in %s", len(items), w.dir)
+	return w.setItems(items)
+}
+
+// collect builds the items for one directory. In recursive mode
+// subdirectories are expanded in place.
+func (w *Watcher) collect(dir string, depth int, ready func(string) bool) []media.Item {
+	w.dirs = append(w.dirs, dir)
+
+	var expand func(string) []media.Item
+	if w.recursive && depth < maxDepth {
+		expand = func(sub string) []media.Item {
+			return w.collect(sub, depth+1, ready)
+		}
+	}
+
+	var m *Manifest
+	manifestPath := filepath.Join(dir, ManifestName)
+	if _, err := os.Stat(manifestPath); err == nil {
+		m, err = LoadManifest(manifestPath)
+		if err != nil {
+			log.Printf("[watcher] %v (falling back to directory listing)", err)
+		} else if m.Items != nil {
+			return m.resolve(dir, ready, expand)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		log.Printf("[watcher] scan error: %v", err)
-		return
+		return nil
 	}
 
-	var files []string
+	// ReadDir returns entries sorted by name, so files and subfolders
+	// interleave in name order.
+	var items []media.Item
 	for _, entry := range entries {
+		if isIgnoredName(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
 		if entry.IsDir() {
+			if expand != nil {
+				items = append(items, expand(path)...)
+			}
 			continue
 		}
-		if media.IsSupported(entry.Name()) {
-			files = append(files, filepath.Join(w.dir, entry.Name()))
+		if media.IsSupported(entry.Name()) && ready(path) {
+			items = append(items, media.Item{Path: path})
 		}
 	}
 
-	sort.Strings(files)
+	if m != nil {
+		items = m.arrange(items, dir)
+	}
+	return items
+}
 
-	w.mu.Lock()
-	w.files = files
-	w.mu.Unlock()
+// watchDirs registers any directories found by the last scan that are
+// not yet watched, and forgets ones that have disappeared so they are
+// re-added if recreated.
+func (w *Watcher) watchDirs() {
+	current := make(map[string]bool, len(w.dirs))
+	for _, d := range w.dirs {
+		current[d] = true
+	}
+	for d := range w.watched {
+		if d != w.dir && !current[d] {
+			w.watcher.Remove(d)
+			delete(w.watched, d)
+		}
+	}
+
+	for _, d := range w.dirs {
+		if w.watched[d] {
+			continue
+		}
+		if err := w.watcher.Add(d); err != nil {
+			log.Printf("[watcher] watch %s: %v", d, err)
+			continue
+		}
+		w.watched[d] = true
+		if d != w.dir {
+			log.Printf("[watcher] monitoring subfolder: %s", d)
+		}
+	}
+}
 
-	log.Printf("[watcher] scanned %d media files in %s", len(files), w.dir)
+// setItems stores items and reports whether they differ from before.
+func (w *Watcher) setItems(items []media.Item) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if reflect.DeepEqual(w.items, items) || (len(w.items) == 0 && len(items) == 0) {
+		return false
+	}
+	w.items = items
+	return true
 }
 
-// Files returns the current sorted list of media file paths.
+// Files returns the current list of media file paths, in play order.
 func (w *Watcher) Files() []string {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
-	dst := make([]string, len(w.files))
-	copy(dst, w.files)
+	return media.Paths(w.items)
+}
+
+// Items returns the current playlist including per-item settings
+// from the manifest (durations, mute flags, metadata).
+func (w *Watcher) Items() []media.Item {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	dst := make([]media.Item, len(w.items))
+	copy(dst, w.items)
 	return dst
 }
 
 // Start begins watching the directory for changes. It blocks until
 // Stop() is called or the watcher encounters a fatal error.
+//
+// Create, remove and rename events (and manifest edits) are debounced
+// into a single rescan. Write events do not trigger a rescan on their
+// own; they mark the file as in progress so the stability check holds
+// it back until the writes stop.
 func (w *Watcher) Start() error {
 	if err := w.watcher.Add(w.dir); err != nil {
 		return err
 	}
+	w.watched[w.dir] = true
+	w.watchDirs()
 
 	log.Printf("[watcher] monitoring: %s", w.dir)
 
+	debounceTimer := time.NewTimer(time.Hour)
+	debounceTimer.Stop()
+	recheckTimer := time.NewTimer(time.Hour)
+	recheckTimer.Stop()
+	defer debounceTimer.Stop()
+	defer recheckTimer.Stop()
+
+	rescan := func() {
+		changed := w.scan()
+		w.watchDirs()
+		if w.tracker.pending() {
+			recheckTimer.Reset(w.settle)
+		}
+		if changed && w.onChange != nil {
+			w.onChange(w.Files())
+		}
+	}
+
 	for {
 		select {
 		case <-w.stopCh:
@@ -106,14 +281,24 @@ func (w *Watcher) Start() error {
 			if !ok {
 				return nil
 			}
-			if isRelevantEvent(event) {
+			if isIgnoredName(filepath.Base(event.Name)) {
+				continue
+			}
+			rewritten := false
+			if event.Op&fsnotify.Write != 0 && media.IsSupported(event.Name) {
+				rewritten = w.tracker.noteWrite(event.Name, time.Now())
+			}
+			if isRelevantEvent(event) || isManifestWrite(event) || rewritten {
 				log.Printf("[watcher] event: %s %s", event.Op, event.Name)
-				w.scan()
-				if w.onChange != nil {
-					w.onChange(w.Files())
-				}
+				debounceTimer.Reset(w.debounce)
 			}
 
+		case <-debounceTimer.C:
+			rescan()
+
+		case <-recheckTimer.C:
+			rescan()
+
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
 				return nil
@@ -134,3 +319,9 @@ func (w *Watcher) Stop() {
 func isRelevantEvent(e fsnotify.Event) bool {
 	return e.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0
 }
+
+// isManifestWrite reports whether the event modifies a playlist manifest
+// in place, which changes the playlist without creating or removing files.
+func isManifestWrite(e fsnotify.Event) bool {
+	return e.Op&fsnotify.Write != 0 && filepath.Base(e.Name) == ManifestName
+}
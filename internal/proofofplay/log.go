@@ -0,0 +1,354 @@
+// Package proofofplay records which media items each zone actually
+// showed, for advertiser reporting. Records are appended to a local
+// JSONL log that rotates by size; sealed segments are uploaded in
+// batches and deleted only once the server accepts them, so records
+// survive restarts and network outages.
+package proofofplay
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"player-native/internal/vlc"
+)
+
+// Record statuses.
+const (
+	StatusCompleted   = "completed"
+	StatusInterrupted = "interrupted"
+)
+
+// Record is one line of the proof-of-play log.
+type Record struct {
+	ID       string            `json:"id"`
+	ZoneID   string            `json:"zone_id"`
+	File     string            `json:"file"`
+	Checksum string            `json:"sha256,omitempty"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Duration float64           `json:"duration_sec"`
+	Status   string            `json:"status"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Defaults for log rotation and retention. See WithMaxBytes and
+// WithMaxSegments.
+const (
+	DefaultMaxBytes    = 1 << 20 // rotate the active file at 1 MB
+	DefaultMaxSegments = 200     // keep at most this many sealed files
+)
+
+const (
+	activeName    = "current.jsonl"
+	segmentPrefix = "pop-"
+	segmentSuffix = ".jsonl"
+	queueSize     = 256
+)
+
+// Option configures a Log.
+type Option func(*Log)
+
+// WithMaxBytes sets the size at which the active file is sealed into a
+// segment awaiting upload.
+func WithMaxBytes(n int64) Option {
+	return func(l *Log) { l.maxBytes = n }
+}
+
+// WithMaxSegments bounds how many sealed segments are kept while the
+// server is unreachable. The oldest are dropped first.
+func WithMaxSegments(n int) Option {
+	return func(l *Log) { l.maxSegments = n }
+}
+
+// fileHash caches a media file's checksum, keyed by size and mtime.
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// Log is a rotating JSONL proof-of-play log. Events are queued by
+// Record and written by a background goroutine, so the playback path
+// never waits on disk I/O or checksumming.
+type Log struct {
+	dir         string
+	maxBytes    int64
+	maxSegments int
+
+	mu     sync.Mutex // guards f, size and segment files
+	f      *os.File
+	size   int64
+	hashes map[string]fileHash // only touched by writeLoop
+
+	qmu    sync.RWMutex // guards closed against sends on a closed queue
+	closed bool
+	queue  chan vlc.PlayEvent
+	done   chan struct{}
+}
+
+// OpenLog opens (or creates) the log in dir. An active file left behind
+// by a previous run is sealed first so it is uploaded like any other.
+func OpenLog(dir string, opts ...Option) (*Log, error) {
+	l := &Log{
+		dir:         dir,
+		maxBytes:    DefaultMaxBytes,
+		maxSegments: DefaultMaxSegments,
+		hashes:      make(map[string]fileHash),
+		queue:       make(chan vlc.PlayEvent, queueSize),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("proof-of-play dir: %w", err)
+	}
+	if err := l.Rotate(); err != nil {
+		return nil, err
+	}
+
+	go l.writeLoop()
+	return l, nil
+}
+
+// Record queues a play event for writing. It matches vlc.EventFunc and
+// never blocks: if the queue is full the event is dropped and logged.
+func (l *Log) Record(ev vlc.PlayEvent) {
+	l.qmu.RLock()
+	defer l.qmu.RUnlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.queue <- ev:
+	default:
+		log.Printf("[pop] queue full, dropping record for %s", ev.Item.Path)
+	}
+}
+
+// writeLoop drains the queue until Close.
+func (l *Log) writeLoop() {
+	defer close(l.done)
+	for ev := range l.queue {
+		if err := l.write(l.newRecord(ev)); err != nil {
+			log.Printf("[pop] write error: %v", err)
+		}
+	}
+}
+
+// newRecord converts an engine event into a log record.
+func (l *Log) newRecord(ev vlc.PlayEvent) Record {
+	status := StatusInterrupted
+	if ev.Completed {
+		status = StatusCompleted
+	}
+	return Record{
+		ID:       newID(),
+		ZoneID:   ev.ZoneID,
+		File:     filepath.Base(ev.Item.Path),
+		Checksum: l.checksum(ev.Item.Path),
+		Start:    ev.Start.UTC(),
+		End:      ev.End.UTC(),
+		Duration: ev.End.Sub(ev.Start).Seconds(),
+		Status:   status,
+		Metadata: ev.Item.Metadata,
+	}
+}
+
+// write appends r to the active file, sealing it when it grows past
+// the size limit.
+func (l *Log) write(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		f, err := os.OpenFile(filepath.Join(l.dir, activeName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		l.f = f
+		l.size = 0
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if l.size >= l.maxBytes {
+		return l.sealLocked()
+	}
+	return nil
+}
+
+// Rotate seals the active file into a segment, if it holds any records.
+func (l *Log) Rotate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sealLocked()
+}
+
+func (l *Log) sealLocked() error {
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+
+	active := filepath.Join(l.dir, activeName)
+	info, err := os.Stat(active)
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+
+	// Name segments by seal time; bump on the (unlikely) collision so a
+	// segment is never overwritten.
+	stamp := time.Now().UnixNano()
+	seg := segmentPath(l.dir, stamp)
+	for {
+		if _, err := os.Stat(seg); os.IsNotExist(err) {
+			break
+		}
+		stamp++
+		seg = segmentPath(l.dir, stamp)
+	}
+	if err := os.Rename(active, seg); err != nil {
+		return fmt.Errorf("seal segment: %w", err)
+	}
+	l.pruneLocked()
+	return nil
+}
+
+func segmentPath(dir string, stamp int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", segmentPrefix, stamp, segmentSuffix))
+}
+
+// pruneLocked drops the oldest segments beyond the retention limit.
+func (l *Log) pruneLocked() {
+	segs, err := l.segments()
+	if err != nil || len(segs) <= l.maxSegments {
+		return
+	}
+	for _, seg := range segs[:len(segs)-l.maxSegments] {
+		log.Printf("[pop] retention limit reached, dropping %s", filepath.Base(seg))
+		os.Remove(seg)
+	}
+}
+
+// Segments returns the sealed segment files awaiting upload, oldest
+// first.
+func (l *Log) Segments() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.segments()
+}
+
+func (l *Log) segments() ([]string, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, segmentPrefix) && strings.HasSuffix(name, segmentSuffix) {
+			segs = append(segs, filepath.Join(l.dir, name))
+		}
+	}
+	sort.Strings(segs) // zero-padded timestamps sort chronologically
+	return segs, nil
+}
+
+// Close flushes queued events and closes the active file. The active
+// file is left in place and sealed on the next OpenLog.
+func (l *Log) Close() error {
+	l.qmu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.qmu.Unlock()
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		err := l.f.Close()
+		l.f = nil
+		return err
+	}
+	return nil
+}
+
+// checksum returns the SHA-256 of path, hashing only when the file's
+// size or mtime changed since the last call.
+func (l *Log) checksum(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if h, ok := l.hashes[path]; ok && h.size == info.Size() && h.modTime.Equal(info.ModTime()) {
+		return h.sum
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return ""
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	l.hashes[path] = fileHash{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	return sum
+}
+
+// readSegment parses a segment file. A truncated last line (from a
+// crash mid-write) is skipped rather than failing the whole segment.
+func readSegment(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			log.Printf("[pop] %s: skipping malformed line", filepath.Base(path))
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, sc.Err()
+}
+
+// newID returns a random record ID the server can use to discard
+// duplicates when a batch is retried.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
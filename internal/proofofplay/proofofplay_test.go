@@ -0,0 +1,260 @@
+package proofofplay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"player-native/internal/api"
+	"player-native/internal/media"
+	"player-native/internal/vlc"
+)
+
+func event(path string, completed bool) vlc.PlayEvent {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	return vlc.PlayEvent{
+		ZoneID:    "main",
+		Item:      media.Item{Path: path},
+		Start:     start,
+		End:       start.Add(10 * time.Second),
+		Completed: completed,
+	}
+}
+
+// TestLogWritesAndRotates records events, closes the log to flush the
+// queue, and checks rotation by size and sealing on reopen.
+func TestLogWritesAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "ad.mp4")
+	os.WriteFile(file, []byte("spot"), 0644)
+
+	l, err := OpenLog(dir, WithMaxBytes(600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		l.Record(event(file, i%2 == 0))
+	}
+	l.Close()
+	l.Record(event(file, true)) // after Close: ignored, must not panic
+
+	segs, _ := l.Segments()
+	if len(segs) == 0 {
+		t.Fatal("expected the size limit to seal at least one segment")
+	}
+
+	// Reopening seals whatever was left in the active file.
+	l, err = OpenLog(dir, WithMaxBytes(600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	segs, _ = l.Segments()
+
+	var records []Record
+	for _, seg := range segs {
+		rs, err := readSegment(seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rs...)
+	}
+	if len(records) != 5 {
+		t.Fatalf("expected 5 records, got %d", len(records))
+	}
+	r := records[0]
+	if r.ZoneID != "main" || r.File != "ad.mp4" || r.Status != StatusCompleted || r.Duration != 10 {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if sum := sha256.Sum256([]byte("spot")); r.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected checksum %q", r.Checksum)
+	}
+	if records[1].Status != StatusInterrupted {
+		t.Errorf("expected interrupted, got %q", records[1].Status)
+	}
+	if records[0].ID == records[1].ID {
+		t.Error("record IDs should be unique")
+	}
+}
+
+// TestLogRetention drops the oldest segments beyond the limit.
+func TestLogRetention(t *testing.T) {
+	l, err := OpenLog(t.TempDir(), WithMaxBytes(1), WithMaxSegments(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := l.write(Record{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	segs, _ := l.Segments()
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(segs))
+	}
+	rs, _ := readSegment(segs[0])
+	if len(rs) != 1 || rs[0].ID != "3" {
+		t.Fatalf("expected oldest kept record 3, got %+v", rs)
+	}
+}
+
+// popServer accepts or fails uploads on demand.
+type popServer struct {
+	mu       sync.Mutex
+	status   int
+	received []Record
+}
+
+func (ps *popServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if r.URL.Path != "/proof-of-play" || r.Header.Get("X-Player-ID") != "p1" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if ps.status != 0 {
+		w.WriteHeader(ps.status)
+		return
+	}
+	var b batch
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ps.received = append(ps.received, b.Records...)
+}
+
+func newTestClient(t *testing.T, endpoint string) *api.Client {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	cfg := fmt.Sprintf(`{"id": "p1", "key": "k", "endpoint": %q}`, endpoint)
+	os.WriteFile(cfgPath, []byte(cfg), 0644)
+	client, err := api.NewClient(cfgPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestUploadBuffersWhileOffline keeps segments while the server fails
+// and uploads them, in batches, once it recovers.
+func TestUploadBuffersWhileOffline(t *testing.T) {
+	ps := &popServer{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(ps)
+	defer ts.Close()
+
+	l, err := OpenLog(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < BatchSize+10; i++ {
+		l.write(Record{ID: fmt.Sprint(i), ZoneID: "main"})
+	}
+
+	u := NewUploader(l, newTestClient(t, ts.URL))
+	if _, err := u.Flush(context.Background()); err == nil {
+		t.Fatal("expected an error while the server is down")
+	}
+	if segs, _ := l.Segments(); len(segs) != 1 {
+		t.Fatalf("expected the segment to be kept, got %d", len(segs))
+	}
+
+	l.write(Record{ID: "late", ZoneID: "main"})
+	ps.mu.Lock()
+	ps.status = 0
+	ps.mu.Unlock()
+
+	n, err := u.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != BatchSize+11 || len(ps.received) != BatchSize+11 {
+		t.Fatalf("expected %d records uploaded, got %d (server saw %d)", BatchSize+11, n, len(ps.received))
+	}
+	if segs, _ := l.Segments(); len(segs) != 0 {
+		t.Fatalf("expected uploaded segments to be removed, got %d", len(segs))
+	}
+}
+
+// TestUploadRetriesKeepRecords checks that a long outage does not
+// drop records: failed attempts must not seal a segment each, which
+// would hit the segment retention limit.
+func TestUploadRetriesKeepRecords(t *testing.T) {
+	ps := &popServer{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(ps)
+	defer ts.Close()
+
+	l, err := OpenLog(t.TempDir(), WithMaxSegments(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	u := NewUploader(l, newTestClient(t, ts.URL))
+	const attempts = 20
+	for i := 0; i < attempts; i++ {
+		l.write(Record{ID: fmt.Sprint(i), ZoneID: "main"})
+		if _, err := u.Flush(context.Background()); err == nil {
+			t.Fatal("expected an error while the server is down")
+		}
+	}
+	if segs, _ := l.Segments(); len(segs) != 1 {
+		t.Fatalf("expected one segment after %d failed flushes, got %d", attempts, len(segs))
+	}
+
+	ps.mu.Lock()
+	ps.status = 0
+	ps.mu.Unlock()
+	n, err := u.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != attempts || len(ps.received) != attempts {
+		t.Fatalf("expected %d records uploaded, got %d (server saw %d)", attempts, n, len(ps.received))
+	}
+	for i, r := range ps.received {
+		if r.ID != fmt.Sprint(i) {
+			t.Fatalf("record %d: got ID %q", i, r.ID)
+		}
+	}
+}
+
+// TestUploadSetsAsideRejected moves a segment the server refuses out of
+// the queue so it does not block newer records.
+func TestUploadSetsAsideRejected(t *testing.T) {
+	ps := &popServer{status: http.StatusUnprocessableEntity}
+	ts := httptest.NewServer(ps)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	l, err := OpenLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.write(Record{ID: "bad"})
+
+	u := NewUploader(l, newTestClient(t, ts.URL))
+	if _, err := u.Flush(context.Background()); err != nil {
+		t.Fatalf("rejection should not fail the flush: %v", err)
+	}
+	if segs, _ := l.Segments(); len(segs) != 0 {
+		t.Fatalf("expected no pending segments, got %d", len(segs))
+	}
+	rejected, _ := filepath.Glob(filepath.Join(dir, "*.rejected"))
+	if len(rejected) != 1 {
+		t.Fatalf("expected 1 rejected segment, got %d", len(rejected))
+	}
+}
@@ -0,0 +1,201 @@
+package proofofplay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"player-native/internal/api"
+)
+
+// Upload tuning. Records are sent in batches of at most BatchSize; after
+// a failed attempt the uploader retries with exponential backoff
+// starting at minBackoff, capped at the regular interval.
+const (
+	DefaultUploadInterval = 5 * time.Minute
+	BatchSize             = 500
+	minBackoff            = 15 * time.Second
+)
+
+// errRejected marks a batch the server refused outright; retrying it
+// would never succeed.
+var errRejected = errors.New("rejected by server")
+
+// batch is the request body POSTed to {endpoint}/proof-of-play.
+type batch struct {
+	Records []Record `json:"records"`
+}
+
+// Uploader periodically uploads every pending segment, oldest first,
+// and then the active log file. A segment is deleted only after all of
+// its batches are accepted; when the server is unreachable records
+// accumulate on disk (up to the log's retention limit) and are sent
+// once connectivity returns. Record IDs let the server drop duplicates
+// if a partially uploaded segment is sent again.
+type Uploader struct {
+	log    *Log
+	api    *api.Client
+	stopCh chan struct{}
+}
+
+// NewUploader creates an uploader that sends l's records through client.
+func NewUploader(l *Log, client *api.Client) *Uploader {
+	return &Uploader{
+		log:    l,
+		api:    client,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start uploads every interval (DefaultUploadInterval if <= 0), backing
+// off between retries while uploads fail. It blocks until Stop() is
+// called.
+func (u *Uploader) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultUploadInterval
+	}
+	log.Printf("[pop] uploader started (every %s)", interval)
+
+	backoff := minBackoff
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-u.stopCh:
+			log.Println("[pop] uploader stopped")
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		n, err := u.Flush(ctx)
+		cancel()
+
+		next := interval
+		if err != nil {
+			log.Printf("[pop] upload failed after %d record(s): %v (retry in %s)", n, err, backoff)
+			next = backoff
+			backoff = min(backoff*2, interval)
+		} else {
+			if n > 0 {
+				log.Printf("[pop] uploaded %d record(s)", n)
+			}
+			backoff = minBackoff
+		}
+		timer.Reset(next)
+	}
+}
+
+// Flush uploads all pending segments and then seals and uploads the
+// active file. It stops at the first transport or server error, leaving
+// the remaining records for the next attempt, and returns the number of
+// records accepted.
+//
+// The active file is sealed only once the older segments are through:
+// sealing on every failed attempt would turn an outage into one small
+// segment per retry and reach the segment retention limit long before
+// the log holds much data.
+func (u *Uploader) Flush(ctx context.Context) (int, error) {
+	sent, err := u.uploadSegments(ctx)
+	if err != nil {
+		return sent, err
+	}
+	if err := u.log.Rotate(); err != nil {
+		return sent, err
+	}
+	n, err := u.uploadSegments(ctx)
+	return sent + n, err
+}
+
+// uploadSegments uploads the sealed segments, oldest first.
+func (u *Uploader) uploadSegments(ctx context.Context) (int, error) {
+	segs, err := u.log.Segments()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, seg := range segs {
+		n, err := u.uploadSegment(ctx, seg)
+		sent += n
+		if errors.Is(err, errRejected) {
+			// Keep the data for inspection but stop retrying it.
+			log.Printf("[pop] %s: %v, set aside", filepath.Base(seg), err)
+			os.Rename(seg, seg+".rejected")
+			continue
+		}
+		if err != nil {
+			return sent, err
+		}
+		os.Remove(seg)
+	}
+	return sent, nil
+}
+
+// uploadSegment sends one segment in batches.
+func (u *Uploader) uploadSegment(ctx context.Context, path string) (int, error) {
+	records, err := readSegment(path)
+	if err != nil {
+		return 0, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+
+	sent := 0
+	for len(records) > 0 {
+		n := min(len(records), BatchSize)
+		if err := u.post(ctx, records[:n]); err != nil {
+			return sent, err
+		}
+		sent += n
+		records = records[n:]
+	}
+	return sent, nil
+}
+
+// post sends one batch. 400, 413 and 422 mean the server will never
+// accept the batch and are reported as errRejected; any other failure
+// (including auth errors, which a config fix resolves) is retried.
+func (u *Uploader) post(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(batch{Records: records})
+	if err != nil {
+		return err
+	}
+
+	req, err := u.api.NewRequest(ctx, http.MethodPost, "/proof-of-play", body)
+	if err != nil {
+		return err
+	}
+	resp, err := u.api.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusBadRequest ||
+		resp.StatusCode == http.StatusRequestEntityTooLarge ||
+		resp.StatusCode == http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", errRejected, resp.Status)
+	default:
+		return fmt.Errorf("server responded %s", resp.Status)
+	}
+}
+
+// Stop halts the upload loop. Pending segments stay on disk.
+func (u *Uploader) Stop() {
+	select {
+	case <-u.stopCh:
+	default:
+		close(u.stopCh)
+	}
+}
@@ -0,0 +1,271 @@
+// Package schedule implements dayparting for zone playlists. A zone's
+// schedule is a list of time windows, each pointing at a playlist
+// directory; the Scheduler switches zones between directories as
+// windows open and close.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a recurring time range during which PlaylistDir is active.
+//
+// Start and End are "HH:MM" in the schedule's timezone. An End earlier
+// than Start wraps past midnight ("22:00"–"02:00"); "24:00" is allowed
+// as an End. Days and the From/Until date range ("YYYY-MM-DD",
+// inclusive) are optional and apply to the day the window starts.
+type Window struct {
+	Name        string   `json:"name"`
+	Days        []string `json:"days,omitempty"`
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	From        string   `json:"from,omitempty"`
+	Until       string   `json:"until,omitempty"`
+	PlaylistDir string   `json:"playlist_dir"`
+
+	startMin int
+	endMin   int
+	days     map[time.Weekday]bool
+	from     civilDate
+	until    civilDate
+}
+
+// Schedule is a zone's dayparting configuration. Windows are checked
+// in order and the first match wins; when none match, the zone falls
+// back to its default playlist directory.
+type Schedule struct {
+	Timezone string   `json:"timezone,omitempty"`
+	Windows  []Window `json:"windows"`
+
+	loc *time.Location
+}
+
+// civilDate is a calendar date without a time or zone. The zero value
+// means "unbounded".
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Validate parses all windows and the timezone. It must succeed before
+// Active or NextChange are called; LoadFromFile in the template package
+// calls it for every zone schedule.
+func (s *Schedule) Validate() error {
+	loc := time.Local
+	if s.Timezone != "" {
+		l, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("schedule timezone %q: %w", s.Timezone, err)
+		}
+		loc = l
+	}
+	s.loc = loc
+
+	for i := range s.Windows {
+		if err := s.Windows[i].parse(); err != nil {
+			name := s.Windows[i].Name
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			return fmt.Errorf("schedule window %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (w *Window) parse() error {
+	if w.PlaylistDir == "" {
+		return fmt.Errorf("missing playlist_dir")
+	}
+
+	start, err := parseClock(w.Start, "00:00")
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	end, err := parseClock(w.End, "24:00")
+	if err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("start and end are equal (%s)", w.Start)
+	}
+	if start == 24*60 {
+		return fmt.Errorf("start cannot be 24:00")
+	}
+	w.startMin, w.endMin = start, end
+
+	w.days = nil
+	if len(w.Days) > 0 {
+		w.days = make(map[time.Weekday]bool)
+		for _, d := range w.Days {
+			wd, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]
+			if !ok {
+				return fmt.Errorf("unknown day %q", d)
+			}
+			w.days[wd] = true
+		}
+	}
+
+	if w.from, err = parseDate(w.From); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	if w.until, err = parseDate(w.Until); err != nil {
+		return fmt.Errorf("until: %w", err)
+	}
+	if !w.from.isZero() && !w.until.isZero() && w.until.before(w.from) {
+		return fmt.Errorf("until %s is before from %s", w.Until, w.From)
+	}
+	return nil
+}
+
+// Active returns the first window that covers t, if any.
+func (s *Schedule) Active(t time.Time) (Window, bool) {
+	t = t.In(s.location())
+	for _, w := range s.Windows {
+		if w.covers(t) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// NextChange returns the earliest window boundary strictly after t.
+// The active window may be unchanged at that instant (for example when
+// two windows share an edge); callers should re-evaluate with Active.
+func (s *Schedule) NextChange(t time.Time) time.Time {
+	loc := s.location()
+	t = t.In(loc)
+
+	var next time.Time
+	consider := func(c time.Time) {
+		if c.After(t) && (next.IsZero() || c.Before(next)) {
+			next = c
+		}
+	}
+
+	// A week plus a day on either side covers every weekly boundary.
+	y, m, d := t.Date()
+	for offset := -1; offset <= 8; offset++ {
+		for _, w := range s.Windows {
+			consider(atMinute(y, m, d+offset, w.startMin, loc))
+			end := w.endMin
+			if w.endMin <= w.startMin {
+				end += 24 * 60
+			}
+			consider(atMinute(y, m, d+offset, end, loc))
+		}
+	}
+
+	for _, w := range s.Windows {
+		if !w.from.isZero() {
+			consider(atMinute(w.from.year, w.from.month, w.from.day, 0, loc))
+		}
+		if !w.until.isZero() {
+			consider(atMinute(w.until.year, w.until.month, w.until.day+1, 0, loc))
+		}
+	}
+
+	return next
+}
+
+func (s *Schedule) location() *time.Location {
+	if s.loc == nil {
+		return time.Local
+	}
+	return s.loc
+}
+
+// covers reports whether t (already in the schedule's location) falls
+// inside the window.
+func (w *Window) covers(t time.Time) bool {
+	tod := t.Hour()*60 + t.Minute()
+	overnight := w.endMin <= w.startMin
+
+	if !overnight {
+		return tod >= w.startMin && tod < w.endMin && w.dayAllowed(t)
+	}
+	if tod >= w.startMin {
+		return w.dayAllowed(t)
+	}
+	if tod < w.endMin {
+		// After midnight: the window belongs to the previous day.
+		return w.dayAllowed(t.AddDate(0, 0, -1))
+	}
+	return false
+}
+
+func (w *Window) dayAllowed(t time.Time) bool {
+	if w.days != nil && !w.days[t.Weekday()] {
+		return false
+	}
+	y, m, d := t.Date()
+	day := civilDate{y, m, d}
+	if !w.from.isZero() && day.before(w.from) {
+		return false
+	}
+	if !w.until.isZero() && w.until.before(day) {
+		return false
+	}
+	return true
+}
+
+func atMinute(y int, m time.Month, d, minute int, loc *time.Location) time.Time {
+	return time.Date(y, m, d, minute/60, minute%60, 0, 0, loc)
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s, def string) (int, error) {
+	if s == "" {
+		s = def
+	}
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return h*60 + m, nil
+}
+
+func parseDate(s string) (civilDate, error) {
+	if s == "" {
+		return civilDate{}, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return civilDate{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD)", s)
+	}
+	y, m, d := t.Date()
+	return civilDate{y, m, d}, nil
+}
+
+func (c civilDate) isZero() bool {
+	return c.year == 0
+}
+
+func (c civilDate) before(o civilDate) bool {
+	if c.year != o.year {
+		return c.year < o.year
+	}
+	if c.month != o.month {
+		return c.month < o.month
+	}
+	return c.day < o.day
+}
@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustSchedule(t *testing.T, s *Schedule) *Schedule {
+	t.Helper()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestActiveDaypart verifies breakfast/lunch windows select the right
+// directory and that times outside all windows match nothing.
+func TestActiveDaypart(t *testing.T) {
+	s := mustSchedule(t, &Schedule{
+		Timezone: "UTC",
+		Windows: []Window{
+			{Name: "breakfast", Start: "06:00", End: "11:00", PlaylistDir: "/p/breakfast"},
+			{Name: "lunch", Start: "11:00", End: "16:00", PlaylistDir: "/p/lunch"},
+		},
+	})
+
+	cases := []struct {
+		at   string
+		want string
+	}{
+		{"2026-03-02T05:59:00Z", ""},
+		{"2026-03-02T06:00:00Z", "/p/breakfast"},
+		{"2026-03-02T10:59:59Z", "/p/breakfast"},
+		{"2026-03-02T11:00:00Z", "/p/lunch"},
+		{"2026-03-02T16:00:00Z", ""},
+	}
+	for _, c := range cases {
+		at, _ := time.Parse(time.RFC3339, c.at)
+		w, ok := s.Active(at)
+		got := ""
+		if ok {
+			got = w.PlaylistDir
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.at, c.want, got)
+		}
+	}
+}
+
+// TestOvernightWindowUsesStartDay ensures the after-midnight part of a
+// wrapping window is attributed to the day it started.
+func TestOvernightWindowUsesStartDay(t *testing.T) {
+	s := mustSchedule(t, &Schedule{
+		Timezone: "UTC",
+		Windows: []Window{
+			{Start: "22:00", End: "02:00", Days: []string{"fri"}, PlaylistDir: "/p/late"},
+		},
+	})
+
+	// 2026-03-06 is a Friday.
+	fri, _ := time.Parse(time.RFC3339, "2026-03-06T23:00:00Z")
+	satEarly, _ := time.Parse(time.RFC3339, "2026-03-07T01:30:00Z")
+	sunEarly, _ := time.Parse(time.RFC3339, "2026-03-08T01:30:00Z")
+
+	if _, ok := s.Active(fri); !ok {
+		t.Error("expected window active on Friday night")
+	}
+	if _, ok := s.Active(satEarly); !ok {
+		t.Error("expected window active early Saturday (Friday's window)")
+	}
+	if _, ok := s.Active(sunEarly); ok {
+		t.Error("expected window inactive early Sunday")
+	}
+}
+
+// TestDateRangeAndTimezone checks inclusive date bounds evaluated in
+// the schedule's timezone.
+func TestDateRangeAndTimezone(t *testing.T) {
+	s := mustSchedule(t, &Schedule{
+		Timezone: "Asia/Manila",
+		Windows: []Window{
+			{Start: "00:00", End: "24:00", From: "2026-12-24", Until: "2026-12-25", PlaylistDir: "/p/xmas"},
+		},
+	})
+
+	// 2026-12-23T16:00Z is midnight on the 24th in Manila (UTC+8).
+	before, _ := time.Parse(time.RFC3339, "2026-12-23T15:59:00Z")
+	start, _ := time.Parse(time.RFC3339, "2026-12-23T16:00:00Z")
+	last, _ := time.Parse(time.RFC3339, "2026-12-25T15:59:00Z")
+	after, _ := time.Parse(time.RFC3339, "2026-12-25T16:00:00Z")
+
+	if _, ok := s.Active(before); ok {
+		t.Error("expected inactive before range")
+	}
+	if _, ok := s.Active(start); !ok {
+		t.Error("expected active at start of range")
+	}
+	if _, ok := s.Active(last); !ok {
+		t.Error("expected active on last day")
+	}
+	if _, ok := s.Active(after); ok {
+		t.Error("expected inactive after range")
+	}
+}
+
+// TestNextChange verifies the next boundary is the closest window edge.
+func TestNextChange(t *testing.T) {
+	s := mustSchedule(t, &Schedule{
+		Timezone: "UTC",
+		Windows: []Window{
+			{Start: "06:00", End: "11:00", PlaylistDir: "/p/a"},
+		},
+	})
+
+	at, _ := time.Parse(time.RFC3339, "2026-03-02T07:30:00Z")
+	want, _ := time.Parse(time.RFC3339, "2026-03-02T11:00:00Z")
+	if got := s.NextChange(at); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	at, _ = time.Parse(time.RFC3339, "2026-03-02T12:00:00Z")
+	want, _ = time.Parse(time.RFC3339, "2026-03-03T06:00:00Z")
+	if got := s.NextChange(at); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+// TestValidateRejectsBadWindows covers common configuration mistakes.
+func TestValidateRejectsBadWindows(t *testing.T) {
+	bad := []Window{
+		{Start: "06:00", End: "11:00"},
+		{Start: "6am", End: "11:00", PlaylistDir: "/p"},
+		{Start: "06:00", End: "06:00", PlaylistDir: "/p"},
+		{Start: "06:00", End: "11:00", Days: []string{"funday"}, PlaylistDir: "/p"},
+		{Start: "06:00", End: "11:00", From: "2026-05-01", Until: "2026-04-01", PlaylistDir: "/p"},
+	}
+	for i, w := range bad {
+		s := &Schedule{Windows: []Window{w}}
+		if err := s.Validate(); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+
+	if err := (&Schedule{Timezone: "Mars/Olympus"}).Validate(); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+}
+
+// TestSchedulerSwitchesAtBoundary drives tick() with a fake clock and
+// checks that SwitchFunc fires once when a window opens.
+func TestSchedulerSwitchesAtBoundary(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2026-03-02T10:59:00Z")
+
+	var switches []string
+	s := NewScheduler(func(zoneID, dir string) {
+		switches = append(switches, zoneID+"="+dir)
+	})
+	s.now = func() time.Time { return now }
+
+	dir, err := s.Add("main", "/p/default", &Schedule{
+		Timezone: "UTC",
+		Windows:  []Window{{Start: "11:00", End: "16:00", PlaylistDir: "/p/lunch"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "/p/default" {
+		t.Fatalf("expected default dir before window, got %s", dir)
+	}
+
+	if wait := s.tick(); wait != time.Minute {
+		t.Errorf("expected to sleep until boundary (1m), got %s", wait)
+	}
+	if len(switches) != 0 {
+		t.Fatalf("unexpected switches: %v", switches)
+	}
+
+	now = now.Add(time.Minute)
+	s.tick()
+	s.tick()
+	if len(switches) != 1 || switches[0] != "main=/p/lunch" {
+		t.Fatalf("expected one switch to lunch, got %v", switches)
+	}
+	if got, _ := s.ActiveDir("main"); got != "/p/lunch" {
+		t.Errorf("expected active dir /p/lunch, got %s", got)
+	}
+}
@@ -0,0 +1,169 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// SwitchFunc is invoked when a zone's active playlist directory changes.
+type SwitchFunc func(zoneID, dir string)
+
+// maxSleep bounds how long the scheduler waits between evaluations so
+// that wall-clock jumps (e.g. NTP sync after boot) are picked up.
+const maxSleep = time.Minute
+
+type zoneSchedule struct {
+	id         string
+	defaultDir string
+	sched      *Schedule
+	activeDir  string
+}
+
+// Scheduler tracks the schedules of all zones and fires SwitchFunc at
+// window boundaries.
+type Scheduler struct {
+	mu       sync.Mutex
+	zones    []*zoneSchedule
+	onSwitch SwitchFunc
+	now      func() time.Time
+	stopCh   chan struct{}
+	wakeCh   chan struct{}
+}
+
+// NewScheduler creates a scheduler that reports directory changes via
+// onSwitch.
+func NewScheduler(onSwitch SwitchFunc) *Scheduler {
+	return &Scheduler{
+		onSwitch: onSwitch,
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+		wakeCh:   make(chan struct{}, 1),
+	}
+}
+
+// Add registers a zone schedule. defaultDir is used whenever no window
+// is active. The schedule is validated and the directory that should
+// be active right now is returned, so callers can start the zone on
+// the correct playlist without waiting for the first tick.
+func (s *Scheduler) Add(zoneID, defaultDir string, sched *Schedule) (string, error) {
+	if err := sched.Validate(); err != nil {
+		return "", fmt.Errorf("zone %s: %w", zoneID, err)
+	}
+
+	zs := &zoneSchedule{
+		id:         zoneID,
+		defaultDir: defaultDir,
+		sched:      sched,
+	}
+	zs.activeDir = zs.dirAt(s.now())
+
+	s.mu.Lock()
+	s.zones = append(s.zones, zs)
+	s.mu.Unlock()
+
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+
+	log.Printf("[schedule] zone %q: %d window(s), active dir %s", zoneID, len(sched.Windows), zs.activeDir)
+	return zs.activeDir, nil
+}
+
+// Remove unregisters a zone's schedule.
+func (s *Scheduler) Remove(zoneID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, zs := range s.zones {
+		if zs.id == zoneID {
+			s.zones = append(s.zones[:i], s.zones[i+1:]...)
+			return
+		}
+	}
+}
+
+// ActiveDir returns the currently selected directory for a zone.
+func (s *Scheduler) ActiveDir(zoneID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, zs := range s.zones {
+		if zs.id == zoneID {
+			return zs.activeDir, true
+		}
+	}
+	return "", false
+}
+
+// Start runs the scheduling loop. It blocks until Stop() is called.
+func (s *Scheduler) Start() {
+	log.Println("[schedule] started")
+	for {
+		wait := s.tick()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.stopCh:
+			timer.Stop()
+			log.Println("[schedule] stopped")
+			return
+		case <-s.wakeCh:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Stop halts the scheduling loop.
+func (s *Scheduler) Stop() {
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+}
+
+// tick re-evaluates every zone, fires switches, and returns how long to
+// sleep until the next boundary.
+func (s *Scheduler) tick() time.Duration {
+	now := s.now()
+
+	type change struct{ zoneID, dir string }
+	var changes []change
+	wait := maxSleep
+
+	s.mu.Lock()
+	for _, zs := range s.zones {
+		dir := zs.dirAt(now)
+		if dir != zs.activeDir {
+			zs.activeDir = dir
+			changes = append(changes, change{zs.id, dir})
+		}
+		if next := zs.sched.NextChange(now); !next.IsZero() {
+			if d := next.Sub(now); d < wait {
+				wait = d
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range changes {
+		log.Printf("[schedule] zone %q switching to %s", c.zoneID, c.dir)
+		if s.onSwitch != nil {
+			s.onSwitch(c.zoneID, c.dir)
+		}
+	}
+
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+func (zs *zoneSchedule) dirAt(t time.Time) string {
+	if w, ok := zs.sched.Active(t); ok {
+		return w.PlaylistDir
+	}
+	return zs.defaultDir
+}
@@ -0,0 +1,217 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Display sources.
+const (
+	DisplayCRTC        = "crtc"        // active mode of a DRM CRTC (debugfs)
+	DisplayFramebuffer = "framebuffer" // size of /dev/fb0
+	DisplayDRM         = "drm"         // preferred mode of a connected DRM connector
+	DisplayFlags       = "flags"       // --screen-width/--screen-height or their defaults
+)
+
+// Display describes the screen the player renders to.
+type Display struct {
+	Connector string  `json:"connector,omitempty"` // e.g. "HDMI-A-1"
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	RefreshHz float64 `json:"refresh_hz,omitempty"`
+	Source    string  `json:"source"`
+}
+
+func (d Display) String() string {
+	s := fmt.Sprintf("%dx%d", d.Width, d.Height)
+	if d.RefreshHz > 0 {
+		s += fmt.Sprintf("@%gHz", d.RefreshHz)
+	}
+	if d.Connector != "" {
+		s += " on " + d.Connector
+	}
+	return s + " (" + d.Source + ")"
+}
+
+// sysRoot is where sysfs is mounted.
+const sysRoot = "/sys"
+
+// DetectDisplay finds the mode the attached screen is running in: the
+// active CRTC mode from DRM debugfs when it is readable, else the
+// framebuffer size (which follows fbset and SetResolution), and only
+// as a last resort the preferred mode of the first connected DRM
+// connector, which may not be the mode in use. The connector name and,
+// where the source lacks it, the refresh rate come from that connector
+// and its EDID.
+func DetectDisplay() (Display, error) {
+	return detectDisplay(sysRoot)
+}
+
+func detectDisplay(root string) (Display, error) {
+	conn, connected := drmConnector(filepath.Join(root, "class", "drm"))
+
+	if w, h, hz, ok := crtcMode(filepath.Join(root, "kernel", "debug", "dri")); ok {
+		return Display{Connector: conn.name, Width: w, Height: h, RefreshHz: hz, Source: DisplayCRTC}, nil
+	}
+	data, err := os.ReadFile(filepath.Join(root, "class", "graphics", "fb0", "virtual_size"))
+	if err == nil {
+		// "1920,1080"
+		w, h, ok := strings.Cut(strings.TrimSpace(string(data)), ",")
+		fw, err1 := strconv.Atoi(w)
+		fh, err2 := strconv.Atoi(h)
+		if ok && err1 == nil && err2 == nil && fw > 0 && fh > 0 {
+			return Display{Connector: conn.name, Width: fw, Height: fh, RefreshHz: edidRefresh(conn.edid, fw, fh), Source: DisplayFramebuffer}, nil
+		}
+	}
+	if connected && conn.width > 0 {
+		return Display{
+			Connector: conn.name,
+			Width:     conn.width,
+			Height:    conn.height,
+			RefreshHz: edidRefresh(conn.edid, conn.width, conn.height),
+			Source:    DisplayDRM,
+		}, nil
+	}
+	return Display{}, errors.New("no connected display found")
+}
+
+// connector is a connected DRM connector and its preferred mode (the
+// first line of its "modes" file, 0x0 if it lists none).
+type connector struct {
+	name          string
+	width, height int
+	edid          []byte
+}
+
+// drmConnector returns the first connected connector.
+func drmConnector(dir string) (connector, bool) {
+	conns, _ := filepath.Glob(filepath.Join(dir, "card*-*"))
+	sort.Strings(conns)
+	for _, c := range conns {
+		status, err := os.ReadFile(filepath.Join(c, "status"))
+		if err != nil || strings.TrimSpace(string(status)) != "connected" {
+			continue
+		}
+
+		// card1-HDMI-A-1 → HDMI-A-1
+		_, name, _ := strings.Cut(filepath.Base(c), "-")
+		conn := connector{name: name}
+		if modes, err := os.ReadFile(filepath.Join(c, "modes")); err == nil {
+			first, _, _ := strings.Cut(strings.TrimSpace(string(modes)), "\n")
+			conn.width, conn.height, _ = parseMode(first)
+		}
+		conn.edid, _ = os.ReadFile(filepath.Join(c, "edid"))
+		return conn, true
+	}
+	return connector{}, false
+}
+
+// crtcMode returns the mode of the first active CRTC in a DRM debugfs
+// "state" file under dir (readable by root only), where a CRTC block
+// has lines such as
+//
+//	crtc[99]: crtc-0
+//		active=1
+//		mode: "1920x1080": 60 148500 1920 2008 2052 2200 1080 1084 1089 1125 0x48 0x5
+//
+// The numbers after the name are vrefresh, clock (kHz), then hdisplay,
+// hsync start and end, htotal, and the same four vertically.
+func crtcMode(dir string) (w, h int, hz float64, ok bool) {
+	states, _ := filepath.Glob(filepath.Join(dir, "*", "state"))
+	sort.Strings(states)
+	for _, path := range states {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		active := false
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "crtc["):
+				active = false
+			case line == "active=1":
+				active = true
+			case active && strings.HasPrefix(line, "mode: "):
+				_, rest, found := strings.Cut(line, "\": ")
+				f := strings.Fields(rest)
+				if !found || len(f) < 10 {
+					continue
+				}
+				var n [10]int
+				valid := true
+				for i := range n {
+					v, err := strconv.Atoi(f[i])
+					n[i] = v
+					valid = valid && err == nil
+				}
+				clock, hdisp, htotal, vdisp, vtotal := n[1], n[2], n[5], n[6], n[9]
+				if !valid || hdisp <= 0 || vdisp <= 0 || htotal <= 0 || vtotal <= 0 {
+					continue
+				}
+				hz = math.Round(float64(clock)*1000/float64(htotal*vtotal)*100) / 100
+				return hdisp, vdisp, hz, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// parseMode parses a DRM mode name such as "3840x2160" or "1920x1080i".
+func parseMode(s string) (w, h int, ok bool) {
+	ws, hs, found := strings.Cut(s, "x")
+	if !found {
+		return 0, 0, false
+	}
+	hs = strings.TrimRight(hs, "abcdefghijklmnopqrstuvwxyz")
+	w, err1 := strconv.Atoi(ws)
+	h, err2 := strconv.Atoi(hs)
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// edidRefresh returns the refresh rate of the first progressive detailed
+// timing in the EDID (base block and CTA-861 extensions) that matches
+// w x h, rounded to 0.01 Hz, or 0 if there is none.
+func edidRefresh(edid []byte, w, h int) float64 {
+	var timings [][]byte
+	if len(edid) >= 128 {
+		for off := 54; off+18 <= 126; off += 18 {
+			timings = append(timings, edid[off:off+18])
+		}
+	}
+	for base := 128; base+128 <= len(edid); base += 128 {
+		ext := edid[base : base+128]
+		if ext[0] != 0x02 || ext[2] < 4 {
+			continue // not a CTA extension, or no timings
+		}
+		for off := int(ext[2]); off+18 <= 127; off += 18 {
+			timings = append(timings, ext[off:off+18])
+		}
+	}
+
+	for _, dtd := range timings {
+		clock := int(dtd[0]) | int(dtd[1])<<8 // in 10 kHz units; 0 marks a descriptor
+		if clock == 0 || dtd[17]&0x80 != 0 {
+			continue
+		}
+		hActive := int(dtd[2]) | int(dtd[4]&0xf0)<<4
+		hBlank := int(dtd[3]) | int(dtd[4]&0x0f)<<8
+		vActive := int(dtd[5]) | int(dtd[7]&0xf0)<<4
+		vBlank := int(dtd[6]) | int(dtd[7]&0x0f)<<8
+		if hActive != w || vActive != h {
+			continue
+		}
+		hz := float64(clock) * 10000 / float64((hActive+hBlank)*(vActive+vBlank))
+		return math.Round(hz*100) / 100
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSys(t *testing.T, root, rel string, data []byte) {
+	t.Helper()
+	p := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDetectDisplay: the active mode wins over the connector's preferred
+// one: the CRTC mode if debugfs is readable, else the framebuffer size.
+// The preferred mode, with the refresh rate from the EDID, is the last
+// resort.
+func TestDetectDisplay(t *testing.T) {
+	root := t.TempDir()
+	writeSys(t, root, "class/drm/card1-HDMI-A-1/status", []byte("disconnected\n"))
+	writeSys(t, root, "class/drm/card1-HDMI-A-2/status", []byte("connected\n"))
+	writeSys(t, root, "class/drm/card1-HDMI-A-2/modes", []byte("1920x1080\n1280x720\n"))
+
+	// 1920x1080, 148.5 MHz, 2200x1125 total: 60 Hz.
+	edid := make([]byte, 128)
+	copy(edid[54:], []byte{0x02, 0x3a, 0x80, 0x18, 0x71, 0x38, 0x2d, 0x40})
+	writeSys(t, root, "class/drm/card1-HDMI-A-2/edid", edid)
+
+	// The panel runs 1280x720 (74.25 MHz, 1650x750 total), not its
+	// preferred mode.
+	writeSys(t, root, "kernel/debug/dri/1/state", []byte(`plane[31]: plane-0
+	crtc=crtc-0
+crtc[98]: crtc-1
+	enable=0
+	active=0
+	mode: "": 0 0 0 0 0 0 0 0 0 0 0x0 0x0
+crtc[99]: crtc-0
+	enable=1
+	active=1
+	mode: "1280x720": 60 74250 1280 1390 1430 1650 720 725 730 750 0x40 0x5
+`))
+	writeSys(t, root, "class/graphics/fb0/virtual_size", []byte("1280,720\n"))
+
+	for _, tc := range []struct {
+		remove string
+		want   Display
+	}{
+		{"", Display{Connector: "HDMI-A-2", Width: 1280, Height: 720, RefreshHz: 60, Source: DisplayCRTC}},
+		{"kernel", Display{Connector: "HDMI-A-2", Width: 1280, Height: 720, Source: DisplayFramebuffer}},
+		{"class/graphics", Display{Connector: "HDMI-A-2", Width: 1920, Height: 1080, RefreshHz: 60, Source: DisplayDRM}},
+	} {
+		if tc.remove != "" {
+			os.RemoveAll(filepath.Join(root, tc.remove))
+		}
+		d, err := detectDisplay(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != tc.want {
+			t.Errorf("without %q: got %+v, want %+v", tc.remove, d, tc.want)
+		}
+	}
+
+	writeSys(t, root, "class/drm/card1-HDMI-A-2/status", []byte("disconnected\n"))
+	if _, err := detectDisplay(root); err == nil {
+		t.Error("expected an error with no display")
+	}
+}
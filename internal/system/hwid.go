@@ -0,0 +1,60 @@
+package system
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HardwareID returns a stable identifier for this device, used to
+// register it with the server: "rpi-<serial>" from the Raspberry Pi's
+// board serial number, or else "mid-" and a hash of the systemd
+// machine-id (which should not be shared verbatim).
+func HardwareID() (string, error) {
+	return hardwareID("/")
+}
+
+func hardwareID(root string) (string, error) {
+	if serial := piSerial(root); serial != "" {
+		return "rpi-" + serial, nil
+	}
+	for _, p := range []string{"etc/machine-id", "var/lib/dbus/machine-id"} {
+		data, err := os.ReadFile(filepath.Join(root, p))
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(data)); id != "" {
+			sum := sha256.Sum256([]byte("n-compasstv:" + id))
+			return "mid-" + hex.EncodeToString(sum[:8]), nil
+		}
+	}
+	return "", errors.New("no board serial or machine-id found")
+}
+
+// piSerial reads the board serial from the device tree, or the "Serial"
+// line of /proc/cpuinfo on older kernels. Serials of all zeros are
+// placeholders and ignored.
+func piSerial(root string) string {
+	var serial string
+	if data, err := os.ReadFile(filepath.Join(root, "sys/firmware/devicetree/base/serial-number")); err == nil {
+		serial = strings.Trim(string(data), "\x00 \n")
+	} else if f, err := os.Open(filepath.Join(root, "proc/cpuinfo")); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			k, v, ok := strings.Cut(sc.Text(), ":")
+			if ok && strings.TrimSpace(k) == "Serial" {
+				serial = strings.TrimSpace(v)
+				break
+			}
+		}
+		f.Close()
+	}
+	if strings.Trim(serial, "0") == "" {
+		return ""
+	}
+	return strings.ToLower(serial)
+}
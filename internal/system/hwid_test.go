@@ -0,0 +1,30 @@
+package system
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHardwareID: the board serial is preferred and used as is; the
+// machine-id is only ever reported hashed.
+func TestHardwareID(t *testing.T) {
+	root := t.TempDir()
+	writeSys(t, root, "etc/machine-id", []byte("0123456789abcdef0123456789abcdef\n"))
+	writeSys(t, root, "proc/cpuinfo", []byte("processor\t: 0\nSerial\t\t: 0000000000000000\n"))
+
+	id, err := hardwareID(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, "mid-") || strings.Contains(id, "0123456789abcdef") {
+		t.Errorf("expected a hashed machine-id with a placeholder serial, got %q", id)
+	}
+	if again, _ := hardwareID(root); again != id {
+		t.Errorf("hardware id not stable: %q then %q", id, again)
+	}
+
+	writeSys(t, root, "sys/firmware/devicetree/base/serial-number", []byte("10000000ABCD1234\x00"))
+	if id, _ := hardwareID(root); id != "rpi-10000000abcd1234" {
+		t.Errorf("expected the board serial, got %q", id)
+	}
+}
@@ -0,0 +1,36 @@
+package system
+
+import "net"
+
+// Addr is an IP address assigned to a network interface.
+type Addr struct {
+	Interface string `json:"interface"`
+	IP        string `json:"ip"`
+}
+
+// Addresses lists the IP addresses of the interfaces that are up,
+// skipping loopback and IPv6 link-local addresses.
+func Addresses() ([]Addr, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var out []Addr
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipn, ok := a.(*net.IPNet)
+			if !ok || ipn.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			out = append(out, Addr{Interface: iface.Name, IP: ipn.IP.String()})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package system
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Screenshot writes the contents of the framebuffer /dev/fb0 as a PNG.
+// Video shown on hardware overlay planes (DRM/KMS output) is not part
+// of the framebuffer and appears black.
+func Screenshot(w io.Writer) error {
+	return screenshot(w, filepath.Join(sysRoot, "class", "graphics", "fb0"), "/dev/fb0")
+}
+
+func screenshot(w io.Writer, sysDir, dev string) error {
+	readInt := func(name string) (int, error) {
+		data, err := os.ReadFile(filepath.Join(sysDir, name))
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+
+	size, err := os.ReadFile(filepath.Join(sysDir, "virtual_size"))
+	if err != nil {
+		return fmt.Errorf("framebuffer size: %w", err)
+	}
+	ws, hs, _ := strings.Cut(strings.TrimSpace(string(size)), ",")
+	width, err1 := strconv.Atoi(ws)
+	height, err2 := strconv.Atoi(hs)
+	bpp, err3 := readInt("bits_per_pixel")
+	if err1 != nil || err2 != nil || err3 != nil || width <= 0 || height <= 0 {
+		return fmt.Errorf("framebuffer geometry unreadable")
+	}
+	if bpp != 16 && bpp != 32 {
+		return fmt.Errorf("unsupported framebuffer depth %d", bpp)
+	}
+	stride, err := readInt("stride")
+	if err != nil {
+		stride = width * bpp / 8
+	}
+
+	f, err := os.Open(dev)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, stride*height)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return fmt.Errorf("read %s: %w", dev, err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := buf[y*stride:]
+		for x := 0; x < width; x++ {
+			var c color.RGBA
+			if bpp == 32 { // XRGB8888, little-endian: B G R X
+				p := row[x*4:]
+				c = color.RGBA{p[2], p[1], p[0], 0xff}
+			} else { // RGB565
+				v := uint16(row[x*2]) | uint16(row[x*2+1])<<8
+				r, g, b := byte(v>>11), byte(v>>5&0x3f), byte(v&0x1f)
+				c = color.RGBA{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return png.Encode(w, img)
+}
@@ -19,6 +19,7 @@ type HealthStatus struct {
 	DiskFreeBytes uint64    `json:"disk_free_bytes"`
 	CPUTempC      float64   `json:"cpu_temp_c"`
 	Throttled     bool      `json:"throttled"`
+	Display       *Display  `json:"display,omitempty"`
 	Timestamp     time.Time `json:"timestamp"`
 }
 
@@ -135,6 +136,12 @@ func RunHealthCheck() HealthStatus {
 		log.Printf("[system] health: throttle check error: %v", err)
 	}
 
+	if d, err := DetectDisplay(); err == nil {
+		status.Display = &d
+	} else {
+		log.Printf("[system] health: display detection error: %v", err)
+	}
+
 	log.Printf("[system] health: temp=%.1f°C disk=%.1f%% throttled=%v",
 		status.CPUTempC, status.DiskUsedPct, status.Throttled)
 
@@ -0,0 +1,58 @@
+package template
+
+import "reflect"
+
+// Changes is the difference between two versions of a template, by zone
+// ID. Zones in Replaced and Content are the new versions.
+type Changes struct {
+	Added     []Zone   // only in the new template
+	Removed   []Zone   // only in the old template
+	Replaced  []Zone   // placement or player settings changed: needs a new player
+	Content   []Zone   // only the playlist directory, recursion or schedule changed
+	Unchanged []string // zone IDs that are identical in both
+}
+
+// Diff compares two templates zone by zone. A change of rotation moves
+// every zone, so all zones in both are replaced.
+func Diff(old, next *Template) Changes {
+	var c Changes
+	prev := make(map[string]Zone, len(old.Zones))
+	for _, z := range old.Zones {
+		prev[z.ID] = z
+	}
+	seen := make(map[string]bool, len(next.Zones))
+	for _, z := range next.Zones {
+		seen[z.ID] = true
+		o, ok := prev[z.ID]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, z)
+		case old.Rotation != next.Rotation, !reflect.DeepEqual(o.player(), z.player()):
+			c.Replaced = append(c.Replaced, z)
+		case !reflect.DeepEqual(o, z):
+			c.Content = append(c.Content, z)
+		default:
+			c.Unchanged = append(c.Unchanged, z.ID)
+		}
+	}
+	for _, z := range old.Zones {
+		if !seen[z.ID] {
+			c.Removed = append(c.Removed, z)
+		}
+	}
+	return c
+}
+
+// Empty reports whether the templates have the same zones.
+func (c Changes) Empty() bool {
+	return len(c.Added)+len(c.Removed)+len(c.Replaced)+len(c.Content) == 0
+}
+
+// player returns the zone without its content settings: the fields a
+// running player is built from.
+func (z Zone) player() Zone {
+	z.PlaylistDir = ""
+	z.Recursive = false
+	z.Schedule = nil
+	return z
+}
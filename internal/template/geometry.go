@@ -0,0 +1,173 @@
+package template
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Units for zone geometry.
+const (
+	UnitPercent = "percent" // percentages of the screen (default)
+	UnitPixels  = "px"      // absolute screen pixels
+)
+
+// Reference screen size used to compare zones when the actual resolution
+// is not known, e.g. by Validate.
+const (
+	RefWidth  = 1920
+	RefHeight = 1080
+)
+
+// Rect is an area of the screen in whole pixels.
+type Rect struct {
+	X, Y, W, H int
+}
+
+// Overlaps reports whether two rectangles share any pixels. Rectangles
+// that only touch do not overlap.
+func (r Rect) Overlaps(o Rect) bool {
+	return r.X < o.X+o.W && o.X < r.X+r.W &&
+		r.Y < o.Y+o.H && o.Y < r.Y+r.H
+}
+
+// Rect converts the zone's geometry to pixels on a screenW x screenH
+// screen. Each edge is rounded to the nearest pixel on its own, so zones
+// that meet at the same coordinate share that edge exactly: no seams
+// and no overlapping pixels.
+func (z Zone) Rect(screenW, screenH int) Rect {
+	x0, y0, x1, y1 := z.bounds(screenW, screenH)
+	left, top := int(math.Round(x0)), int(math.Round(y0))
+	return Rect{X: left, Y: top, W: int(math.Round(x1)) - left, H: int(math.Round(y1)) - top}
+}
+
+// Screen returns the size of the screen as the viewer sees it on a
+// panelW x panelH panel: swapped for 90° and 270° rotations.
+func (t *Template) Screen(panelW, panelH int) (w, h int) {
+	if t.Rotation == 90 || t.Rotation == 270 {
+		return panelH, panelW
+	}
+	return panelW, panelH
+}
+
+// PanelRect returns where zone z lands on a panelW x panelH panel once
+// the template's rotation is applied. Rotating whole pixels keeps
+// shared edges shared.
+func (t *Template) PanelRect(z Zone, panelW, panelH int) Rect {
+	w, h := t.Screen(panelW, panelH)
+	r := z.Rect(w, h)
+	switch t.Rotation {
+	case 90:
+		return Rect{X: h - r.Y - r.H, Y: r.X, W: r.H, H: r.W}
+	case 180:
+		return Rect{X: w - r.X - r.W, Y: h - r.Y - r.H, W: r.W, H: r.H}
+	case 270:
+		return Rect{X: r.Y, Y: w - r.X - r.W, W: r.H, H: r.W}
+	}
+	return r
+}
+
+// PanelZone returns z as placed on a panelW x panelH panel: geometry in
+// pixels and the template's rotation added to Rotate. Playback backends
+// receive zones in this form.
+func (t *Template) PanelZone(z Zone, panelW, panelH int) Zone {
+	r := t.PanelRect(z, panelW, panelH)
+	z.Unit, z.Aspect = UnitPixels, ""
+	z.X, z.Y, z.Width, z.Height = float64(r.X), float64(r.Y), float64(r.W), float64(r.H)
+	z.Rotate = (z.Rotate + t.Rotation) % 360
+	return z
+}
+
+// validRotation reports whether deg is a supported rotation.
+func validRotation(deg int) bool {
+	return deg == 0 || deg == 90 || deg == 180 || deg == 270
+}
+
+// Overlaps reports whether two zones share any screen area at the
+// reference resolution.
+func (z Zone) Overlaps(o Zone) bool {
+	return z.Rect(RefWidth, RefHeight).Overlaps(o.Rect(RefWidth, RefHeight))
+}
+
+// bounds returns the zone's edges in fractional pixels.
+func (z Zone) bounds(screenW, screenH int) (x0, y0, x1, y1 float64) {
+	x0, y0, x1, y1 = z.rawBounds(screenW, screenH)
+	return snap(x0), snap(y0), snap(x1), snap(y1)
+}
+
+func (z Zone) rawBounds(screenW, screenH int) (x0, y0, x1, y1 float64) {
+	sx, sy := 1.0, 1.0
+	if z.Unit != UnitPixels {
+		sx, sy = float64(screenW)/100, float64(screenH)/100
+	}
+	ratio, ok := parseAspect(z.Aspect)
+	if !ok {
+		// Scale the far edge directly so that it matches the near edge
+		// of a neighbouring zone bit for bit.
+		return z.X * sx, z.Y * sy, (z.X + z.Width) * sx, (z.Y + z.Height) * sy
+	}
+
+	x0, y0 = z.X*sx, z.Y*sy
+	w, h := z.Width*sx, z.Height*sy
+	switch {
+	case z.Height == 0:
+		h = w / ratio
+	case z.Width == 0:
+		w = h * ratio
+	case w/h > ratio:
+		// Too wide: pillarbox within the box.
+		x0 += (w - h*ratio) / 2
+		w = h * ratio
+	default:
+		// Too tall: letterbox within the box.
+		y0 += (h - w/ratio) / 2
+		h = w / ratio
+	}
+	return x0, y0, x0 + w, y0 + h
+}
+
+// snap drops float noise below a millionth of a pixel, so that equal
+// edges written differently (33.3 + 33.3 and 66.6) compare and round
+// the same way.
+func snap(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// parseAspect parses an aspect ratio written as "W:H" (e.g. "16:9").
+func parseAspect(s string) (float64, bool) {
+	w, h, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, false
+	}
+	fw, err1 := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	fh, err2 := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if err1 != nil || err2 != nil || fw <= 0 || fh <= 0 || math.IsInf(fw/fh, 0) {
+		return 0, false
+	}
+	return fw / fh, true
+}
+
+// checkGeometry returns a problem with the zone's unit, aspect or size,
+// as a diagnostic code and message.
+func (z Zone) checkGeometry() (code, msg string) {
+	switch z.Unit {
+	case "", UnitPercent, UnitPixels:
+	default:
+		return CodeUnit, fmt.Sprintf("zone %q has unknown unit %q (want %q or %q)", z.ID, z.Unit, UnitPercent, UnitPixels)
+	}
+	if z.Aspect != "" {
+		if _, ok := parseAspect(z.Aspect); !ok {
+			return CodeAspect, fmt.Sprintf("zone %q has invalid aspect %q (want W:H, e.g. 16:9)", z.ID, z.Aspect)
+		}
+		// One of width and height may be left out and derived.
+		if z.Width < 0 || z.Height < 0 || (z.Width == 0 && z.Height == 0) {
+			return CodeDimensions, fmt.Sprintf("zone %q has invalid dimensions: %gx%g", z.ID, z.Width, z.Height)
+		}
+		return "", ""
+	}
+	if z.Width <= 0 || z.Height <= 0 {
+		return CodeDimensions, fmt.Sprintf("zone %q has invalid dimensions: %gx%g", z.ID, z.Width, z.Height)
+	}
+	return "", ""
+}
@@ -0,0 +1,141 @@
+package template
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// ASCII draws the layout on a screenW x screenH screen as a text diagram
+// cols characters wide, in the style of the diagrams in the README.
+// Zones are drawn bottom to top, so higher zones hide what they cover,
+// and each is labelled with its ID and pixel size where they fit.
+func (t *Template) ASCII(screenW, screenH, cols int) string {
+	// Terminal cells are about twice as tall as they are wide.
+	rows := (cols*screenH + screenW) / (2 * screenW)
+	if rows < 2 {
+		rows = 2
+	}
+	grid := make([][]byte, rows+1)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", cols+1))
+	}
+
+	for _, z := range t.Stacked() {
+		r := z.Rect(screenW, screenH)
+		c0, c1 := cell(r.X, screenW, cols), cell(r.X+r.W, screenW, cols)
+		r0, r1 := cell(r.Y, screenH, rows), cell(r.Y+r.H, screenH, rows)
+		if c1 == c0 || r1 == r0 {
+			continue // too small to draw
+		}
+
+		for y := r0 + 1; y < r1; y++ {
+			for x := c0 + 1; x < c1; x++ {
+				grid[y][x] = ' '
+			}
+		}
+		for x := c0; x <= c1; x++ {
+			border(grid, x, r0, '-')
+			border(grid, x, r1, '-')
+		}
+		for y := r0; y <= r1; y++ {
+			border(grid, c0, y, '|')
+			border(grid, c1, y, '|')
+		}
+		for _, p := range [][2]int{{c0, r0}, {c1, r0}, {c0, r1}, {c1, r1}} {
+			grid[p[1]][p[0]] = '+'
+		}
+
+		labels := []string{z.ID, fmt.Sprintf("%dx%d", r.W, r.H)}
+		inner := c1 - c0 - 1
+		top := r0 + 1 + (r1-r0-1-len(labels))/2
+		for i, l := range labels {
+			y := top + i
+			if y <= r0 || y >= r1 || len(l) > inner {
+				continue
+			}
+			copy(grid[y][c0+1+(inner-len(l))/2:], l)
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range grid {
+		b.WriteString(strings.TrimRight(string(line), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// cell maps a pixel coordinate on a screen of size pixels to one of n
+// character cells.
+func cell(v, size, n int) int {
+	c := (v*n + size/2) / size
+	switch {
+	case c < 0:
+		return 0
+	case c > n:
+		return n
+	}
+	return c
+}
+
+// border draws a border character, turning crossings with another
+// zone's border into corners.
+func border(grid [][]byte, x, y int, ch byte) {
+	switch grid[y][x] {
+	case ch, '+':
+	case '-', '|':
+		grid[y][x] = '+'
+	default:
+		grid[y][x] = ch
+	}
+}
+
+// previewColors are the zone fill colours of PNG previews, in template
+// order.
+var previewColors = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+	{0xe3, 0x77, 0xc2, 0xff},
+	{0x17, 0xbe, 0xcf, 0xff},
+}
+
+// PNG writes the layout as a screenW x screenH PNG image. Zones are
+// filled with distinct colours bottom to top, blended by their opacity,
+// and outlined so that adjacent zones stay distinguishable.
+func (t *Template) PNG(w io.Writer, screenW, screenH int) error {
+	img := image.NewRGBA(image.Rect(0, 0, screenW, screenH))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	colors := make(map[string]color.RGBA)
+	for i, z := range t.Zones {
+		colors[z.ID] = previewColors[i%len(previewColors)]
+	}
+	const outline = 2
+	for _, z := range t.Stacked() {
+		r := z.Rect(screenW, screenH)
+		area := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+		alpha := &image.Uniform{color.Alpha{uint8(z.Alpha()*255 + 0.5)}}
+		fill := colors[z.ID]
+		draw.DrawMask(img, area, &image.Uniform{fill}, image.Point{}, alpha, image.Point{}, draw.Over)
+
+		edge := color.RGBA{fill.R / 2, fill.G / 2, fill.B / 2, 0xff}
+		for _, e := range []image.Rectangle{
+			image.Rect(area.Min.X, area.Min.Y, area.Max.X, area.Min.Y+outline),
+			image.Rect(area.Min.X, area.Max.Y-outline, area.Max.X, area.Max.Y),
+			image.Rect(area.Min.X, area.Min.Y, area.Min.X+outline, area.Max.Y),
+			image.Rect(area.Max.X-outline, area.Min.Y, area.Max.X, area.Max.Y),
+		} {
+			draw.DrawMask(img, e.Intersect(area), &image.Uniform{edge}, image.Point{}, alpha, image.Point{}, draw.Over)
+		}
+	}
+	return png.Encode(w, img)
+}
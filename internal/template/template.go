@@ -7,28 +7,78 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"player-native/internal/schedule"
 )
 
 // Zone represents a rectangular region of the screen.
-// Coordinates are percentages (0-100) of total screen area.
+// Coordinates are percentages (0-100) of the screen, fractions allowed
+// (33.3333 for an exact third), or absolute pixels when Unit is "px".
+// Aspect ("16:9") locks the zone's shape: width or height may be left
+// out and is derived from the other, and if both are given the zone is
+// the largest rectangle of that shape centred in the box.
+// An optional Schedule switches the zone between playlist directories
+// by time of day; PlaylistDir is used outside all scheduled windows.
+// Recursive includes subfolders of the playlist directory. Backend
+// selects the zone's playback backend (e.g. "mpv"), overriding the
+// player's --backend flag.
+//
+// Zones may overlap if they have different Zindex values; higher zones
+// are drawn on top. Transparent lets content with an alpha channel show
+// the zones below, and Opacity (0-1, default 1) fades the whole zone.
+// Rotate (0, 90, 180 or 270) turns the zone's content clockwise, e.g.
+// to show portrait video in a landscape zone.
 type Zone struct {
-	ID          string `json:"id"`
-	X           int    `json:"x"`
-	Y           int    `json:"y"`
-	Width       int    `json:"width"`
-	Height      int    `json:"height"`
-	PlaylistDir string `json:"playlist_dir"`
-	Zindex      int    `json:"zindex"`
+	ID          string             `json:"id"`
+	X           float64            `json:"x"`
+	Y           float64            `json:"y"`
+	Width       float64            `json:"width"`
+	Height      float64            `json:"height"`
+	Unit        string             `json:"unit,omitempty"`
+	Aspect      string             `json:"aspect,omitempty"`
+	PlaylistDir string             `json:"playlist_dir"`
+	Zindex      int                `json:"zindex"`
+	Recursive   bool               `json:"recursive,omitempty"`
+	Backend     string             `json:"backend,omitempty"`
+	Transparent bool               `json:"transparent,omitempty"`
+	Opacity     *float64           `json:"opacity,omitempty"`
+	Rotate      int                `json:"rotate,omitempty"`
+	Schedule    *schedule.Schedule `json:"schedule,omitempty"`
 }
 
 // Template is a named screen layout with one or more zones.
+//
+// Rotation (0, 90, 180 or 270) turns the whole picture clockwise on the
+// panel, for screens mounted in portrait: a panel turned 90° counter-
+// clockwise needs 90. Zones are laid out on the screen as the viewer
+// sees it, so a 90° template on a 1920x1080 panel lays out 1080x1920.
 type Template struct {
-	Name  string `json:"name"`
-	Zones []Zone `json:"zones"`
+	Name     string `json:"name"`
+	Rotation int    `json:"rotation,omitempty"`
+	Zones    []Zone `json:"zones"`
 }
 
-// LoadFromFile reads a template definition from a JSON file.
+// LoadFromFile reads a template definition from a JSON file and
+// validates it.
 func LoadFromFile(path string) (*Template, error) {
+	t, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ReadFile reads a template definition from a JSON file without
+// validating it.
+func ReadFile(path string) (*Template, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read template: %w", err)
@@ -38,40 +88,71 @@ func LoadFromFile(path string) (*Template, error) {
 	if err := json.Unmarshal(data, &t); err != nil {
 		return nil, fmt.Errorf("parse template: %w", err)
 	}
+	return &t, nil
+}
 
-	if err := t.Validate(); err != nil {
-		return nil, err
+// Alpha returns the zone's opacity, 1 if unset.
+func (z Zone) Alpha() float64 {
+	if z.Opacity == nil {
+		return 1
 	}
+	return *z.Opacity
+}
 
-	return &t, nil
+// Stacked returns the zones bottom to top: by Zindex, then template order.
+func (t *Template) Stacked() []Zone {
+	zones := append([]Zone(nil), t.Zones...)
+	sort.SliceStable(zones, func(i, j int) bool { return zones[i].Zindex < zones[j].Zindex })
+	return zones
 }
 
-// Validate checks that the template has at least one zone and
-// that all zones have valid dimensions.
-func (t *Template) Validate() error {
-	if len(t.Zones) == 0 {
-		return fmt.Errorf("template %q has no zones", t.Name)
+// HasOverlaps reports whether any two zones overlap on a screenW x
+// screenH screen.
+func (t *Template) HasOverlaps(screenW, screenH int) bool {
+	for i, a := range t.Zones {
+		for _, b := range t.Zones[i+1:] {
+			if a.Rect(screenW, screenH).Overlaps(b.Rect(screenW, screenH)) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	ids := make(map[string]bool)
-	for _, z := range t.Zones {
-		if z.ID == "" {
-			return fmt.Errorf("zone missing id")
-		}
-		if ids[z.ID] {
-			return fmt.Errorf("duplicate zone id: %s", z.ID)
-		}
-		ids[z.ID] = true
+// builtins maps the built-in layouts to constructors that place each
+// zone's playlist directory under a playlist root.
+var builtins = map[string]func(root string) *Template{
+	"fullscreen": Fullscreen,
+	"main-with-footer": func(root string) *Template {
+		return MainWithFooter(filepath.Join(root, "main"), filepath.Join(root, "footer"))
+	},
+	"main-with-sidebar": func(root string) *Template {
+		return MainWithSidebar(filepath.Join(root, "main"), filepath.Join(root, "sidebar"))
+	},
+	"l-shape": func(root string) *Template {
+		return LShape(filepath.Join(root, "main"), filepath.Join(root, "footer"), filepath.Join(root, "sidebar"))
+	},
+}
 
-		if z.Width <= 0 || z.Height <= 0 {
-			return fmt.Errorf("zone %q has invalid dimensions: %dx%d", z.ID, z.Width, z.Height)
-		}
-		if z.X < 0 || z.Y < 0 || z.X+z.Width > 100 || z.Y+z.Height > 100 {
-			return fmt.Errorf("zone %q exceeds screen bounds", z.ID)
-		}
+// BuiltinNames returns the names of the built-in layouts, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	return nil
+// Builtin returns a built-in layout by name. Zone playlist directories
+// are placed under playlistRoot ("main", "footer", ...); the fullscreen
+// layout plays playlistRoot itself.
+func Builtin(name, playlistRoot string) (*Template, error) {
+	fn, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown built-in template %q (available: %s)", name, strings.Join(BuiltinNames(), ", "))
+	}
+	return fn(playlistRoot), nil
 }
 
 // Fullscreen returns a single-zone template that fills the entire screen.
@@ -0,0 +1,221 @@
+package template
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestValidateOverlaps: overlapping zones need distinct zindex values to
+// define which one is on top.
+func TestValidateOverlaps(t *testing.T) {
+	half := 0.5
+	tmpl := &Template{Name: "logo-bug", Zones: []Zone{
+		{ID: "main", Width: 100, Height: 100},
+		{ID: "logo", X: 85, Y: 5, Width: 10, Height: 10, Zindex: 1, Transparent: true, Opacity: &half},
+	}}
+	if err := tmpl.Validate(); err != nil {
+		t.Fatalf("intentional overlay rejected: %v", err)
+	}
+	if got := tmpl.Stacked(); got[0].ID != "main" || got[1].ID != "logo" {
+		t.Errorf("expected main below logo, got %s, %s", got[0].ID, got[1].ID)
+	}
+
+	tmpl.Zones[1].Zindex = 0
+	if err := tmpl.Validate(); err == nil || !strings.Contains(err.Error(), "same zindex") {
+		t.Fatalf("expected an ambiguous-stacking error, got %v", err)
+	}
+
+	tmpl.Zones[1].Zindex = 1
+	bad := 1.5
+	tmpl.Zones[1].Opacity = &bad
+	if err := tmpl.Validate(); err == nil {
+		t.Fatal("expected an opacity range error")
+	}
+
+	// Touching edges do not overlap.
+	a := Zone{ID: "a", Width: 50, Height: 100}
+	b := Zone{ID: "b", X: 50, Width: 50, Height: 100}
+	if a.Overlaps(b) {
+		t.Error("adjacent zones reported as overlapping")
+	}
+}
+
+// TestDiagnose covers the warning checks and that every error is
+// reported, not just the first.
+func TestDiagnose(t *testing.T) {
+	tmpl := &Template{Name: "messy", Zones: []Zone{
+		{ID: "main", Width: 75, Height: 80, PlaylistDir: "/playlist", Recursive: true},
+		{ID: "ticker", Y: 80, Width: 100, Height: 3, PlaylistDir: "/playlist/ticker/"},
+		{ID: "side", X: 75, Width: 25, Height: 80, PlaylistDir: "/playlist/side"},
+		{ID: "side2", X: 75, Width: 25, Height: 80, PlaylistDir: "/playlist/side"},
+	}}
+	d := tmpl.Diagnose(1920, 1080)
+
+	codes := func(ds []Diagnostic) string {
+		var c []string
+		for _, x := range ds {
+			c = append(c, x.Code)
+		}
+		return strings.Join(c, ",")
+	}
+	if got := codes(d.Errors); got != CodeOverlap {
+		t.Errorf("errors = %s (%v)", got, d.Errors)
+	}
+	// 17% of the height below the ticker is uncovered; the ticker is
+	// 32 px tall; ticker and side are inside main's recursive folder
+	// and side/side2 share one.
+	want := strings.Join([]string{CodeUncovered, CodeTooSmall, CodePlaylistShare, CodePlaylistShare, CodePlaylistShare, CodePlaylistShare}, ",")
+	if got := codes(d.Warnings); got != want {
+		t.Errorf("warnings = %s, want %s\n%v", got, want, d.Warnings)
+	}
+	if !strings.Contains(d.Warnings[0].Message, "17%") {
+		t.Errorf("unexpected coverage message: %s", d.Warnings[0].Message)
+	}
+
+	err := tmpl.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Diagnostics.Warnings) == 0 {
+		t.Fatalf("expected a *ValidationError with diagnostics, got %v", err)
+	}
+
+	tmpl.Zones = append(tmpl.Zones, Zone{ID: "main", Width: 0, Height: 10})
+	if n := len(tmpl.Diagnose(0, 0).Errors); n != 3 {
+		t.Errorf("expected overlap, duplicate id and dimension errors, got %d", n)
+	}
+}
+
+// TestShippedTemplates keeps the bundled layouts free of findings.
+func TestShippedTemplates(t *testing.T) {
+	files, _ := filepath.Glob("../../templates/*.json")
+	if len(files) == 0 {
+		t.Skip("templates not found")
+	}
+	for _, f := range files {
+		tmpl, err := LoadFromFile(f)
+		if err != nil {
+			t.Errorf("%s: %v", f, err)
+			continue
+		}
+		if d := tmpl.Diagnose(1920, 1080); len(d.Warnings) > 0 {
+			t.Errorf("%s: %v", f, d.Warnings)
+		}
+	}
+}
+
+// TestRectSharedEdges: fractional and pixel zones convert to pixels with
+// no seams between neighbours, and aspect-locked zones keep their shape.
+func TestRectSharedEdges(t *testing.T) {
+	thirds := []Zone{
+		{ID: "left", Width: 33.3333, Height: 100},
+		{ID: "center", X: 33.3333, Width: 33.3334, Height: 100},
+		{ID: "right", X: 66.6667, Width: 33.3333, Height: 100},
+	}
+	for _, size := range [][2]int{{3840, 2160}, {1920, 1080}, {1366, 768}, {1000, 1000}} {
+		w, h := size[0], size[1]
+		edge := 0
+		for _, z := range thirds {
+			r := z.Rect(w, h)
+			if r.X != edge {
+				t.Errorf("%dx%d: zone %s starts at %d, previous ends at %d", w, h, z.ID, r.X, edge)
+			}
+			edge = r.X + r.W
+		}
+		if edge != w {
+			t.Errorf("%dx%d: zones end at %d", w, h, edge)
+		}
+	}
+	tmpl := &Template{Name: "thirds", Zones: thirds}
+	if d := tmpl.Diagnose(3840, 2160); len(d.Errors)+len(d.Warnings) > 0 {
+		t.Errorf("unexpected findings: %+v", d)
+	}
+
+	px := Zone{ID: "ticker", Unit: UnitPixels, Y: 2000, Width: 3840, Height: 160}
+	if r := px.Rect(3840, 2160); r != (Rect{0, 2000, 3840, 160}) {
+		t.Errorf("pixel zone = %+v", r)
+	}
+	if (&Template{Name: "px", Zones: []Zone{px}}).Diagnose(1920, 1080).Err() == nil {
+		t.Error("expected a pixel zone off a 1920x1080 screen to be rejected")
+	}
+
+	video := Zone{ID: "video", Width: 50, Aspect: "16:9"}
+	if r := video.Rect(1920, 1080); r != (Rect{0, 0, 960, 540}) {
+		t.Errorf("derived height: %+v", r)
+	}
+	video.Height = 100
+	video.Width = 100
+	if r := video.Rect(1920, 1200); r != (Rect{0, 60, 1920, 1080}) {
+		t.Errorf("letterboxed: %+v", r)
+	}
+	video.Aspect = "wide"
+	if code, _ := video.checkGeometry(); code != CodeAspect {
+		t.Errorf("expected %s, got %q", CodeAspect, code)
+	}
+}
+
+// TestASCIIPreview checks that adjacent zones share a border line and
+// that every built-in renders with its labels.
+func TestASCIIPreview(t *testing.T) {
+	tmpl, err := Builtin("main-with-sidebar", "/playlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tmpl.ASCII(1920, 1080, 40)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if lines[0] != "+"+strings.Repeat("-", 29)+"+"+strings.Repeat("-", 9)+"+" {
+		t.Errorf("unexpected top border %q\n%s", lines[0], got)
+	}
+	for _, want := range []string{"main", "1440x1080", "sidebar"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+
+	for _, name := range BuiltinNames() {
+		b, _ := Builtin(name, "/playlist")
+		if err := b.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := Builtin("nope", "/playlist"); err == nil {
+		t.Error("expected an unknown built-in error")
+	}
+}
+
+// TestRotation: a portrait layout on a rotated landscape panel keeps
+// its zones on the panel, sharing edges, with the rotation passed on to
+// the content.
+func TestRotation(t *testing.T) {
+	tmpl := &Template{Name: "totem", Rotation: 90, Zones: []Zone{
+		{ID: "top", Width: 100, Height: 70},
+		{ID: "bottom", Y: 70, Width: 100, Height: 30, Rotate: 270},
+	}}
+	if w, h := tmpl.Screen(1920, 1080); w != 1080 || h != 1920 {
+		t.Fatalf("screen = %dx%d", w, h)
+	}
+	// Turned clockwise: the top of the picture is on the panel's right.
+	if r := tmpl.PanelRect(tmpl.Zones[0], 1920, 1080); r != (Rect{576, 0, 1344, 1080}) {
+		t.Errorf("top = %+v", r)
+	}
+	bottom := tmpl.PanelZone(tmpl.Zones[1], 1920, 1080)
+	if bottom.Unit != UnitPixels || bottom.X != 0 || bottom.Width != 576 || bottom.Height != 1080 || bottom.Rotate != 0 {
+		t.Errorf("bottom = %+v", bottom)
+	}
+	if d := tmpl.Diagnose(1920, 1080); len(d.Errors)+len(d.Warnings) > 0 {
+		t.Errorf("unexpected findings: %+v", d)
+	}
+
+	for _, rot := range []int{180, 270} {
+		tmpl.Rotation = rot
+		a, b := tmpl.PanelRect(tmpl.Zones[0], 1920, 1080), tmpl.PanelRect(tmpl.Zones[1], 1920, 1080)
+		if a.W*a.H+b.W*b.H != 1920*1080 || a.Overlaps(b) {
+			t.Errorf("rotation %d: %+v %+v do not tile the panel", rot, a, b)
+		}
+	}
+
+	tmpl.Rotation = 45
+	if err := tmpl.Validate(); err == nil {
+		t.Error("expected a rotation error")
+	}
+}
@@ -0,0 +1,285 @@
+package template
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MinZonePixels is the smallest zone width or height, in pixels at the
+// target resolution, that is not reported as too small.
+const MinZonePixels = 64
+
+// Diagnostic codes.
+const (
+	CodeNoZones       = "no-zones"
+	CodeMissingID     = "missing-id"
+	CodeDuplicateID   = "duplicate-id"
+	CodeDimensions    = "invalid-dimensions"
+	CodeUnit          = "invalid-unit"
+	CodeAspect        = "invalid-aspect"
+	CodeBounds        = "out-of-bounds"
+	CodeOpacity       = "invalid-opacity"
+	CodeRotation      = "invalid-rotation"
+	CodeSchedule      = "invalid-schedule"
+	CodeOverlap       = "overlap"
+	CodeUncovered     = "uncovered-area"
+	CodeTooSmall      = "zone-too-small"
+	CodePlaylistShare = "playlist-dir-collision"
+)
+
+// Diagnostic is one finding about a template.
+type Diagnostic struct {
+	Code    string   `json:"code"`
+	Zones   []string `json:"zones,omitempty"`
+	Message string   `json:"message"`
+}
+
+func (d Diagnostic) String() string { return d.Message }
+
+// Diagnostics are the findings of Diagnose. Errors make a template
+// unusable; warnings point at layouts that are probably unintended.
+type Diagnostics struct {
+	Errors   []Diagnostic `json:"errors,omitempty"`
+	Warnings []Diagnostic `json:"warnings,omitempty"`
+}
+
+func (d *Diagnostics) errorf(code string, zones []string, format string, args ...any) {
+	d.Errors = append(d.Errors, Diagnostic{Code: code, Zones: zones, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *Diagnostics) warnf(code string, zones []string, format string, args ...any) {
+	d.Warnings = append(d.Warnings, Diagnostic{Code: code, Zones: zones, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns the errors as a *ValidationError, or nil if there are none.
+func (d Diagnostics) Err() error {
+	if len(d.Errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Diagnostics: d}
+}
+
+// ValidationError is returned by Validate. It carries the full
+// diagnostics, warnings included.
+type ValidationError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Diagnostics.Errors))
+	for i, d := range e.Diagnostics.Errors {
+		msgs[i] = d.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks that the template is usable: it has zones with unique
+// IDs and valid dimensions inside the screen, and overlapping zones have
+// an explicit stacking order. It returns a *ValidationError listing every
+// problem; warnings do not fail validation.
+func (t *Template) Validate() error {
+	return t.Diagnose(0, 0).Err()
+}
+
+// Diagnose checks the template for errors and warnings. Warnings cover
+// screen area no zone covers, playlist directories shared between
+// zones and, when screenW and screenH are set, zones smaller than
+// MinZonePixels at that resolution. Without a resolution, zones are
+// compared at RefWidth x RefHeight and pixel zones are not checked
+// against the screen bounds. screenW and screenH are the panel size;
+// zones are checked on the screen as rotated by the template.
+func (t *Template) Diagnose(screenW, screenH int) Diagnostics {
+	var d Diagnostics
+	if len(t.Zones) == 0 {
+		d.errorf(CodeNoZones, nil, "template %q has no zones", t.Name)
+		return d
+	}
+	if !validRotation(t.Rotation) {
+		d.errorf(CodeRotation, nil, "template %q rotation %d is not 0, 90, 180 or 270", t.Name, t.Rotation)
+	}
+	known := screenW > 0 && screenH > 0
+	w, h := t.Screen(screenW, screenH)
+	if !known {
+		w, h = t.Screen(RefWidth, RefHeight)
+	}
+	screenW, screenH = w, h
+
+	ids := make(map[string]bool)
+	var valid []Zone // zones with usable geometry
+	for _, z := range t.Zones {
+		zones := []string{z.ID}
+		if z.ID == "" {
+			d.errorf(CodeMissingID, nil, "zone missing id")
+		} else if ids[z.ID] {
+			d.errorf(CodeDuplicateID, zones, "duplicate zone id: %s", z.ID)
+		}
+		ids[z.ID] = true
+
+		if code, msg := z.checkGeometry(); code != "" {
+			d.errorf(code, zones, "%s", msg)
+		} else if !inBounds(z, w, h, known || z.Unit != UnitPixels) {
+			d.errorf(CodeBounds, zones, "zone %q exceeds screen bounds", z.ID)
+		} else {
+			valid = append(valid, z)
+		}
+		if !validRotation(z.Rotate) {
+			d.errorf(CodeRotation, zones, "zone %q rotate %d is not 0, 90, 180 or 270", z.ID, z.Rotate)
+		}
+		if z.Opacity != nil && (*z.Opacity < 0 || *z.Opacity > 1) {
+			d.errorf(CodeOpacity, zones, "zone %q opacity %v is outside 0-1", z.ID, *z.Opacity)
+		}
+		if z.Schedule != nil {
+			if err := z.Schedule.Validate(); err != nil {
+				d.errorf(CodeSchedule, zones, "zone %q: %v", z.ID, err)
+			}
+		}
+	}
+
+	// Overlaps are allowed only where the stacking order is explicit.
+	for i, a := range valid {
+		for _, b := range valid[i+1:] {
+			if a.Rect(w, h).Overlaps(b.Rect(w, h)) && a.Zindex == b.Zindex {
+				d.errorf(CodeOverlap, []string{a.ID, b.ID},
+					"zones %q and %q overlap with the same zindex %d", a.ID, b.ID, a.Zindex)
+			}
+		}
+	}
+
+	if pct := uncoveredPercent(valid, w, h); pct > 0 && (known || !hasPixelZones(valid)) {
+		d.warnf(CodeUncovered, nil, "%.4g%% of the screen is not covered by any zone", pct)
+	}
+
+	if known {
+		for _, z := range valid {
+			r := z.Rect(screenW, screenH)
+			if r.W < MinZonePixels || r.H < MinZonePixels {
+				d.warnf(CodeTooSmall, []string{z.ID}, "zone %q is only %dx%d px at %dx%d (minimum %d px)",
+					z.ID, r.W, r.H, screenW, screenH, MinZonePixels)
+			}
+		}
+	}
+
+	playlistCollisions(t.Zones, &d)
+	return d
+}
+
+// inBounds reports whether the zone lies on a screenW x screenH screen.
+// Without checkFar only the top-left corner is checked.
+func inBounds(z Zone, screenW, screenH int, checkFar bool) bool {
+	x0, y0, x1, y1 := z.bounds(screenW, screenH)
+	if x0 < 0 || y0 < 0 {
+		return false
+	}
+	return !checkFar || (x1 <= float64(screenW) && y1 <= float64(screenH))
+}
+
+func hasPixelZones(zones []Zone) bool {
+	for _, z := range zones {
+		if z.Unit == UnitPixels {
+			return true
+		}
+	}
+	return false
+}
+
+// uncoveredPercent returns the percentage of a screenW x screenH screen
+// outside every zone, using the grid formed by all zone edges.
+func uncoveredPercent(zones []Zone, screenW, screenH int) float64 {
+	type box struct{ x0, y0, x1, y1 float64 }
+	boxes := make([]box, len(zones))
+	xs := []float64{0, float64(screenW)}
+	ys := []float64{0, float64(screenH)}
+	for i, z := range zones {
+		b := &boxes[i]
+		b.x0, b.y0, b.x1, b.y1 = z.bounds(screenW, screenH)
+		xs = append(xs, b.x0, b.x1)
+		ys = append(ys, b.y0, b.y1)
+	}
+	xs, ys = uniqueSorted(xs), uniqueSorted(ys)
+
+	var uncovered float64
+	for i := 0; i+1 < len(xs); i++ {
+		for j := 0; j+1 < len(ys); j++ {
+			cx, cy := (xs[i]+xs[i+1])/2, (ys[j]+ys[j+1])/2
+			covered := false
+			for _, b := range boxes {
+				if cx > b.x0 && cx < b.x1 && cy > b.y0 && cy < b.y1 {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				uncovered += (xs[i+1] - xs[i]) * (ys[j+1] - ys[j])
+			}
+		}
+	}
+	return 100 * uncovered / float64(screenW*screenH)
+}
+
+func uniqueSorted(v []float64) []float64 {
+	sort.Float64s(v)
+	out := v[:0]
+	for i, x := range v {
+		if i == 0 || x != out[len(out)-1] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// playlistCollisions warns about zones that would play each other's
+// content: the same playlist directory, or a directory inside another
+// zone's recursive one. Schedule window directories count too.
+func playlistCollisions(zones []Zone, d *Diagnostics) {
+	type use struct {
+		zone      string
+		dir       string
+		recursive bool
+	}
+	var uses []use
+	for _, z := range zones {
+		dirs := []string{z.PlaylistDir}
+		if z.Schedule != nil {
+			for _, w := range z.Schedule.Windows {
+				dirs = append(dirs, w.PlaylistDir)
+			}
+		}
+		for _, dir := range dirs {
+			if dir != "" {
+				uses = append(uses, use{zone: z.ID, dir: filepath.Clean(dir), recursive: z.Recursive})
+			}
+		}
+	}
+
+	reported := make(map[string]bool)
+	for i, a := range uses {
+		for _, b := range uses[i+1:] {
+			if a.zone == b.zone {
+				continue
+			}
+			var msg string
+			switch {
+			case a.dir == b.dir:
+				msg = fmt.Sprintf("zones %q and %q share playlist directory %s", a.zone, b.zone, a.dir)
+			case a.recursive && within(b.dir, a.dir):
+				msg = fmt.Sprintf("zone %q plays %s, which is inside zone %q's recursive directory %s", b.zone, b.dir, a.zone, a.dir)
+			case b.recursive && within(a.dir, b.dir):
+				msg = fmt.Sprintf("zone %q plays %s, which is inside zone %q's recursive directory %s", a.zone, a.dir, b.zone, b.dir)
+			default:
+				continue
+			}
+			if !reported[msg] {
+				reported[msg] = true
+				d.warnf(CodePlaylistShare, []string{a.zone, b.zone}, "%s", msg)
+			}
+		}
+	}
+}
+
+// within reports whether dir is strictly inside parent.
+func within(dir, parent string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,62 @@
+package vlc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"player-native/internal/template"
+)
+
+// DefaultBackend is the backend used when neither --backend nor a
+// zone's "backend" field names one.
+const DefaultBackend = "vlc"
+
+// backends maps backend names to constructors. "vlc" is libVLC when
+// built with -tags libvlc and the VLC subprocess otherwise.
+var backends = map[string]func() (Backend, error){
+	"vlc":       newBackend,
+	"mpv":       newMPVBackend,
+	"gstreamer": newGstBackend,
+}
+
+// BackendNames returns the names accepted by NewBackendFactory.
+func BackendNames() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackendFactory returns a factory that creates each zone's backend
+// by name: the zone's Backend field if set, otherwise defaultName.
+func NewBackendFactory(defaultName string) (BackendFactory, error) {
+	if err := CheckBackend(defaultName); err != nil {
+		return nil, err
+	}
+	return func(zone template.Zone) (Backend, error) {
+		name := defaultName
+		if zone.Backend != "" {
+			name = zone.Backend
+		}
+		newFn, ok := backends[name]
+		if !ok {
+			return nil, fmt.Errorf("zone %q: %w", zone.ID, unknownBackend(name))
+		}
+		return newFn()
+	}, nil
+}
+
+// CheckBackend reports whether name is a known backend.
+func CheckBackend(name string) error {
+	if _, ok := backends[name]; !ok {
+		return unknownBackend(name)
+	}
+	return nil
+}
+
+func unknownBackend(name string) error {
+	return fmt.Errorf("unknown backend %q (available: %s)", name, strings.Join(BackendNames(), ", "))
+}
@@ -1,121 +1,434 @@
 // Package vlc provides a zone-aware video playback engine.
 // Each zone runs an independent gapless playback loop (videos + images).
-// On RPi5 (linux/arm64) it uses CGO with libVLC for DRM/KMS rendering.
-// On other platforms it uses VLC as a subprocess for development testing.
+// Built with -tags libvlc (CGO) it plays through libVLC in-process and
+// renders via DRM/KMS on a headless RPi5; otherwise, and as a fallback,
+// it runs VLC as a subprocess per zone.
 package vlc
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
+	"player-native/internal/media"
 	"player-native/internal/template"
 )
 
 // Backend is the platform-specific playback implementation for a single zone.
 // Each zone gets its own Backend instance.
 //
+// PlayAll plays items until stopCh is closed, which happens when the
+// zone stops or restarts; each call gets a fresh channel.
+//
 // IMPORTANT: PlayAll must respond to Stop() without deadlocking.
 // Backends must NOT hold a mutex while blocking.
 type Backend interface {
 	Init(zone template.Zone, screenW, screenH int) error
-	PlayAll(files []string, stopCh <-chan struct{}) error
+	PlayAll(items []media.Item, stopCh <-chan struct{}) error
 	Stop()
 	Release()
 }
 
+// BackendFactory creates the backend for one zone. The engine calls
+// Init on the result.
+type BackendFactory func(zone template.Zone) (Backend, error)
+
+// Clock is the time source for zone run loops. Tests substitute a
+// virtual clock (see package vlctest) to drive waits deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the part of *time.Timer the engine uses.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                 { return time.Now() }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop() bool          { return r.t.Stop() }
+
+// Run loop timings.
+const (
+	emptyWait    = 2 * time.Second        // recheck interval while a zone has no content
+	errorBackoff = 500 * time.Millisecond // pause before replaying after PlayAll returns
+)
+
+// Skipper is implemented by backends that can advance to the next
+// item without restarting playback.
+type Skipper interface {
+	Next() error
+}
+
+// Pauser is implemented by backends that can pause and resume in place.
+// Zones on other backends are "paused" by stopping playback and holding
+// the run loop until Resume.
+type Pauser interface {
+	Pause() error
+	Resume() error
+}
+
+// PlaylistUpdater is implemented by backends that can hot-swap the
+// playlist of a running player. The item on screen plays to its end and
+// the new list takes over at the next item boundary, continuing from
+// the position given by resumeIndex. Other backends are restarted on
+// every playlist change.
+type PlaylistUpdater interface {
+	UpdatePlaylist(items []media.Item) error
+}
+
+// VolumeSetter is implemented by backends that can change volume during
+// playback. Percent is 0-200 (100 = unity gain).
+type VolumeSetter interface {
+	SetVolume(percent int) error
+}
+
+// NowPlaying describes the item a zone is currently showing.
+type NowPlaying struct {
+	File     string  `json:"file"`
+	Position float64 `json:"position_sec"`
+	Duration float64 `json:"duration_sec,omitempty"`
+	State    string  `json:"state"`
+	Volume   int     `json:"volume_pct"`
+}
+
+// Monitor is implemented by backends that can report the current item.
+type Monitor interface {
+	NowPlaying() (NowPlaying, bool)
+}
+
+// Stacker is implemented by backends that show each zone in its own
+// window. Windows waits for a newly started player to map its windows,
+// applies the zone's opacity and returns their IDs; Raise puts those
+// windows above all others. The engine raises zones bottom to top
+// whenever one starts so that overlapping zones stack by Zindex.
+type Stacker interface {
+	Windows() ([]string, error)
+	Raise(windows []string) error
+}
+
+// PlayEvent reports that a zone finished showing one playlist item.
+// Completed is false when the item was cut short by a skip, restart,
+// playlist change or shutdown.
+type PlayEvent struct {
+	ZoneID    string
+	Item      media.Item
+	Start     time.Time
+	End       time.Time
+	Completed bool
+}
+
+// EventFunc receives item-level playback events.
+type EventFunc func(PlayEvent)
+
+// EventSource is implemented by backends that know which item is on
+// screen and can report item boundaries. The engine wires its handler
+// in through SetEventFunc; backends must not block in the callback.
+type EventSource interface {
+	SetEventFunc(fn EventFunc)
+}
+
+// ErrZoneNotFound is returned by zone operations for an unknown zone ID.
+var ErrZoneNotFound = errors.New("zone not found")
+
+// ErrUnsupported is returned when a zone's backend lacks the capability
+// an operation needs.
+var ErrUnsupported = errors.New("operation not supported by backend")
+
+// ErrFallback is returned by Backend.Init when the backend cannot show
+// the zone on this system; the engine then plays the zone through the
+// fallback backend instead, see WithFallback.
+var ErrFallback = errors.New("backend cannot show zone")
+
+// ZoneState describes what a zone is currently doing.
+type ZoneState string
+
+const (
+	StateIdle    ZoneState = "idle"
+	StatePlaying ZoneState = "playing"
+	StatePaused  ZoneState = "paused"
+	StateError   ZoneState = "error"
+	StateStopped ZoneState = "stopped"
+)
+
+// ZoneStatus is a point-in-time snapshot of a zone. PlaylistHash
+// changes whenever the zone's items, their order or settings change.
+type ZoneStatus struct {
+	ID           string        `json:"id"`
+	State        ZoneState     `json:"state"`
+	Items        int           `json:"items"`
+	PlaylistHash string        `json:"playlist_hash,omitempty"`
+	Zone         template.Zone `json:"zone"`
+	Now          *NowPlaying   `json:"now_playing,omitempty"`
+	LastError    string        `json:"last_error,omitempty"`
+}
+
+// ZoneError is a playback error and the zone and time it happened.
+type ZoneError struct {
+	ZoneID string
+	Err    error
+	At     time.Time
+}
+
 // ZonePlayer manages a single zone's playback lifecycle.
 type ZonePlayer struct {
-	zone    template.Zone
-	backend Backend
-	mu      sync.Mutex
-	files   []string
-	running bool
-	stopCh  chan struct{} // closed when the zone should shut down permanently
+	zone      template.Zone
+	backend   Backend
+	mu        sync.Mutex
+	items     []media.Item
+	running   bool
+	paused    bool
+	lastErr   error     // result of the last PlayAll
+	failure   ZoneError // most recent error, kept after recovery
+	clock     Clock
+	stopCh    chan struct{} // closed when the zone should shut down permanently
 	restartCh chan struct{} // signaled when playlist changes during playback
+	runStop   chan struct{} // stopCh of the current PlayAll; closed to end it
+	onStart   func()        // called (in a new goroutine) before each PlayAll
+	removed   bool          // taken out of the engine by RemoveZone
 }
 
-// Engine coordinates all zone players for a given template.
+// Engine coordinates all zone players for a given template. Zones can
+// be added and removed while it plays, see UpdateTemplate.
 type Engine struct {
-	zones   []*ZonePlayer
-	screenW int
-	screenH int
+	screenW    int
+	screenH    int
+	newBackend BackendFactory
+	fallback   BackendFactory // used when Init returns ErrFallback
+	clock      Clock
+
+	mu       sync.RWMutex
+	zones    []*ZonePlayer // template order
+	tmplName string
+	rotation int           // template rotation, see template.Template
+	stack    []*ZonePlayer // bottom to top
+	layered  bool          // zones overlap or fade, so starts restack
+	onEvent  EventFunc     // set by OnPlayEvent, wired into new zones
+	errCh    chan error    // set by Play; new zones start when non-nil
+
+	stackMu sync.Mutex // serializes raising windows
+}
+
+// Option configures an Engine.
+type Option func(*Engine)
+
+// WithBackendFactory replaces the platform backend, e.g. with a
+// simulated one in tests.
+func WithBackendFactory(f BackendFactory) Option {
+	return func(e *Engine) { e.newBackend = f }
+}
+
+// WithFallback sets the backend used for zones the primary backend
+// cannot show. The default is the VLC subprocess.
+func WithFallback(f BackendFactory) Option {
+	return func(e *Engine) { e.fallback = f }
+}
+
+// WithClock sets the time source for run-loop waits.
+func WithClock(c Clock) Option {
+	return func(e *Engine) { e.clock = c }
 }
 
 // NewEngine creates an engine that manages playback across all zones.
-func NewEngine(tmpl *template.Template, screenW, screenH int) (*Engine, error) {
+func NewEngine(tmpl *template.Template, screenW, screenH int, opts ...Option) (*Engine, error) {
 	e := &Engine{
-		screenW: screenW,
-		screenH: screenH,
+		tmplName: tmpl.Name,
+		rotation: tmpl.Rotation,
+		screenW:  screenW,
+		screenH:  screenH,
+		newBackend: func(template.Zone) (Backend, error) {
+			return newBackend()
+		},
+		fallback: func(template.Zone) (Backend, error) {
+			return newSubprocessBackend(), nil
+		},
+		clock: realClock{},
+	}
+	for _, opt := range opts {
+		opt(e)
 	}
 
 	for _, z := range tmpl.Zones {
-		b, err := newBackend()
+		zp, err := e.newZone(z)
 		if err != nil {
 			e.Release()
 			return nil, err
 		}
+		e.zones = append(e.zones, zp)
+	}
+	e.layoutLocked()
 
-		if err := b.Init(z, screenW, screenH); err != nil {
-			e.Release()
+	log.Printf("[engine] %d zone(s) ready", len(e.zones))
+	return e, nil
+}
+
+// newZone creates and initialises the player for one zone. The backend
+// gets the zone as placed on the panel (see template.PanelZone).
+func (e *Engine) newZone(z template.Zone) (*ZonePlayer, error) {
+	e.mu.RLock()
+	layout := template.Template{Rotation: e.rotation}
+	e.mu.RUnlock()
+	pz := layout.PanelZone(z, e.screenW, e.screenH)
+
+	b, err := e.newBackend(pz)
+	if err != nil {
+		return nil, err
+	}
+	err = b.Init(pz, e.screenW, e.screenH)
+	if errors.Is(err, ErrFallback) {
+		log.Printf("[engine] zone %q: %v, using the fallback backend", z.ID, err)
+		if b, err = e.fallback(pz); err != nil {
 			return nil, err
 		}
+		err = b.Init(pz, e.screenW, e.screenH)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		zp := &ZonePlayer{
-			zone:      z,
-			backend:   b,
-			stopCh:    make(chan struct{}),
-			restartCh: make(chan struct{}, 1),
-		}
-		e.zones = append(e.zones, zp)
-		log.Printf("[engine] zone %q initialized (%d%%x%d%% at %d%%,%d%%)",
-			z.ID, z.Width, z.Height, z.X, z.Y)
+	zp := &ZonePlayer{
+		zone:      z,
+		backend:   b,
+		clock:     e.clock,
+		stopCh:    make(chan struct{}),
+		restartCh: make(chan struct{}, 1),
 	}
+	zp.onStart = func() { e.restack(zp) }
 
-	log.Printf("[engine] %d zone(s) ready", len(e.zones))
-	return e, nil
+	e.mu.RLock()
+	fn := e.onEvent
+	e.mu.RUnlock()
+	if fn != nil {
+		e.wireEvents(zp, fn)
+	}
+
+	log.Printf("[engine] zone %q initialized (%gx%g px at %g,%g)", z.ID, pz.Width, pz.Height, pz.X, pz.Y)
+	return zp, nil
+}
+
+// layoutLocked recomputes the stacking order after the zones changed.
+// e.mu must be held for writing.
+func (e *Engine) layoutLocked() {
+	tmpl := e.templateLocked()
+	byID := make(map[string]*ZonePlayer, len(e.zones))
+	for _, zp := range e.zones {
+		byID[zp.zone.ID] = zp
+	}
+	stack := make([]*ZonePlayer, 0, len(e.zones))
+	for _, z := range tmpl.Stacked() {
+		stack = append(stack, byID[z.ID])
+	}
+	e.stack = stack
+
+	// Stacking only matters where zones overlap or fade.
+	e.layered = tmpl.HasOverlaps(tmpl.Screen(e.screenW, e.screenH))
+	for _, z := range tmpl.Zones {
+		e.layered = e.layered || z.Opacity != nil
+	}
+}
+
+// templateLocked returns the layout the engine is currently playing.
+// e.mu must be held.
+func (e *Engine) templateLocked() *template.Template {
+	tmpl := &template.Template{Name: e.tmplName, Rotation: e.rotation, Zones: make([]template.Zone, len(e.zones))}
+	for i, zp := range e.zones {
+		zp.mu.Lock()
+		tmpl.Zones[i] = zp.zone
+		zp.mu.Unlock()
+	}
+	return tmpl
+}
+
+// players returns a snapshot of the zone players in template order.
+func (e *Engine) players() []*ZonePlayer {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]*ZonePlayer(nil), e.zones...)
 }
 
 // SetPlaylist updates the file list for a specific zone by ID.
+// Every file plays with default settings.
 func (e *Engine) SetPlaylist(zoneID string, files []string) {
-	for _, zp := range e.zones {
-		if zp.zone.ID == zoneID {
-			zp.updatePlaylist(files)
-			return
-		}
+	e.SetItems(zoneID, media.ItemsFromPaths(files))
+}
+
+// SetItems updates the playlist for a specific zone by ID, including
+// per-item settings such as image duration and mute.
+func (e *Engine) SetItems(zoneID string, items []media.Item) {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		log.Printf("[engine] warning: zone %q not found", zoneID)
+		return
 	}
-	log.Printf("[engine] warning: zone %q not found", zoneID)
+	zp.updatePlaylist(items)
 }
 
 // SetPlaylistAllZones sets the same playlist on all zones.
 func (e *Engine) SetPlaylistAllZones(files []string) {
-	for _, zp := range e.zones {
-		zp.updatePlaylist(files)
+	items := media.ItemsFromPaths(files)
+	for _, zp := range e.players() {
+		zp.updatePlaylist(items)
 	}
 }
 
-// Play starts all zone players in goroutines.
+// Play starts all zone players in goroutines. The channel receives the
+// result of each zone's run loop; zones added later report to it too,
+// removed zones do not.
 func (e *Engine) Play() <-chan error {
-	errCh := make(chan error, len(e.zones))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errCh = make(chan error, len(e.zones))
 	for _, zp := range e.zones {
-		go func(z *ZonePlayer) {
-			errCh <- z.run()
-		}(zp)
+		e.startLocked(zp)
 	}
-	return errCh
+	return e.errCh
+}
+
+// startLocked runs a zone's loop in a goroutine. e.mu must be held.
+func (e *Engine) startLocked(zp *ZonePlayer) {
+	errCh := e.errCh
+	go func() {
+		err := zp.run()
+		zp.mu.Lock()
+		removed := zp.removed
+		zp.mu.Unlock()
+		if removed {
+			return
+		}
+		// Only the first result matters to callers; never block on a
+		// full channel.
+		select {
+		case errCh <- err:
+		default:
+		}
+	}()
 }
 
 // Stop halts all zone players.
 func (e *Engine) Stop() {
 	log.Println("[engine] stopping all zones...")
-	for _, zp := range e.zones {
+	for _, zp := range e.players() {
 		zp.stop()
 	}
 }
 
 // Release frees all backend resources.
 func (e *Engine) Release() {
-	for _, zp := range e.zones {
+	for _, zp := range e.players() {
 		zp.stop()
 		if zp.backend != nil {
 			zp.backend.Release()
@@ -124,33 +437,425 @@ func (e *Engine) Release() {
 	log.Println("[engine] all zones released")
 }
 
+// AddZone creates a player for a new zone and, if the engine is
+// playing, starts it. The zone stays idle until its playlist is set.
+func (e *Engine) AddZone(z template.Zone) error {
+	if e.zone(z.ID) != nil {
+		return fmt.Errorf("zone %q already exists", z.ID)
+	}
+	zp, err := e.newZone(z)
+	if err != nil {
+		return fmt.Errorf("zone %q: %w", z.ID, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.zones = append(e.zones, zp)
+	e.layoutLocked()
+	if e.errCh != nil {
+		e.startLocked(zp)
+	}
+	log.Printf("[engine] zone %q added", z.ID)
+	return nil
+}
+
+// RemoveZone stops a zone and releases its backend.
+func (e *Engine) RemoveZone(zoneID string) error {
+	e.mu.Lock()
+	var zp *ZonePlayer
+	for i, z := range e.zones {
+		if z.zone.ID == zoneID {
+			zp = z
+			e.zones = append(e.zones[:i:i], e.zones[i+1:]...)
+			break
+		}
+	}
+	if zp == nil {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+	e.layoutLocked()
+	e.mu.Unlock()
+
+	zp.mu.Lock()
+	zp.removed = true
+	zp.mu.Unlock()
+	zp.stop()
+	zp.backend.Release()
+	log.Printf("[engine] zone %q removed", zoneID)
+	return nil
+}
+
+// UpdateTemplate switches the engine to tmpl without interrupting zones
+// that did not change. Removed zones are stopped and released; added
+// zones, and zones that moved, resized or changed player settings, get
+// new players, which are idle until their playlists are set. Zones that
+// only changed content settings keep their player. On error, the zones
+// updated so far stay updated.
+func (e *Engine) UpdateTemplate(tmpl *template.Template) (template.Changes, error) {
+	e.mu.RLock()
+	c := template.Diff(e.templateLocked(), tmpl)
+	e.mu.RUnlock()
+
+	for _, z := range append(c.Removed, c.Replaced...) {
+		if err := e.RemoveZone(z.ID); err != nil {
+			return c, err
+		}
+	}
+	e.mu.Lock()
+	e.rotation = tmpl.Rotation
+	e.mu.Unlock()
+	for _, z := range c.Content {
+		// Field by field: the ID is read without the lock.
+		zp := e.zone(z.ID)
+		zp.mu.Lock()
+		zp.zone.PlaylistDir = z.PlaylistDir
+		zp.zone.Recursive = z.Recursive
+		zp.zone.Schedule = z.Schedule
+		zp.mu.Unlock()
+	}
+	for _, z := range append(c.Added, c.Replaced...) {
+		if err := e.AddZone(z); err != nil {
+			return c, err
+		}
+	}
+
+	// Keep the new template's zone order for Status and Zones.
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tmplName = tmpl.Name
+	order := make(map[string]int, len(tmpl.Zones))
+	for i, z := range tmpl.Zones {
+		order[z.ID] = i
+	}
+	sort.SliceStable(e.zones, func(i, j int) bool {
+		return order[e.zones[i].zone.ID] < order[e.zones[j].zone.ID]
+	})
+	e.layoutLocked()
+	log.Printf("[engine] template %q applied: %d added, %d removed, %d replaced, %d unchanged",
+		tmpl.Name, len(c.Added), len(c.Removed), len(c.Replaced), len(c.Unchanged)+len(c.Content))
+	return c, nil
+}
+
 // Zones returns the list of zone IDs.
 func (e *Engine) Zones() []string {
-	ids := make([]string, len(e.zones))
-	for i, zp := range e.zones {
+	zones := e.players()
+	ids := make([]string, len(zones))
+	for i, zp := range zones {
 		ids[i] = zp.zone.ID
 	}
 	return ids
 }
 
+// OnPlayEvent registers fn to receive item-level playback events from
+// every zone whose backend implements EventSource, including zones
+// added later. Call it before Play.
+func (e *Engine) OnPlayEvent(fn EventFunc) {
+	e.mu.Lock()
+	e.onEvent = fn
+	e.mu.Unlock()
+	for _, zp := range e.players() {
+		e.wireEvents(zp, fn)
+	}
+}
+
+func (e *Engine) wireEvents(zp *ZonePlayer, fn EventFunc) {
+	if src, ok := zp.backend.(EventSource); ok {
+		src.SetEventFunc(fn)
+	} else {
+		log.Printf("[engine] zone %q backend does not report play events", zp.zone.ID)
+	}
+}
+
+// TemplateName returns the name of the template the engine is playing.
+func (e *Engine) TemplateName() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.tmplName
+}
+
+// Status returns a snapshot of every zone, in template order.
+func (e *Engine) Status() []ZoneStatus {
+	zones := e.players()
+	out := make([]ZoneStatus, len(zones))
+	for i, zp := range zones {
+		out[i] = zp.status()
+	}
+	return out
+}
+
+// LastError returns the most recent playback error of any zone, even if
+// that zone has recovered since. It reports false if none has failed.
+func (e *Engine) LastError() (ZoneError, bool) {
+	var last ZoneError
+	for _, zp := range e.players() {
+		zp.mu.Lock()
+		f := zp.failure
+		zp.mu.Unlock()
+		if f.Err != nil && !f.At.Before(last.At) {
+			last = f
+		}
+	}
+	return last, last.Err != nil
+}
+
+// Playlist returns the items currently assigned to a zone.
+func (e *Engine) Playlist(zoneID string) ([]media.Item, error) {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		return nil, fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+	zp.mu.Lock()
+	defer zp.mu.Unlock()
+	items := make([]media.Item, len(zp.items))
+	copy(items, zp.items)
+	return items, nil
+}
+
+// Skip advances a zone to its next item. It requires a backend that
+// implements Skipper.
+func (e *Engine) Skip(zoneID string) error {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		return fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+	s, ok := zp.backend.(Skipper)
+	if !ok {
+		return ErrUnsupported
+	}
+	log.Printf("[zone:%s] skip to next item", zoneID)
+	return s.Next()
+}
+
+// RestartZone stops a zone's backend and restarts playback from the
+// top of its playlist.
+func (e *Engine) RestartZone(zoneID string) error {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		return fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+	log.Printf("[zone:%s] restart requested", zoneID)
+	zp.restart()
+	return nil
+}
+
+// Pause pauses a zone. Backends without Pauser stop playback until
+// Resume is called.
+func (e *Engine) Pause(zoneID string) error {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		return fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+
+	zp.mu.Lock()
+	if zp.paused {
+		zp.mu.Unlock()
+		return nil
+	}
+	zp.paused = true
+	running := zp.running
+	zp.mu.Unlock()
+
+	log.Printf("[zone:%s] paused", zoneID)
+	if p, ok := zp.backend.(Pauser); ok && running {
+		err := p.Pause()
+		if err == nil {
+			return nil
+		}
+		log.Printf("[zone:%s] in-place pause failed: %v (stopping instead)", zoneID, err)
+	}
+	zp.restart()
+	return nil
+}
+
+// Resume continues a paused zone.
+func (e *Engine) Resume(zoneID string) error {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		return fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+
+	zp.mu.Lock()
+	if !zp.paused {
+		zp.mu.Unlock()
+		return nil
+	}
+	zp.paused = false
+	running := zp.running
+	zp.mu.Unlock()
+
+	log.Printf("[zone:%s] resumed", zoneID)
+	if p, ok := zp.backend.(Pauser); ok && running {
+		return p.Resume()
+	}
+	zp.signalRestart()
+	return nil
+}
+
+// SetVolume changes a zone's volume (0-200%) without restarting it. It
+// requires a backend that implements VolumeSetter.
+func (e *Engine) SetVolume(zoneID string, percent int) error {
+	zp := e.zone(zoneID)
+	if zp == nil {
+		return fmt.Errorf("%w: %s", ErrZoneNotFound, zoneID)
+	}
+	if percent < 0 || percent > 200 {
+		return fmt.Errorf("volume %d%% out of range 0-200", percent)
+	}
+	v, ok := zp.backend.(VolumeSetter)
+	if !ok {
+		return ErrUnsupported
+	}
+	log.Printf("[zone:%s] volume %d%%", zoneID, percent)
+	return v.SetVolume(percent)
+}
+
+func (e *Engine) zone(id string) *ZonePlayer {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, zp := range e.zones {
+		if zp.zone.ID == id {
+			return zp
+		}
+	}
+	return nil
+}
+
+// restack re-establishes z-order after zp started a player, whose new
+// window may have mapped on top: zp and every zone above it are raised,
+// bottom to top. Finding the windows can wait for a player to map them,
+// so it happens before stackMu is taken; the lock only keeps concurrent
+// restacks from interleaving their raises.
+func (e *Engine) restack(zp *ZonePlayer) {
+	e.mu.RLock()
+	stack, layered := e.stack, e.layered
+	e.mu.RUnlock()
+	if !layered {
+		return
+	}
+
+	type layer struct {
+		zone    *ZonePlayer
+		s       Stacker
+		windows []string
+	}
+	var layers []layer
+	above := false
+	for _, z := range stack {
+		above = above || z == zp
+		s, ok := z.backend.(Stacker)
+		if !above || !ok {
+			continue
+		}
+		windows, err := s.Windows()
+		if err != nil {
+			if z == zp || !errors.Is(err, ErrUnsupported) {
+				log.Printf("[zone:%s] stacking: %v", z.zone.ID, err)
+			}
+			continue
+		}
+		layers = append(layers, layer{z, s, windows})
+	}
+
+	e.stackMu.Lock()
+	defer e.stackMu.Unlock()
+	for _, l := range layers {
+		if err := l.s.Raise(l.windows); err != nil {
+			log.Printf("[zone:%s] stacking: %v", l.zone.zone.ID, err)
+		}
+	}
+}
+
 // --- ZonePlayer internals ---
 
-func (zp *ZonePlayer) updatePlaylist(files []string) {
+func (zp *ZonePlayer) updatePlaylist(items []media.Item) {
 	zp.mu.Lock()
-	zp.files = files
+	zp.items = items
 	wasRunning := zp.running
 	zp.mu.Unlock()
 
-	log.Printf("[zone:%s] playlist updated: %d files", zp.zone.ID, len(files))
+	log.Printf("[zone:%s] playlist updated: %d files", zp.zone.ID, len(items))
 
-	if wasRunning {
-		// Signal the run loop to restart with the new playlist.
-		zp.backend.Stop()
-		select {
-		case zp.restartCh <- struct{}{}:
-		default:
+	if !wasRunning {
+		return
+	}
+	if u, ok := zp.backend.(PlaylistUpdater); ok {
+		err := u.UpdatePlaylist(items)
+		if err == nil {
+			return
+		}
+		log.Printf("[zone:%s] in-place update failed: %v (restarting)", zp.zone.ID, err)
+	}
+	// Signal the run loop to restart with the new playlist.
+	zp.restart()
+}
+
+// restart ends the current PlayAll and asks the run loop to start over.
+func (zp *ZonePlayer) restart() {
+	zp.endRun()
+	zp.backend.Stop()
+	zp.signalRestart()
+}
+
+// endRun closes the stop channel of the current PlayAll, if any. Doing
+// this under the lock that also starts a run means a restart can never
+// slip in between the run loop's playlist snapshot and PlayAll.
+func (zp *ZonePlayer) endRun() {
+	zp.mu.Lock()
+	defer zp.mu.Unlock()
+	if zp.runStop != nil {
+		close(zp.runStop)
+		zp.runStop = nil
+	}
+}
+
+func (zp *ZonePlayer) signalRestart() {
+	select {
+	case zp.restartCh <- struct{}{}:
+	default:
+	}
+}
+
+func (zp *ZonePlayer) status() ZoneStatus {
+	var now *NowPlaying
+	if m, ok := zp.backend.(Monitor); ok {
+		if np, ok := m.NowPlaying(); ok {
+			now = &np
 		}
 	}
+
+	zp.mu.Lock()
+	defer zp.mu.Unlock()
+
+	st := ZoneStatus{
+		ID:           zp.zone.ID,
+		Items:        len(zp.items),
+		PlaylistHash: playlistHash(zp.items),
+		Zone:         zp.zone,
+		Now:          now,
+	}
+	if zp.lastErr != nil {
+		st.LastError = zp.lastErr.Error()
+	}
+
+	select {
+	case <-zp.stopCh:
+		st.State = StateStopped
+		return st
+	default:
+	}
+
+	switch {
+	case zp.paused:
+		st.State = StatePaused
+	case zp.running:
+		st.State = StatePlaying
+	case zp.lastErr != nil:
+		st.State = StateError
+	default:
+		st.State = StateIdle
+	}
+	return st
 }
 
 func (zp *ZonePlayer) run() error {
@@ -163,35 +868,56 @@ func (zp *ZonePlayer) run() error {
 		default:
 		}
 
+		// Snapshot the playlist and mark the zone running in one step,
+		// so any later update sees running and ends this run.
 		zp.mu.Lock()
-		files := make([]string, len(zp.files))
-		copy(files, zp.files)
+		items := make([]media.Item, len(zp.items))
+		copy(items, zp.items)
+		paused := zp.paused
+		var runStop chan struct{}
+		if !paused && len(items) > 0 {
+			runStop = make(chan struct{})
+			zp.runStop = runStop
+			zp.running = true
+		}
 		zp.mu.Unlock()
 
-		if len(files) == 0 {
-			log.Printf("[zone:%s] no content, waiting...", zp.zone.ID)
+		if paused {
 			select {
 			case <-zp.stopCh:
 				return nil
 			case <-zp.restartCh:
 				continue
-			case <-time.After(2 * time.Second):
-				continue
 			}
 		}
 
-		zp.mu.Lock()
-		zp.running = true
-		zp.mu.Unlock()
+		if len(items) == 0 {
+			log.Printf("[zone:%s] no content, waiting...", zp.zone.ID)
+			if zp.wait(emptyWait) {
+				return nil
+			}
+			continue
+		}
 
-		log.Printf("[zone:%s] starting gapless playback (%d files)", zp.zone.ID, len(files))
+		log.Printf("[zone:%s] starting gapless playback (%d files)", zp.zone.ID, len(items))
+
+		if zp.onStart != nil {
+			go zp.onStart()
+		}
 
-		// PlayAll blocks until Stop() is called or it finishes.
-		// Pass stopCh so the backend can listen for shutdown.
-		err := zp.backend.PlayAll(files, zp.stopCh)
+		// PlayAll blocks until runStop is closed (stop or restart) or
+		// it finishes on its own.
+		err := zp.backend.PlayAll(items, runStop)
 
 		zp.mu.Lock()
 		zp.running = false
+		if zp.runStop == runStop {
+			zp.runStop = nil
+		}
+		zp.lastErr = err
+		if err != nil {
+			zp.failure = ZoneError{ZoneID: zp.zone.ID, Err: err, At: zp.clock.Now()}
+		}
 		zp.mu.Unlock()
 
 		if err != nil {
@@ -207,11 +933,39 @@ func (zp *ZonePlayer) run() error {
 			log.Printf("[zone:%s] restarting with updated playlist", zp.zone.ID)
 			continue
 		default:
-			// PlayAll returned on its own (error or VLC exit) — restart.
-			time.Sleep(500 * time.Millisecond)
-			continue
 		}
+
+		// PlayAll returned on its own (error or VLC exit) — restart.
+		if zp.wait(errorBackoff) {
+			log.Printf("[zone:%s] stopped", zp.zone.ID)
+			return nil
+		}
+	}
+}
+
+// playlistHash returns a short hash of items in order, with their
+// settings, or "" for an empty playlist.
+func playlistHash(items []media.Item) string {
+	if len(items) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	json.NewEncoder(h).Encode(items)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// wait blocks for d on the zone's clock, returning early on a restart
+// signal. It reports whether the zone was stopped meanwhile.
+func (zp *ZonePlayer) wait(d time.Duration) (stopped bool) {
+	t := zp.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-zp.stopCh:
+		return true
+	case <-zp.restartCh:
+	case <-t.C():
 	}
+	return false
 }
 
 func (zp *ZonePlayer) stop() {
@@ -223,10 +977,51 @@ func (zp *ZonePlayer) stop() {
 		close(zp.stopCh)
 	}
 
-	// Then tell the backend to stop playback.
+	// Then end the current run and tell the backend to stop playback.
+	zp.endRun()
 	zp.backend.Stop()
 
 	zp.mu.Lock()
 	zp.running = false
 	zp.mu.Unlock()
 }
+
+// resumeIndex returns where a hot-swapped playlist should continue
+// after the item at path (currently playing from old) finishes: the
+// entry after it in next, or, if it was removed, the first item that
+// followed it in old and is still present. It returns 0 when nothing
+// matches.
+func resumeIndex(old []media.Item, path string, next []media.Item) int {
+	if len(next) == 0 {
+		return 0
+	}
+	find := func(list []media.Item, p string) int {
+		for i, it := range list {
+			if it.Path == p {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if i := find(next, path); i >= 0 {
+		return (i + 1) % len(next)
+	}
+	cur := find(old, path)
+	if cur < 0 {
+		return 0
+	}
+	for k := 1; k < len(old); k++ {
+		if i := find(next, old[(cur+k)%len(old)].Path); i >= 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// rotate returns items starting at index start, wrapping around.
+func rotate(items []media.Item, start int) []media.Item {
+	out := make([]media.Item, 0, len(items))
+	out = append(out, items[start:]...)
+	return append(out, items[:start]...)
+}
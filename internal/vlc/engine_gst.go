@@ -0,0 +1,879 @@
+//go:build gstreamer && cgo
+
+// GStreamer backend (build with -tags gstreamer, CGO enabled), selected
+// with --backend gstreamer or "backend": "gstreamer" on a template zone.
+// GStreamer runs in-process, and ONE shared pipeline draws every zone
+// on this backend into a single output:
+//
+//	playbin (one per zone) ─ intervideosink ─▶ intervideosrc ─ compositor ─ kmssink
+//	                       └ interaudiosink ─▶ interaudiosrc ─ audiomixer ─ autoaudiosink
+//
+// This approach:
+//   - One output surface and one audio device for the whole layout
+//     instead of a player per zone
+//   - One decoder per zone: its playbin moves to the next item on
+//     about-to-finish, so items follow each other without a gap
+//   - Zones link into the shared pipeline while it runs, so starting,
+//     stopping or changing one zone leaves the others playing
+//   - Zone position, size and stacking (zindex) from template.Zone, with
+//     per-zone opacity and alpha-preserving transparent zones
+//   - Renders through kmssink without a display server
+//   - Proof of play from the bus: each item starts at its stream-start
+//     and ends at the next one, or when the zone stops
+//
+// A zone keeps showing its last frame between items and while it
+// restarts.
+package vlc
+
+/*
+#cgo pkg-config: gstreamer-1.0
+#include <stdint.h>
+#include <stdlib.h>
+#include <gst/gst.h>
+
+extern char *goGstAboutToFinish(uintptr_t handle);
+
+static void gst_about_to_finish_cb(GstElement *playbin, gpointer data) {
+	char *uri = goGstAboutToFinish((uintptr_t)data);
+	if (uri != NULL) {
+		g_object_set(playbin, "uri", uri, NULL);
+		free(uri);
+	}
+}
+
+static gulong gst_on_about_to_finish(GstElement *playbin, uintptr_t h) {
+	return g_signal_connect(playbin, "about-to-finish", G_CALLBACK(gst_about_to_finish_cb), (gpointer)h);
+}
+
+// gst_parse parses a pipeline, owned by the caller, or a floating bin
+// with ghost pads. A message about
+// an error, fatal when NULL is returned, goes to errmsg (free with g_free).
+static GstElement *gst_parse(const char *desc, int bin, char **errmsg) {
+	GError *err = NULL;
+	GstElement *e;
+	if (bin)
+		e = gst_parse_bin_from_description(desc, TRUE, &err);
+	else
+		e = gst_parse_launch(desc, &err);
+	if (err != NULL) {
+		*errmsg = g_strdup(err->message);
+		g_error_free(err);
+	}
+	if (e != NULL && !bin)
+		gst_object_ref_sink(e);
+	return e;
+}
+
+// gst_make creates a top-level element, owned by the caller.
+static GstElement *gst_make(const char *factory) {
+	GstElement *e = gst_element_factory_make(factory, NULL);
+	if (e != NULL)
+		gst_object_ref_sink(e);
+	return e;
+}
+
+static GstElement *gst_child(GstElement *bin, const char *name) {
+	return gst_bin_get_by_name(GST_BIN(bin), name);
+}
+
+// gst_set sets a property from its string form, like gst-launch does.
+static void gst_set(gpointer obj, const char *name, const char *value) {
+	gst_util_set_object_arg(G_OBJECT(obj), name, value);
+}
+
+static void gst_set_element(GstElement *e, const char *name, GstElement *value) {
+	g_object_set(e, name, value, NULL);
+}
+
+static int gst_set_state(GstElement *e, int state) {
+	return gst_element_set_state(e, (GstState)state) != GST_STATE_CHANGE_FAILURE;
+}
+
+static void gst_sync_state(GstElement *e) {
+	gst_element_sync_state_with_parent(e);
+}
+
+// gst_attach adds src, a bin with a ghost src pad, to pipeline and links
+// it to a new request pad of sink. It returns the pad, or NULL.
+static GstPad *gst_attach(GstElement *pipeline, GstElement *src, GstElement *sink) {
+	GstPad *out, *in;
+	if (!gst_bin_add(GST_BIN(pipeline), src))
+		return NULL;
+	in = gst_element_request_pad_simple(sink, "sink_%u");
+	out = gst_element_get_static_pad(src, "src");
+	if (in == NULL || out == NULL || gst_pad_link(out, in) != GST_PAD_LINK_OK) {
+		if (out != NULL)
+			gst_object_unref(out);
+		if (in != NULL) {
+			gst_element_release_request_pad(sink, in);
+			gst_object_unref(in);
+		}
+		gst_bin_remove(GST_BIN(pipeline), src);
+		return NULL;
+	}
+	gst_object_unref(out);
+	return in;
+}
+
+// gst_detach stops src, releases its pad on sink and removes it.
+static void gst_detach(GstElement *pipeline, GstElement *src, GstElement *sink, GstPad *in) {
+	gst_element_set_state(src, GST_STATE_NULL);
+	gst_element_release_request_pad(sink, in);
+	gst_object_unref(in);
+	gst_bin_remove(GST_BIN(pipeline), src);
+}
+
+static GstBus *gst_bus(GstElement *e) {
+	return gst_element_get_bus(e);
+}
+
+// gst_next waits up to timeout ns for a message the backend handles and
+// returns its type, or 0. An error's text goes to errmsg (free with
+// g_free).
+static int gst_next(GstBus *bus, GstClockTime timeout, char **errmsg) {
+	GstMessage *m;
+	int type;
+
+	m = gst_bus_timed_pop_filtered(bus, timeout,
+		GST_MESSAGE_EOS | GST_MESSAGE_ERROR | GST_MESSAGE_STREAM_START);
+	if (m == NULL)
+		return 0;
+	type = GST_MESSAGE_TYPE(m);
+	if (type == GST_MESSAGE_ERROR) {
+		GError *err = NULL;
+		gchar *debug = NULL;
+		gst_message_parse_error(m, &err, &debug);
+		*errmsg = g_strdup(err != NULL ? err->message : "unknown error");
+		g_clear_error(&err);
+		g_free(debug);
+	}
+	gst_message_unref(m);
+	return type;
+}
+
+// gst_flush drops the messages waiting on bus.
+static void gst_flush(GstBus *bus) {
+	gst_bus_set_flushing(bus, TRUE);
+	gst_bus_set_flushing(bus, FALSE);
+}
+
+static void gst_unref(gpointer obj) {
+	gst_object_unref(obj);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/cgo"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+)
+
+// gstPoll is how long a run loop waits for a bus message before it
+// checks for Stop and timers again.
+const gstPoll = 50 * time.Millisecond
+
+// gstRetry is the pause before the shared pipeline restarts after an
+// error.
+const gstRetry = time.Second
+
+// Message types returned by gst_next.
+var (
+	gstEOS         = int(C.GST_MESSAGE_EOS)
+	gstError       = int(C.GST_MESSAGE_ERROR)
+	gstStreamStart = int(C.GST_MESSAGE_STREAM_START)
+)
+
+var (
+	gstInitOnce sync.Once
+	gstInitErr  error
+
+	gstMu     sync.Mutex
+	gstShared *gstOutput
+
+	gstChannels atomic.Int64 // numbers the inter channels of zones
+)
+
+// gstInit initialises GStreamer once per process.
+func gstInit() error {
+	gstInitOnce.Do(func() {
+		var gerr *C.GError
+		if C.gst_init_check(nil, nil, &gerr) == 0 {
+			gstInitErr = errors.New("gstreamer init failed")
+			if gerr != nil {
+				gstInitErr = fmt.Errorf("gstreamer init failed: %s", C.GoString((*C.char)(unsafe.Pointer(gerr.message))))
+				C.g_error_free(gerr)
+			}
+		}
+	})
+	return gstInitErr
+}
+
+// gstParse parses a pipeline description, or a bin with ghost pads
+// when bin is set.
+func gstParse(desc string, bin bool) (*C.GstElement, error) {
+	cdesc := C.CString(desc)
+	defer C.free(unsafe.Pointer(cdesc))
+	var cbin C.int
+	if bin {
+		cbin = 1
+	}
+	var msg *C.char
+	e := C.gst_parse(cdesc, cbin, &msg)
+	if msg != nil {
+		defer C.g_free(C.gpointer(unsafe.Pointer(msg)))
+		if e == nil {
+			return nil, fmt.Errorf("gstreamer: %s", C.GoString(msg))
+		}
+		log.Printf("[gst] %q: %s", desc, C.GoString(msg))
+	}
+	if e == nil {
+		return nil, fmt.Errorf("gstreamer: cannot build %q", desc)
+	}
+	return e, nil
+}
+
+// gstSet sets a property of a GStreamer object from its string form.
+func gstSet(obj unsafe.Pointer, name, value string) {
+	cname, cvalue := C.CString(name), C.CString(value)
+	C.gst_set(C.gpointer(obj), cname, cvalue)
+	C.free(unsafe.Pointer(cname))
+	C.free(unsafe.Pointer(cvalue))
+}
+
+func gstSetState(e *C.GstElement, state C.GstState) bool {
+	return C.gst_set_state(e, C.int(state)) != 0
+}
+
+func gstUnref(obj unsafe.Pointer) {
+	C.gst_unref(C.gpointer(obj))
+}
+
+// gstNext returns the next message on bus within gstPoll: its type, or
+// 0 if none came, and the text of an error.
+func gstNext(bus *C.GstBus) (int, string) {
+	var msg *C.char
+	kind := int(C.gst_next(bus, C.GstClockTime(gstPoll.Nanoseconds()), &msg))
+	var text string
+	if msg != nil {
+		text = C.GoString(msg)
+		C.g_free(C.gpointer(unsafe.Pointer(msg)))
+	}
+	return kind, text
+}
+
+// gstOutput is the shared pipeline that composites all zones.
+type gstOutput struct {
+	refs int // guarded by gstMu
+
+	pipeline *C.GstElement
+	comp     *C.GstElement // compositor
+	mix      *C.GstElement // audiomixer
+	quit     chan struct{}
+	done     chan struct{}
+
+	mu    sync.Mutex
+	links map[*gstLink]bool // zones linked in
+}
+
+// gstLink is one zone's branches into the shared pipeline.
+type gstLink struct {
+	zone   template.Zone
+	video  *C.GstElement // source bins
+	audio  *C.GstElement
+	vpad   *C.GstPad // request pads on the compositor and the mixer
+	apad   *C.GstPad
+	failed chan error // receives shared pipeline errors
+}
+
+// acquireOutput returns the shared pipeline, starting it for the first
+// zone. A template with a different screen size gets a new pipeline
+// once every zone has released the old one.
+func acquireOutput(screenW, screenH int) (*gstOutput, error) {
+	gstMu.Lock()
+	defer gstMu.Unlock()
+	if gstShared != nil {
+		gstShared.refs++
+		return gstShared, nil
+	}
+
+	kms := runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+	p, err := gstParse(gstOutputDesc(screenW, screenH, kms), false)
+	if err != nil {
+		return nil, err
+	}
+	o := &gstOutput{
+		refs:     1,
+		pipeline: p,
+		comp:     gstChild(p, "comp"),
+		mix:      gstChild(p, "mix"),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		links:    make(map[*gstLink]bool),
+	}
+	if o.comp == nil || o.mix == nil || !gstSetState(p, C.GST_STATE_PLAYING) {
+		o.free()
+		return nil, errors.New("gstreamer: cannot start the output pipeline")
+	}
+	go o.watch()
+	gstShared = o
+	log.Printf("[gst] output pipeline started (screen %dx%d, kms=%v)", screenW, screenH, kms)
+	return o, nil
+}
+
+func gstChild(bin *C.GstElement, name string) *C.GstElement {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C.gst_child(bin, cname)
+}
+
+// releaseOutput drops a zone's reference and stops the pipeline after
+// the last one.
+func releaseOutput(o *gstOutput) {
+	gstMu.Lock()
+	defer gstMu.Unlock()
+	o.refs--
+	if o.refs > 0 {
+		return
+	}
+	close(o.quit)
+	<-o.done
+	o.free()
+	if gstShared == o {
+		gstShared = nil
+	}
+	log.Println("[gst] output pipeline stopped")
+}
+
+func (o *gstOutput) free() {
+	gstSetState(o.pipeline, C.GST_STATE_NULL)
+	if o.comp != nil {
+		gstUnref(unsafe.Pointer(o.comp))
+	}
+	if o.mix != nil {
+		gstUnref(unsafe.Pointer(o.mix))
+	}
+	gstUnref(unsafe.Pointer(o.pipeline))
+}
+
+// watch reports pipeline errors to the linked zones and restarts the
+// pipeline, until quit is closed.
+func (o *gstOutput) watch() {
+	defer close(o.done)
+	bus := C.gst_bus(o.pipeline)
+	defer gstUnref(unsafe.Pointer(bus))
+	for {
+		select {
+		case <-o.quit:
+			return
+		default:
+		}
+		kind, text := gstNext(bus)
+		if kind != gstError {
+			continue
+		}
+		err := fmt.Errorf("gstreamer output: %s", text)
+		log.Printf("[gst] %v, restarting the output", err)
+		o.mu.Lock()
+		for l := range o.links {
+			select {
+			case l.failed <- err:
+			default:
+			}
+		}
+		o.mu.Unlock()
+
+		gstSetState(o.pipeline, C.GST_STATE_NULL)
+		select {
+		case <-o.quit:
+			return
+		case <-time.After(gstRetry):
+		}
+		C.gst_flush(bus)
+		gstSetState(o.pipeline, C.GST_STATE_PLAYING)
+	}
+}
+
+// link adds a zone's video and audio branches, fed from the inter
+// channel, to the pipeline.
+func (o *gstOutput) link(zone template.Zone, screenW, screenH int, channel string) (*gstLink, error) {
+	video, err := gstParse(gstVideoSourceDesc(zone, screenW, screenH, channel), true)
+	if err != nil {
+		return nil, err
+	}
+	audio, err := gstParse(gstAudioSourceDesc(channel), true)
+	if err != nil {
+		gstUnref(unsafe.Pointer(video))
+		return nil, err
+	}
+
+	l := &gstLink{zone: zone, video: video, audio: audio, failed: make(chan error, 1)}
+	if l.vpad = C.gst_attach(o.pipeline, video, o.comp); l.vpad == nil {
+		gstUnref(unsafe.Pointer(audio))
+		return nil, errors.New("gstreamer: cannot link the zone into the compositor")
+	}
+	for _, p := range gstPadProps(zone, screenW, screenH) {
+		gstSet(unsafe.Pointer(l.vpad), p[0], p[1])
+	}
+	if l.apad = C.gst_attach(o.pipeline, audio, o.mix); l.apad == nil {
+		C.gst_detach(o.pipeline, video, o.comp, l.vpad)
+		return nil, errors.New("gstreamer: cannot link the zone into the mixer")
+	}
+
+	o.mu.Lock()
+	o.links[l] = true
+	o.restackLocked()
+	o.mu.Unlock()
+
+	C.gst_sync_state(video)
+	C.gst_sync_state(audio)
+	return l, nil
+}
+
+// unlink removes a zone's branches.
+func (o *gstOutput) unlink(l *gstLink) {
+	o.mu.Lock()
+	delete(o.links, l)
+	o.restackLocked()
+	o.mu.Unlock()
+
+	C.gst_detach(o.pipeline, l.video, o.comp, l.vpad)
+	C.gst_detach(o.pipeline, l.audio, o.mix, l.apad)
+}
+
+// restackLocked sets the compositor z-order of the linked zones, above
+// the background source on sink_0. o.mu must be held.
+func (o *gstOutput) restackLocked() {
+	links := make([]*gstLink, 0, len(o.links))
+	for l := range o.links {
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool { return gstBelow(links[i].zone, links[j].zone) })
+	for i, l := range links {
+		gstSet(unsafe.Pointer(l.vpad), "zorder", strconv.Itoa(i+1))
+	}
+}
+
+// gstBackend plays one zone through its own playbin, linked into the
+// shared pipeline while PlayAll runs.
+type gstBackend struct {
+	zone    template.Zone
+	screenW int
+	screenH int
+	out     *gstOutput
+
+	mu     sync.Mutex
+	volume int
+	emit   EventFunc
+	run    *gstRun // nil unless PlayAll is running
+}
+
+// gstRun is the player state of one PlayAll call.
+type gstRun struct {
+	playbin *C.GstElement
+	tracker *playTracker
+	stop    chan struct{} // closed by Stop
+	skip    chan struct{} // asks the run loop for the next item
+	wake    chan struct{} // ends an image's wait in about-to-finish
+
+	mu     sync.Mutex
+	items  []media.Item // absolute paths
+	next   int          // index in items of the item to queue next
+	queued []media.Item // items given to playbin that have not started
+	last   media.Item   // the item given to playbin last
+	ended  bool         // an item reached its end since the last error
+}
+
+func newGstBackend() (Backend, error) {
+	if err := gstInit(); err != nil {
+		return nil, err
+	}
+	return &gstBackend{volume: 100}, nil
+}
+
+func (b *gstBackend) Init(zone template.Zone, screenW, screenH int) error {
+	out, err := acquireOutput(screenW, screenH)
+	if err != nil {
+		return err
+	}
+	b.zone = zone
+	b.screenW = screenW
+	b.screenH = screenH
+	b.out = out
+	log.Printf("[gst:%s] initialised (screen %dx%d)", zone.ID, screenW, screenH)
+	return nil
+}
+
+//export goGstAboutToFinish
+func goGstAboutToFinish(handle C.uintptr_t) *C.char {
+	r := cgo.Handle(handle).Value().(*gstRun)
+	uri, ok := r.aboutToFinish()
+	if !ok {
+		return nil
+	}
+	return C.CString(uri)
+}
+
+// aboutToFinish returns the URI playbin plays next, once the current
+// item has been shown for as long as it should. It runs on a GStreamer
+// streaming thread and reports false when the run is stopping.
+func (r *gstRun) aboutToFinish() (string, bool) {
+	r.mu.Lock()
+	cur := r.last
+	r.ended = true
+	r.mu.Unlock()
+
+	// An image is decoded at once: hold it here for its duration.
+	if media.Detect(cur.Path) == media.Image {
+		d := cur.Duration
+		if d <= 0 {
+			d = media.DefaultImageDuration * time.Second
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-r.stop:
+			return "", false
+		case <-r.wake:
+			return "", false
+		case <-t.C:
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return gstURI(r.queueLocked().Path), true
+}
+
+// queueLocked takes the next item off the playlist as the one given to
+// playbin. r.mu must be held.
+func (r *gstRun) queueLocked() media.Item {
+	it := r.items[r.next]
+	r.next = (r.next + 1) % len(r.items)
+	r.queued = append(r.queued, it)
+	r.last = it
+	return it
+}
+
+func (b *gstBackend) PlayAll(items []media.Item, stopCh <-chan struct{}) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+
+	channel := b.zone.ID + "-" + strconv.FormatInt(gstChannels.Add(1), 10)
+	link, err := b.out.link(b.zone, b.screenW, b.screenH, channel)
+	if err != nil {
+		return err
+	}
+	defer b.out.unlink(link)
+
+	r := &gstRun{
+		tracker: newPlayTracker(b.zone.ID, items),
+		stop:    make(chan struct{}),
+		skip:    make(chan struct{}, 1),
+		wake:    make(chan struct{}, 1),
+		items:   absItems(items),
+	}
+	if err := b.newPlaybin(r, channel); err != nil {
+		return err
+	}
+	defer gstUnref(unsafe.Pointer(r.playbin))
+	handle := cgo.NewHandle(r)
+	defer handle.Delete()
+	C.gst_on_about_to_finish(r.playbin, C.uintptr_t(handle))
+	bus := C.gst_bus(r.playbin)
+	defer gstUnref(unsafe.Pointer(bus))
+
+	b.mu.Lock()
+	gstSet(unsafe.Pointer(r.playbin), "volume", strconv.FormatFloat(float64(b.volume)/100, 'f', -1, 64))
+	r.tracker.setEmit(b.emit)
+	b.run = r
+	b.mu.Unlock()
+
+	log.Printf("[gst:%s] playing %d items (looped)", b.zone.ID, len(items))
+	err = b.play(r, bus)
+	if err == nil {
+		err = b.wait(r, bus, link, stopCh)
+	}
+
+	b.mu.Lock()
+	b.run = nil
+	b.mu.Unlock()
+
+	// NULL waits for the streaming threads, so no about-to-finish call
+	// outlives the handle.
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	gstSetState(r.playbin, C.GST_STATE_NULL)
+	r.tracker.finish(time.Now(), false)
+	return err
+}
+
+// newPlaybin creates the zone's playbin, sending its picture and sound
+// to the shared pipeline over channel.
+func (b *gstBackend) newPlaybin(r *gstRun, channel string) error {
+	factory := C.CString("playbin")
+	r.playbin = C.gst_make(factory)
+	C.free(unsafe.Pointer(factory))
+	if r.playbin == nil {
+		return errors.New("gstreamer: playbin not available (install gstreamer1.0-plugins-base)")
+	}
+
+	vsink, err := gstParse(gstVideoSinkDesc(b.zone, b.screenW, b.screenH, channel), true)
+	if err != nil {
+		gstUnref(unsafe.Pointer(r.playbin))
+		return err
+	}
+	asink, err := gstParse(gstAudioSinkDesc(channel), true)
+	if err != nil {
+		gstUnref(unsafe.Pointer(vsink))
+		gstUnref(unsafe.Pointer(r.playbin))
+		return err
+	}
+	for name, sink := range map[string]*C.GstElement{"video-sink": vsink, "audio-sink": asink} {
+		cname := C.CString(name)
+		C.gst_set_element(r.playbin, cname, sink)
+		C.free(unsafe.Pointer(cname))
+	}
+	gstSet(unsafe.Pointer(r.playbin), "flags", "video+audio+soft-volume")
+	return nil
+}
+
+// play gives playbin the next item and starts it from the top, dropping
+// whatever it was playing.
+func (b *gstBackend) play(r *gstRun, bus *C.GstBus) error {
+	// Let an image held in about-to-finish go, or READY would wait
+	// for it.
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	gstSetState(r.playbin, C.GST_STATE_READY)
+	C.gst_flush(bus)
+	select {
+	case <-r.wake:
+	default:
+	}
+
+	r.mu.Lock()
+	r.queued = nil
+	it := r.queueLocked()
+	r.mu.Unlock()
+
+	gstSet(unsafe.Pointer(r.playbin), "uri", gstURI(it.Path))
+	if !gstSetState(r.playbin, C.GST_STATE_PLAYING) {
+		return fmt.Errorf("gstreamer: cannot play %s", it.Path)
+	}
+	return nil
+}
+
+// wait handles bus messages until the run is stopped. It returns an
+// error when every item in a row has failed to play.
+func (b *gstBackend) wait(r *gstRun, bus *C.GstBus, link *gstLink, stopCh <-chan struct{}) error {
+	failures := 0
+	var cut *time.Timer // ends a video that has a duration
+	var cutC <-chan time.Time
+	defer func() {
+		if cut != nil {
+			cut.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case <-r.stop:
+			return nil
+		case err := <-link.failed:
+			return err
+		case <-r.skip:
+			r.tracker.interrupt()
+			if err := b.play(r, bus); err != nil {
+				return err
+			}
+			continue
+		case <-cutC:
+			cutC = nil
+			if err := b.play(r, bus); err != nil {
+				return err
+			}
+			continue
+		default:
+		}
+
+		kind, text := gstNext(bus)
+		switch kind {
+		case gstStreamStart:
+			r.mu.Lock()
+			if len(r.queued) == 0 {
+				r.mu.Unlock()
+				continue
+			}
+			it := r.queued[0]
+			r.queued = r.queued[1:]
+			r.mu.Unlock()
+
+			r.tracker.advance(gstURI(it.Path), time.Now())
+
+			gstSet(unsafe.Pointer(r.playbin), "mute", strconv.FormatBool(it.Mute))
+			if cut != nil {
+				cut.Stop()
+				cutC = nil
+			}
+			if it.Duration > 0 && media.Detect(it.Path) != media.Image {
+				cut = time.NewTimer(it.Duration)
+				cutC = cut.C
+			}
+
+		case gstEOS:
+			// about-to-finish gave playbin nothing more to play.
+			if err := b.play(r, bus); err != nil {
+				return err
+			}
+
+		case gstError:
+			r.mu.Lock()
+			if r.ended {
+				failures = 0
+				r.ended = false
+			}
+			n := len(r.items)
+			r.mu.Unlock()
+			failures++
+			if failures >= n {
+				return fmt.Errorf("gstreamer: no item could be played: %s", text)
+			}
+			log.Printf("[gst:%s] playback error, skipping item: %s", b.zone.ID, text)
+			r.tracker.interrupt()
+			if err := b.play(r, bus); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// running returns the current run.
+func (b *gstBackend) running() (*gstRun, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.run == nil {
+		return nil, fmt.Errorf("%w: not playing", ErrUnsupported)
+	}
+	return b.run, nil
+}
+
+// Next implements Skipper.
+func (b *gstBackend) Next() error {
+	r, err := b.running()
+	if err != nil {
+		return err
+	}
+	select {
+	case r.skip <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pause implements Pauser.
+func (b *gstBackend) Pause() error {
+	return b.setState(C.GST_STATE_PAUSED)
+}
+
+// Resume implements Pauser.
+func (b *gstBackend) Resume() error {
+	return b.setState(C.GST_STATE_PLAYING)
+}
+
+func (b *gstBackend) setState(state C.GstState) error {
+	r, err := b.running()
+	if err != nil {
+		return err
+	}
+	if !gstSetState(r.playbin, state) {
+		return errors.New("gstreamer: state change failed")
+	}
+	return nil
+}
+
+// UpdatePlaylist implements PlaylistUpdater. The item on screen plays
+// to its end and the new list takes over from there.
+func (b *gstBackend) UpdatePlaylist(items []media.Item) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+	r, err := b.running()
+	if err != nil {
+		return err
+	}
+
+	next := absItems(items)
+	r.mu.Lock()
+	start := resumeIndex(r.items, r.last.Path, next)
+	r.items = next
+	r.next = start
+	r.mu.Unlock()
+	r.tracker.setItems(items)
+
+	log.Printf("[gst:%s] playlist queued for next item (%d items, resuming at #%d)", b.zone.ID, len(items), start+1)
+	return nil
+}
+
+// SetVolume implements VolumeSetter. playbin's volume is linear, 1.0
+// being 100%.
+func (b *gstBackend) SetVolume(percent int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.volume = percent
+	if b.run != nil {
+		gstSet(unsafe.Pointer(b.run.playbin), "volume", strconv.FormatFloat(float64(percent)/100, 'f', -1, 64))
+	}
+	return nil
+}
+
+// SetEventFunc implements EventSource.
+func (b *gstBackend) SetEventFunc(fn EventFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit = fn
+	if b.run != nil {
+		b.run.tracker.setEmit(fn)
+	}
+}
+
+func (b *gstBackend) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.run != nil {
+		select {
+		case <-b.run.stop:
+		default:
+			close(b.run.stop)
+		}
+	}
+}
+
+func (b *gstBackend) Release() {
+	b.Stop()
+	if b.out != nil {
+		releaseOutput(b.out)
+		b.out = nil
+	}
+	log.Printf("[gst:%s] released", b.zone.ID)
+}
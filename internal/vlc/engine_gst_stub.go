@@ -0,0 +1,11 @@
+//go:build !gstreamer || !cgo
+
+package vlc
+
+import "errors"
+
+// newGstBackend fails: build with -tags gstreamer (and CGO enabled) for
+// the GStreamer backend.
+func newGstBackend() (Backend, error) {
+	return nil, errors.New("gstreamer backend not built in (build with -tags gstreamer)")
+}
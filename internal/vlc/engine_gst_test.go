@@ -0,0 +1,61 @@
+package vlc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"player-native/internal/template"
+)
+
+// TestGstDescriptions checks zone placement and stacking on the shared
+// compositor and the per-zone sinks and sources around it.
+func TestGstDescriptions(t *testing.T) {
+	half := 0.5
+	sidebar := template.Zone{ID: "sidebar", X: 75, Y: 0, Width: 25, Height: 100, Zindex: 1, Transparent: true, Opacity: &half}
+	main := template.Zone{ID: "main", X: 0, Y: 0, Width: 75, Height: 85, Rotate: 90}
+
+	if got := gstOutputDesc(1920, 1080, true); !strings.Contains(got, "! video/x-raw,width=1920,height=1080,framerate=30/1 ! videoconvert ! queue ! kmssink") ||
+		!strings.Contains(got, "audiomixer name=mix") {
+		t.Errorf("output: %s", got)
+	}
+
+	for _, tc := range []struct {
+		zone template.Zone
+		want [][2]string
+	}{
+		{sidebar, [][2]string{{"xpos", "1440"}, {"ypos", "0"}, {"width", "480"}, {"height", "1080"}, {"alpha", "0.5"}}},
+		{main, [][2]string{{"xpos", "0"}, {"ypos", "0"}, {"width", "1440"}, {"height", "918"}, {"alpha", "1"}}},
+	} {
+		if got := gstPadProps(tc.zone, 1920, 1080); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s pad: got %v, want %v", tc.zone.ID, got, tc.want)
+		}
+	}
+
+	if got := gstVideoSinkDesc(sidebar, 1920, 1080, "sidebar-1"); got !=
+		"videoconvert ! videoscale ! videorate ! video/x-raw,format=BGRA,width=480,height=1080,framerate=30/1,pixel-aspect-ratio=1/1 ! intervideosink channel=sidebar-1" {
+		t.Errorf("sidebar sink: %s", got)
+	}
+	if got := gstVideoSinkDesc(main, 1920, 1080, "main-2"); !strings.HasPrefix(got, "videoflip method=clockwise ! ") ||
+		!strings.Contains(got, "format=I420,width=1440,height=918") {
+		t.Errorf("main sink: %s", got)
+	}
+	if got := gstVideoSourceDesc(main, 1920, 1080, "main-2"); !strings.HasPrefix(got, "intervideosrc channel=main-2 timeout=") {
+		t.Errorf("main source: %s", got)
+	}
+	if !strings.Contains(gstAudioSinkDesc("main-2"), "interaudiosink channel=main-2") ||
+		!strings.HasPrefix(gstAudioSourceDesc("main-2"), "interaudiosrc channel=main-2 ") {
+		t.Error("audio branches do not share the zone's channel")
+	}
+
+	if !gstBelow(main, sidebar) || gstBelow(sidebar, main) {
+		t.Error("main (zindex 0) should be below sidebar (zindex 1)")
+	}
+	if !gstBelow(template.Zone{ID: "a"}, template.Zone{ID: "b"}) {
+		t.Error("equal zindex should order by zone ID")
+	}
+
+	if got := gstURI("/p/ad 1.jpg"); got != "file:///p/ad%201.jpg" {
+		t.Errorf("uri: %s", got)
+	}
+}
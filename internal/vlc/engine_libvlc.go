@@ -0,0 +1,572 @@
+//go:build libvlc && cgo
+
+// Native libVLC backend (build with -tags libvlc, CGO enabled). VLC runs
+// in-process, one libVLC instance per zone:
+//
+//   - Renders through DRM/KMS when no display server is running, so the
+//     player needs no desktop session (Raspberry Pi OS Lite)
+//   - Gapless playback via a media list player in loop mode
+//   - Item boundaries and errors arrive as libVLC events
+//   - Skip, pause, volume and now-playing without a control socket
+//   - Playlist swaps at the next item boundary, by replacing the media
+//     list under the running list player
+//
+// If libVLC cannot be initialised the subprocess backend is used instead.
+package vlc
+
+/*
+#cgo pkg-config: libvlc
+#include <stdint.h>
+#include <stdlib.h>
+#include <vlc/vlc.h>
+
+extern void goLibvlcEvent(int type, libvlc_media_t *item, uintptr_t handle);
+
+static void libvlc_event_cb(const struct libvlc_event_t *ev, void *data) {
+	libvlc_media_t *item = NULL;
+	if (ev->type == libvlc_MediaListPlayerNextItemSet)
+		item = ev->u.media_list_player_next_item_set.item;
+	goLibvlcEvent(ev->type, item, (uintptr_t)data);
+}
+
+static int libvlc_attach(libvlc_event_manager_t *em, int type, uintptr_t h) {
+	return libvlc_event_attach(em, type, libvlc_event_cb, (void *)h);
+}
+
+static void libvlc_detach(libvlc_event_manager_t *em, int type, uintptr_t h) {
+	libvlc_event_detach(em, type, libvlc_event_cb, (void *)h);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"runtime/cgo"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+)
+
+var (
+	libvlcOnce sync.Once
+	libvlcOK   bool
+)
+
+// newBackend returns the libVLC backend, or the subprocess backend if
+// libVLC fails to initialise (e.g. missing plugins).
+func newBackend() (Backend, error) {
+	libvlcOnce.Do(func() {
+		inst, err := newInstance([]string{"--quiet"})
+		if err != nil {
+			log.Printf("[libvlc] %v, falling back to VLC subprocess", err)
+			return
+		}
+		C.libvlc_release(inst)
+		libvlcOK = true
+	})
+	if !libvlcOK {
+		return newSubprocessBackend(), nil
+	}
+	return &libvlcBackend{}, nil
+}
+
+// libvlcBackend plays one zone through an in-process libVLC instance.
+type libvlcBackend struct {
+	mu   sync.Mutex
+	inst *C.libvlc_instance_t
+	zone template.Zone
+	kms  bool // holds the DRM/KMS output
+	emit EventFunc
+	run  *libvlcRun // nil unless PlayAll is running
+}
+
+// libvlcRun is the player state of one PlayAll call. Its C objects are
+// only touched under libvlcBackend.mu while it is the current run.
+type libvlcRun struct {
+	player  *C.libvlc_media_player_t
+	list    *C.libvlc_media_list_t
+	lp      *C.libvlc_media_list_player_t
+	tracker *playTracker
+	events  chan libvlcEvent
+	stop    chan struct{} // closed by Stop
+
+	items []media.Item // the playlist
+	n     int          // playable items in list
+
+	// After a playlist swap the item on screen is no longer in list, and
+	// the list player's position is the one it had in the old list.
+	swapped *C.libvlc_media_t // the item on screen at the last swap
+	swapPos int               // its position in the old list
+}
+
+// libvlcEvent is a libVLC event handed from a VLC thread to PlayAll.
+type libvlcEvent struct {
+	kind C.int
+	mrl  string
+}
+
+// libvlcEventTypes are the events PlayAll listens to, with the event
+// manager (list player or media player) that emits them.
+var libvlcEventTypes = []struct {
+	kind       C.int
+	listPlayer bool
+}{
+	{C.libvlc_MediaListPlayerNextItemSet, true},
+	{C.libvlc_MediaPlayerPlaying, false},
+	{C.libvlc_MediaPlayerEncounteredError, false},
+}
+
+//export goLibvlcEvent
+func goLibvlcEvent(kind C.int, item *C.libvlc_media_t, handle C.uintptr_t) {
+	r := cgo.Handle(handle).Value().(*libvlcRun)
+	ev := libvlcEvent{kind: kind}
+	if item != nil {
+		if mrl := C.libvlc_media_get_mrl(item); mrl != nil {
+			ev.mrl = C.GoString(mrl)
+			C.libvlc_free(unsafe.Pointer(mrl))
+		}
+	}
+	// Never block a VLC thread; PlayAll drains the channel promptly.
+	select {
+	case r.events <- ev:
+	default:
+	}
+}
+
+func newInstance(args []string) (*C.libvlc_instance_t, error) {
+	argv := make([]*C.char, len(args))
+	for i, a := range args {
+		argv[i] = C.CString(a)
+	}
+	defer func() {
+		for _, p := range argv {
+			C.free(unsafe.Pointer(p))
+		}
+	}()
+	var argp **C.char
+	if len(argv) > 0 {
+		argp = &argv[0]
+	}
+	inst := C.libvlc_new(C.int(len(argv)), argp)
+	if inst == nil {
+		return nil, fmt.Errorf("libvlc init failed: %s", libvlcErr())
+	}
+	return inst, nil
+}
+
+func libvlcErr() string {
+	if msg := C.libvlc_errmsg(); msg != nil {
+		return C.GoString(msg)
+	}
+	return "unknown error"
+}
+
+func (b *libvlcBackend) Init(zone template.Zone, screenW, screenH int) error {
+	kms, err := claimKMS(zone, screenW, screenH)
+	if err != nil {
+		return err // ErrFallback: the engine uses the subprocess backend
+	}
+	args := libvlcArgs(zone, screenW, screenH, kms)
+	inst, err := newInstance(args)
+	if err != nil {
+		if kms {
+			releaseKMS(zone.ID)
+		}
+		return err
+	}
+	b.inst = inst
+	b.zone = zone
+	b.kms = kms
+
+	output := "window"
+	if kms {
+		output = "DRM/KMS"
+	}
+	if zone.Transparent || zone.Opacity != nil {
+		log.Printf("[libvlc:%s] warning: transparency and opacity are not supported by this backend", zone.ID)
+	}
+	if zone.Zindex != 0 {
+		log.Printf("[libvlc:%s] warning: zindex is not supported by this backend, overlapping zones stack in start order", zone.ID)
+	}
+	log.Printf("[libvlc:%s] initialised (%s, screen %dx%d)", zone.ID, output, screenW, screenH)
+	return nil
+}
+
+// libvlcArgs returns the instance options for a zone. Only core and
+// base-plugin options are used: libvlc_new fails on options of plugins
+// that are not installed (e.g. the Qt interface).
+func libvlcArgs(zone template.Zone, screenW, screenH int, kms bool) []string {
+	args := []string{
+		"--no-video-title-show",
+		"--no-osd",
+		"--no-spu",
+		"--no-video-deco",
+		"--mouse-hide-timeout=0",
+		"--no-random",
+
+		"--avcodec-hw=any",
+		"--avcodec-threads=0",
+		"--avcodec-skiploopfilter=0",
+
+		"--file-caching=8000",
+		"--network-caching=3000",
+		"--clock-jitter=0",
+		"--deinterlace=0",
+
+		"--image-duration=" + strconv.Itoa(media.DefaultImageDuration),
+		"--quiet",
+	}
+	args = append(args, rotateArgs(zone)...)
+	if kms {
+		// Straight to the display controller; no X11/Wayland.
+		args = append(args, "--no-xlib", "--vout=drm_vout,any", "--aout=alsa")
+		return args
+	}
+	args = append(args, "--video-on-top")
+	return append(args, windowArgs(zone, screenW, screenH)...)
+}
+
+func (b *libvlcBackend) PlayAll(items []media.Item, stopCh <-chan struct{}) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+
+	r := &libvlcRun{
+		tracker: newPlayTracker(b.zone.ID, items),
+		items:   items,
+		events:  make(chan libvlcEvent, 64),
+		stop:    make(chan struct{}),
+	}
+	if err := b.load(r, items); err != nil {
+		b.free(r)
+		return err
+	}
+	handle := cgo.NewHandle(r)
+	defer handle.Delete()
+
+	b.mu.Lock()
+	r.tracker.setEmit(b.emit)
+	for _, e := range libvlcEventTypes {
+		C.libvlc_attach(r.eventManager(e.listPlayer), e.kind, C.uintptr_t(handle))
+	}
+	b.run = r
+	C.libvlc_media_list_player_play(r.lp)
+	b.mu.Unlock()
+
+	log.Printf("[libvlc:%s] playing %d items (looped)", b.zone.ID, len(items))
+
+	err := b.wait(r, stopCh)
+
+	b.mu.Lock()
+	b.run = nil
+	b.mu.Unlock()
+
+	C.libvlc_media_list_player_stop(r.lp)
+	for _, e := range libvlcEventTypes {
+		C.libvlc_detach(r.eventManager(e.listPlayer), e.kind, C.uintptr_t(handle))
+	}
+	r.tracker.finish(time.Now(), false)
+	b.free(r)
+	return err
+}
+
+// load builds the media list and list player for items.
+func (b *libvlcBackend) load(r *libvlcRun, items []media.Item) error {
+	r.list = C.libvlc_media_list_new(b.inst)
+	r.player = C.libvlc_media_player_new(b.inst)
+	r.lp = C.libvlc_media_list_player_new(b.inst)
+	if r.list == nil || r.player == nil || r.lp == nil {
+		return fmt.Errorf("libvlc player: %s", libvlcErr())
+	}
+
+	medias := b.newMedias(items)
+	if len(medias) == 0 {
+		return fmt.Errorf("no playable items")
+	}
+	for _, m := range medias {
+		C.libvlc_media_list_add_media(r.list, m)
+		C.libvlc_media_release(m) // the list holds its own reference
+	}
+	r.n = len(medias)
+
+	C.libvlc_media_list_player_set_media_player(r.lp, r.player)
+	C.libvlc_media_list_player_set_media_list(r.lp, r.list)
+	C.libvlc_media_list_player_set_playback_mode(r.lp, C.libvlc_playback_mode_loop)
+	return nil
+}
+
+// newMedias creates the media of items with their options, skipping
+// those libVLC rejects. The caller owns the returned references.
+func (b *libvlcBackend) newMedias(items []media.Item) []*C.libvlc_media_t {
+	var medias []*C.libvlc_media_t
+	for _, it := range absItems(items) {
+		path := C.CString(it.Path)
+		m := C.libvlc_media_new_path(b.inst, path)
+		C.free(unsafe.Pointer(path))
+		if m == nil {
+			log.Printf("[libvlc:%s] skipping %s: %s", b.zone.ID, it.Path, libvlcErr())
+			continue
+		}
+		for _, opt := range itemOptions(it) {
+			o := C.CString(opt)
+			C.libvlc_media_add_option(m, o)
+			C.free(unsafe.Pointer(o))
+		}
+		medias = append(medias, m)
+	}
+	return medias
+}
+
+// wait handles player events until the run is stopped. It returns an
+// error when every item in a row has failed to play.
+func (b *libvlcBackend) wait(r *libvlcRun, stopCh <-chan struct{}) error {
+	failures := 0
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case <-r.stop:
+			return nil
+		case ev := <-r.events:
+			switch ev.kind {
+			case C.libvlc_MediaListPlayerNextItemSet:
+				r.tracker.advance(ev.mrl, time.Now())
+			case C.libvlc_MediaPlayerPlaying:
+				failures = 0
+			case C.libvlc_MediaPlayerEncounteredError:
+				failures++
+				b.mu.Lock()
+				n := r.n
+				b.mu.Unlock()
+				if failures >= n {
+					return fmt.Errorf("libvlc: no item could be played")
+				}
+				// The list player only advances on end-of-stream.
+				log.Printf("[libvlc:%s] playback error, skipping item", b.zone.ID)
+				r.tracker.interrupt()
+				b.mu.Lock()
+				if b.run == r {
+					C.libvlc_media_list_player_next(r.lp)
+				}
+				b.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (r *libvlcRun) eventManager(listPlayer bool) *C.libvlc_event_manager_t {
+	if listPlayer {
+		return C.libvlc_media_list_player_event_manager(r.lp)
+	}
+	return C.libvlc_media_player_event_manager(r.player)
+}
+
+// free releases the C objects of a run that is no longer current.
+func (b *libvlcBackend) free(r *libvlcRun) {
+	if r.lp != nil {
+		C.libvlc_media_list_player_release(r.lp)
+	}
+	if r.player != nil {
+		C.libvlc_media_player_release(r.player)
+	}
+	if r.list != nil {
+		C.libvlc_media_list_release(r.list)
+	}
+	if r.swapped != nil {
+		C.libvlc_media_release(r.swapped)
+	}
+}
+
+// current calls fn with the running player under the lock.
+func (b *libvlcBackend) current(fn func(r *libvlcRun) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.run == nil {
+		return fmt.Errorf("%w: not playing", ErrUnsupported)
+	}
+	return fn(b.run)
+}
+
+// Next implements Skipper.
+func (b *libvlcBackend) Next() error {
+	return b.current(func(r *libvlcRun) error {
+		r.tracker.interrupt()
+		if C.libvlc_media_list_player_next(r.lp) != 0 {
+			return fmt.Errorf("libvlc next: %s", libvlcErr())
+		}
+		return nil
+	})
+}
+
+// UpdatePlaylist implements PlaylistUpdater. The media list is replaced
+// in place, under the running list player: the item on screen plays to
+// its end and the list player moves on from its position in the old
+// list, so the new list is rotated to put the resume item there.
+func (b *libvlcBackend) UpdatePlaylist(items []media.Item) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+	return b.current(func(r *libvlcRun) error {
+		// Where the list player is, and what it is showing.
+		pos, path := -1, ""
+		if m := C.libvlc_media_player_get_media(r.player); m != nil {
+			if mrl := C.libvlc_media_get_mrl(m); mrl != nil {
+				path = mrlPath(C.GoString(mrl))
+				C.libvlc_free(unsafe.Pointer(mrl))
+			}
+			C.libvlc_media_list_lock(r.list)
+			pos = int(C.libvlc_media_list_index_of_item(r.list, m))
+			C.libvlc_media_list_unlock(r.list)
+			if pos < 0 && m == r.swapped {
+				pos = r.swapPos
+			}
+			if r.swapped != nil {
+				C.libvlc_media_release(r.swapped)
+			}
+			r.swapped, r.swapPos = m, pos // keeps get_media's reference
+		}
+
+		start := 0
+		if path != "" {
+			start = resumeIndex(absItems(r.items), path, absItems(items))
+		}
+		medias := b.newMedias(rotate(items, start))
+		if len(medias) == 0 {
+			return fmt.Errorf("no playable items")
+		}
+
+		// The list player goes to pos+1, or back to 0 past the end.
+		next := 0
+		if pos >= 0 && pos+1 < len(medias) {
+			next = pos + 1
+		}
+		C.libvlc_media_list_lock(r.list)
+		for C.libvlc_media_list_count(r.list) > 0 {
+			C.libvlc_media_list_remove_index(r.list, 0)
+		}
+		for i := range medias {
+			m := medias[(i-next+len(medias))%len(medias)]
+			C.libvlc_media_list_add_media(r.list, m)
+		}
+		C.libvlc_media_list_unlock(r.list)
+		for _, m := range medias {
+			C.libvlc_media_release(m)
+		}
+		r.items = items
+		r.n = len(medias)
+		r.tracker.setItems(items)
+
+		log.Printf("[libvlc:%s] playlist queued for next item (%d items, resuming at #%d)", b.zone.ID, len(items), start+1)
+		return nil
+	})
+}
+
+// Pause implements Pauser.
+func (b *libvlcBackend) Pause() error {
+	return b.current(func(r *libvlcRun) error {
+		C.libvlc_media_list_player_set_pause(r.lp, 1)
+		return nil
+	})
+}
+
+// Resume implements Pauser.
+func (b *libvlcBackend) Resume() error {
+	return b.current(func(r *libvlcRun) error {
+		C.libvlc_media_list_player_set_pause(r.lp, 0)
+		return nil
+	})
+}
+
+// SetVolume implements VolumeSetter. libVLC volume is already a
+// percentage (100 = 0 dB).
+func (b *libvlcBackend) SetVolume(percent int) error {
+	return b.current(func(r *libvlcRun) error {
+		if C.libvlc_audio_set_volume(r.player, C.int(percent)) != 0 {
+			return fmt.Errorf("libvlc volume: %s", libvlcErr())
+		}
+		return nil
+	})
+}
+
+var libvlcStates = map[C.libvlc_state_t]string{
+	C.libvlc_Opening:   "opening",
+	C.libvlc_Buffering: "buffering",
+	C.libvlc_Playing:   "playing",
+	C.libvlc_Paused:    "paused",
+	C.libvlc_Stopped:   "stopped",
+	C.libvlc_Ended:     "ended",
+	C.libvlc_Error:     "error",
+}
+
+// NowPlaying implements Monitor.
+func (b *libvlcBackend) NowPlaying() (NowPlaying, bool) {
+	var np NowPlaying
+	err := b.current(func(r *libvlcRun) error {
+		m := C.libvlc_media_player_get_media(r.player)
+		if m == nil {
+			return fmt.Errorf("no media")
+		}
+		defer C.libvlc_media_release(m)
+		mrl := C.libvlc_media_get_mrl(m)
+		if mrl == nil {
+			return fmt.Errorf("no mrl")
+		}
+		np.File = mrlPath(C.GoString(mrl))
+		C.libvlc_free(unsafe.Pointer(mrl))
+
+		np.Position = float64(C.libvlc_media_player_get_time(r.player)) / 1000
+		if l := C.libvlc_media_player_get_length(r.player); l > 0 {
+			np.Duration = float64(l) / 1000
+		}
+		np.State = libvlcStates[C.libvlc_media_player_get_state(r.player)]
+		if v := C.libvlc_audio_get_volume(r.player); v >= 0 {
+			np.Volume = int(v)
+		}
+		return nil
+	})
+	return np, err == nil
+}
+
+// SetEventFunc implements EventSource.
+func (b *libvlcBackend) SetEventFunc(fn EventFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit = fn
+	if b.run != nil {
+		b.run.tracker.setEmit(fn)
+	}
+}
+
+func (b *libvlcBackend) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.run != nil {
+		select {
+		case <-b.run.stop:
+		default:
+			close(b.run.stop)
+		}
+	}
+}
+
+func (b *libvlcBackend) Release() {
+	b.Stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Players hold their own instance reference, so a PlayAll still
+	// tearing down is unaffected.
+	if b.inst != nil {
+		C.libvlc_release(b.inst)
+		b.inst = nil
+	}
+	if b.kms {
+		releaseKMS(b.zone.ID)
+		b.kms = false
+	}
+	log.Printf("[libvlc:%s] released", b.zone.ID)
+}
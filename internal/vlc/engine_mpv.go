@@ -0,0 +1,542 @@
+// mpv backend using a subprocess per zone, selected with --backend mpv
+// or "backend": "mpv" on a template zone.
+//
+// This approach:
+//   - Per-zone window geometry via --geometry (or --fs)
+//   - Gapless looping via --loop-playlist=inf and --prefetch-playlist
+//   - Hardware decoding via --hwdec=auto
+//   - Renders through DRM/KMS (--gpu-context=drm) without a display server
+//   - Item-level control and state via mpv's JSON IPC on a per-zone
+//     unix socket (not available on Windows, where mpv uses named pipes)
+package vlc
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+)
+
+const mpvConnectTimeout = 10 * time.Second
+
+type mpvBackend struct {
+	mpvPath string
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	zone    template.Zone
+	screenW int
+	screenH int
+
+	ipc     *mpvClient   // nil until mpv's IPC socket is connected
+	tracker *playTracker // item boundaries of the current PlayAll
+	emit    EventFunc
+	items   []media.Item // playlist mpv is working through
+
+	// After a hot swap the entry that was playing stays in mpv's
+	// playlist until it finishes; it is removed once mpv moves on.
+	retireID int
+}
+
+func newMPVBackend() (Backend, error) {
+	return &mpvBackend{}, nil
+}
+
+func (b *mpvBackend) Init(zone template.Zone, screenW, screenH int) error {
+	path, err := findMPV()
+	if err != nil {
+		return err
+	}
+	b.mpvPath = path
+	b.zone = zone
+	b.screenW = screenW
+	b.screenH = screenH
+
+	log.Printf("[mpv:%s] using %s (screen %dx%d, fullzone=%v)", zone.ID, path, screenW, screenH, isFullZone(zone, screenW, screenH))
+	if zone.Transparent {
+		log.Printf("[mpv:%s] warning: transparent zones are not supported by this backend", zone.ID)
+	}
+	return nil
+}
+
+func (b *mpvBackend) PlayAll(items []media.Item, stopCh <-chan struct{}) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+	log.Printf("[mpv:%s] playing %d items (looped)", b.zone.ID, len(items))
+
+	var sock string
+	if runtime.GOOS != "windows" {
+		sock = zoneSocketPath(b.zone.ID, "-mpv")
+		os.Remove(sock)
+	}
+	kms := runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+	if kms && !isFullZone(b.zone, b.screenW, b.screenH) {
+		log.Printf("[mpv:%s] warning: DRM/KMS output is full screen, zone geometry is not applied", b.zone.ID)
+	}
+	args := mpvArgs(b.zone, b.screenW, b.screenH, items, sock, kms)
+	tracker := newPlayTracker(b.zone.ID, items)
+
+	b.mu.Lock()
+	tracker.setEmit(b.emit)
+	b.tracker = tracker
+	b.items = items
+	b.retireID = 0
+	b.cmd = exec.Command(b.mpvPath, args...)
+	b.cmd.Stdout = os.Stdout
+	b.cmd.Stderr = os.Stderr
+	cmd := b.cmd
+	b.mu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("mpv start failed: %w", err)
+	}
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- cmd.Wait()
+	}()
+
+	exited := make(chan struct{})
+	if sock != "" {
+		go b.attachIPC(sock, tracker, exited)
+	}
+
+	select {
+	case <-stopCh:
+		b.kill()
+	case <-doneCh:
+	}
+	close(exited)
+	tracker.finish(time.Now(), false)
+	if sock != "" {
+		os.Remove(sock)
+	}
+	return nil
+}
+
+// mpvArgs returns the mpv command line for a zone. An empty sock
+// disables the IPC server.
+func mpvArgs(zone template.Zone, screenW, screenH int, items []media.Item, sock string, kms bool) []string {
+	args := []string{
+		// === KIOSK: nothing visible except video ===
+		"--no-border",
+		"--ontop",
+		"--no-osc",
+		"--osd-level=0",
+		"--no-osd-bar",
+		"--sid=no",
+		"--cursor-autohide=always",
+		"--no-input-default-bindings",
+		"--force-window=yes",
+
+		// === PLAYBACK ===
+		"--loop-playlist=inf",
+		"--prefetch-playlist=yes",
+		"--gapless-audio=yes",
+		"--keep-open=no",
+		"--image-display-duration=" + strconv.Itoa(media.DefaultImageDuration),
+		"--volume-max=200",
+
+		// === HARDWARE DECODING ===
+		"--hwdec=auto",
+
+		// === BUFFERING ===
+		"--cache=yes",
+		"--demuxer-readahead-secs=8",
+
+		"--really-quiet",
+	}
+	if sock != "" {
+		args = append(args, "--input-ipc-server="+sock)
+	}
+	if zone.Rotate != 0 {
+		args = append(args, "--video-rotate="+strconv.Itoa(zone.Rotate))
+	}
+
+	// Output: straight to the display controller without a display
+	// server, otherwise fullscreen OR exact window placement.
+	switch {
+	case kms:
+		args = append(args, "--vo=gpu", "--gpu-context=drm")
+	case isFullZone(zone, screenW, screenH):
+		args = append(args, "--fs")
+	default:
+		r := zone.Rect(screenW, screenH)
+		args = append(args,
+			fmt.Sprintf("--geometry=%dx%d+%d+%d", r.W, r.H, r.X, r.Y),
+			"--no-keepaspect-window",
+		)
+	}
+
+	// Per-file options are wrapped in --{ ... --} groups.
+	for _, it := range absItems(items) {
+		opts := mpvItemOptions(it)
+		if len(opts) == 0 {
+			args = append(args, it.Path)
+			continue
+		}
+		args = append(args, "--{")
+		for _, k := range []string{"image-display-duration", "end", "aid"} {
+			if v, ok := opts[k]; ok {
+				args = append(args, "--"+k+"="+v)
+			}
+		}
+		args = append(args, it.Path, "--}")
+	}
+	return args
+}
+
+// mpvItemOptions returns mpv per-file options for an item's manifest
+// settings, the counterpart of itemOptions for VLC.
+func mpvItemOptions(it media.Item) map[string]string {
+	opts := make(map[string]string)
+	if it.Duration > 0 {
+		secs := strconv.FormatFloat(it.Duration.Seconds(), 'f', -1, 64)
+		if media.Detect(it.Path) == media.Image {
+			opts["image-display-duration"] = secs
+		} else {
+			opts["end"] = secs
+		}
+	}
+	if it.Mute {
+		opts["aid"] = "no"
+	}
+	return opts
+}
+
+// attachIPC connects to the IPC socket of a freshly started mpv and
+// turns its events into play events until exited is closed. Without a
+// connection the zone still plays; only item-level control and
+// reporting are lost.
+func (b *mpvBackend) attachIPC(addr string, tracker *playTracker, exited <-chan struct{}) {
+	c, err := dialMPV(addr, mpvConnectTimeout)
+	if err != nil {
+		select {
+		case <-exited:
+		default:
+			log.Printf("[mpv:%s] ipc unavailable: %v", b.zone.ID, err)
+		}
+		return
+	}
+	defer c.close()
+
+	// Events are handled here rather than on the read loop, since
+	// handling them needs further commands.
+	events := make(chan mpvMessage, 64)
+	c.onEvent = func(msg mpvMessage) {
+		select {
+		case events <- msg:
+		default:
+		}
+	}
+	go c.readLoop()
+	if err := c.observe(); err != nil {
+		log.Printf("[mpv:%s] ipc: %v", b.zone.ID, err)
+		return
+	}
+
+	// Pick up the file that started before we connected.
+	skipStart := 0
+	if entries, err := c.playlist(); err == nil {
+		for _, e := range entries {
+			if e.Current {
+				tracker.advance(e.Filename, time.Now())
+				skipStart = e.ID
+			}
+		}
+	}
+
+	b.mu.Lock()
+	b.ipc = c
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		if b.ipc == c {
+			b.ipc = nil
+		}
+		b.mu.Unlock()
+	}()
+	log.Printf("[mpv:%s] ipc connected (%s)", b.zone.ID, addr)
+
+	for {
+		select {
+		case <-exited:
+			return
+		case msg := <-events:
+			switch msg.Event {
+			case "end-file":
+				skipStart = 0
+				if msg.Reason != "eof" {
+					tracker.interrupt()
+				}
+			case "start-file":
+				if msg.EntryID == skipStart {
+					skipStart = 0
+					continue
+				}
+				b.startFile(c, tracker, msg.EntryID, time.Now())
+			}
+		}
+	}
+}
+
+// startFile records the start of playlist entry id and retires the
+// pre-swap entry once mpv has moved past it.
+func (b *mpvBackend) startFile(c *mpvClient, tracker *playTracker, id int, now time.Time) {
+	entries, err := c.playlist()
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			tracker.advance(e.Filename, now)
+		}
+	}
+
+	b.mu.Lock()
+	retire := b.retireID
+	if retire == 0 || retire == id {
+		b.mu.Unlock()
+		return
+	}
+	b.retireID = 0
+	b.mu.Unlock()
+
+	for i, e := range entries {
+		if e.ID == retire {
+			c.command([]any{"playlist-remove", i})
+		}
+	}
+}
+
+// remote returns the IPC connection and tracker of the running player.
+func (b *mpvBackend) remote() (*mpvClient, *playTracker, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ipc == nil {
+		return nil, nil, fmt.Errorf("%w: mpv ipc not connected", ErrUnsupported)
+	}
+	return b.ipc, b.tracker, nil
+}
+
+// Next implements Skipper.
+func (b *mpvBackend) Next() error {
+	c, tracker, err := b.remote()
+	if err != nil {
+		return err
+	}
+	tracker.interrupt()
+	_, err = c.command([]any{"playlist-next", "force"})
+	return err
+}
+
+// Pause implements Pauser.
+func (b *mpvBackend) Pause() error {
+	return b.setProperty("pause", true)
+}
+
+// Resume implements Pauser.
+func (b *mpvBackend) Resume() error {
+	return b.setProperty("pause", false)
+}
+
+// SetVolume implements VolumeSetter. mpv's volume is a percentage;
+// --volume-max=200 allows the full range.
+func (b *mpvBackend) SetVolume(percent int) error {
+	return b.setProperty("volume", percent)
+}
+
+func (b *mpvBackend) setProperty(name string, value any) error {
+	c, _, err := b.remote()
+	if err != nil {
+		return err
+	}
+	_, err = c.command([]any{"set_property", name, value})
+	return err
+}
+
+// NowPlaying implements Monitor.
+func (b *mpvBackend) NowPlaying() (NowPlaying, bool) {
+	c, _, err := b.remote()
+	if err != nil {
+		return NowPlaying{}, false
+	}
+	st := c.state()
+	if st.path == "" {
+		return NowPlaying{}, false
+	}
+	state := "playing"
+	if st.paused {
+		state = "paused"
+	}
+	return NowPlaying{
+		File:     st.path,
+		Position: st.pos,
+		Duration: st.duration,
+		State:    state,
+		Volume:   int(math.Round(st.volume)),
+	}, true
+}
+
+// UpdatePlaylist implements PlaylistUpdater. playlist-clear removes
+// every entry except the one on screen and the new list is appended
+// behind it, rotated to the resume position. If nothing is playing the
+// list is replaced immediately instead, still without restarting mpv.
+func (b *mpvBackend) UpdatePlaylist(items []media.Item) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+	c, tracker, err := b.remote()
+	if err != nil {
+		return err
+	}
+
+	st := c.state()
+	b.mu.Lock()
+	old := b.items
+	b.items = items
+	b.mu.Unlock()
+
+	current := 0
+	entries, err := c.playlist()
+	for _, e := range entries {
+		if e.Current {
+			current = e.ID
+		}
+	}
+
+	start := 0
+	if st.path != "" {
+		start = resumeIndex(absItems(old), st.path, absItems(items))
+	}
+	queue := absItems(rotate(items, start))
+
+	tracker.setItems(items)
+	if st.path == "" || current == 0 {
+		if err == nil {
+			err = fmt.Errorf("no current item")
+		}
+		log.Printf("[mpv:%s] hot swap unavailable (%v), replacing playlist now", b.zone.ID, err)
+		tracker.interrupt()
+		for i, it := range queue {
+			flags := "append"
+			if i == 0 {
+				flags = "replace"
+			}
+			if _, err := c.command(mpvLoadfile(it, flags)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := c.command([]any{"playlist-clear"}); err != nil {
+		return err
+	}
+	for _, it := range queue {
+		if _, err := c.command(mpvLoadfile(it, "append")); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	b.retireID = current
+	b.mu.Unlock()
+
+	log.Printf("[mpv:%s] playlist queued for next item (%d items, resuming at #%d)", b.zone.ID, len(items), start+1)
+	return nil
+}
+
+// mpvLoadfile returns a loadfile command in named-argument form, which
+// keeps per-file options unambiguous across mpv versions.
+func mpvLoadfile(it media.Item, flags string) map[string]any {
+	cmd := map[string]any{"name": "loadfile", "url": it.Path, "flags": flags}
+	if opts := mpvItemOptions(it); len(opts) > 0 {
+		cmd["options"] = opts
+	}
+	return cmd
+}
+
+// Windows implements Stacker.
+func (b *mpvBackend) Windows() ([]string, error) {
+	b.mu.Lock()
+	cmd := b.cmd
+	b.mu.Unlock()
+	if cmd == nil || cmd.Process == nil {
+		return nil, fmt.Errorf("%w: not playing", ErrUnsupported)
+	}
+	return x11Windows(cmd.Process.Pid, b.zone)
+}
+
+// Raise implements Stacker.
+func (b *mpvBackend) Raise(windows []string) error {
+	return x11Raise(windows)
+}
+
+// SetEventFunc implements EventSource.
+func (b *mpvBackend) SetEventFunc(fn EventFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit = fn
+	if b.tracker != nil {
+		b.tracker.setEmit(fn)
+	}
+}
+
+func (b *mpvBackend) Stop() {
+	b.kill()
+}
+
+func (b *mpvBackend) Release() {
+	b.kill()
+	log.Printf("[mpv:%s] released", b.zone.ID)
+}
+
+func (b *mpvBackend) kill() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cmd != nil && b.cmd.Process != nil {
+		b.cmd.Process.Kill()
+		b.cmd = nil
+	}
+}
+
+func findMPV() (string, error) {
+	if path, err := exec.LookPath("mpv"); err == nil {
+		return path, nil
+	}
+
+	var candidates []string
+	switch runtime.GOOS {
+	case "windows":
+		candidates = []string{
+			`C:\Program Files\mpv\mpv.exe`,
+		}
+	case "darwin":
+		candidates = []string{
+			"/Applications/mpv.app/Contents/MacOS/mpv",
+			"/opt/homebrew/bin/mpv",
+		}
+	default:
+		candidates = []string{
+			"/usr/bin/mpv",
+			"/snap/bin/mpv",
+		}
+	}
+
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c, nil
+		}
+	}
+
+	return "", fmt.Errorf("mpv not found — install from https://mpv.io/installation/")
+}
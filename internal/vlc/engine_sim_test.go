@@ -0,0 +1,334 @@
+package vlc_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+	"player-native/internal/vlc"
+	"player-native/internal/vlc/vlctest"
+)
+
+// harness is an engine with one fake-backed zone ("main").
+type harness struct {
+	clock   *vlctest.Clock
+	engine  *vlc.Engine
+	backend *vlctest.Backend
+	errCh   <-chan error
+
+	mu     sync.Mutex
+	events []vlc.PlayEvent
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{clock: vlctest.NewClock(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))}
+	f := vlctest.NewFactory(h.clock)
+
+	e, err := vlc.NewEngine(template.Fullscreen("/p"), 1920, 1080,
+		vlc.WithBackendFactory(f.New), vlc.WithClock(h.clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.OnPlayEvent(func(ev vlc.PlayEvent) {
+		h.mu.Lock()
+		h.events = append(h.events, ev)
+		h.mu.Unlock()
+	})
+	h.engine = e
+	h.backend = f.Backend("main")
+	t.Cleanup(e.Release)
+	return h
+}
+
+func (h *harness) play() {
+	h.errCh = h.engine.Play()
+}
+
+// stop stops the engine and waits for the zone's run loop to exit.
+func (h *harness) stop(t *testing.T) {
+	t.Helper()
+	h.engine.Stop()
+	select {
+	case err := <-h.errCh:
+		if err != nil {
+			t.Fatalf("run loop returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run loop did not exit")
+	}
+}
+
+func (h *harness) state() vlc.ZoneStatus {
+	return h.engine.Status()[0]
+}
+
+func (h *harness) playEvents() []vlc.PlayEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]vlc.PlayEvent(nil), h.events...)
+}
+
+func paths(items []media.Item) string {
+	return strings.Join(media.Paths(items), ",")
+}
+
+// TestRestartOnPlaylistChange: a backend without hot-swap support is
+// stopped and replayed with the new list.
+func TestRestartOnPlaylistChange(t *testing.T) {
+	h := newHarness(t)
+	h.engine.SetPlaylist("main", []string{"a.mp4", "b.jpg"})
+	h.play()
+
+	if got := paths(h.backend.WaitPlayAll()); got != "a.mp4,b.jpg" {
+		t.Fatalf("first run played %s", got)
+	}
+	h.clock.BlockUntil(1)
+	h.clock.Advance(4 * time.Second)
+
+	h.engine.SetPlaylist("main", []string{"a.mp4", "b.jpg", "c.mp4"})
+	if got := paths(h.backend.WaitPlayAll()); got != "a.mp4,b.jpg,c.mp4" {
+		t.Fatalf("restart played %s", got)
+	}
+	h.stop(t)
+
+	evs := h.playEvents()
+	if len(evs) < 1 || evs[0].Item.Path != "a.mp4" || evs[0].Completed || evs[0].End.Sub(evs[0].Start) != 4*time.Second {
+		t.Fatalf("expected a.mp4 interrupted after 4s, got %+v", evs)
+	}
+}
+
+// TestStopDuringPlayAll: Stop ends a blocking PlayAll and the zone
+// reports stopped.
+func TestStopDuringPlayAll(t *testing.T) {
+	h := newHarness(t)
+	h.engine.SetItems("main", []media.Item{
+		{Path: "a.mp4", Duration: 15 * time.Second},
+		{Path: "b.jpg"},
+	})
+	h.play()
+	h.backend.WaitPlayAll()
+
+	h.clock.BlockUntil(1)
+	h.clock.Advance(15 * time.Second) // a.mp4 completes, b.jpg starts
+	h.clock.BlockUntil(1)
+	if st := h.state(); st.State != vlc.StatePlaying {
+		t.Fatalf("expected playing, got %s", st.State)
+	}
+
+	h.stop(t)
+	if st := h.state(); st.State != vlc.StateStopped {
+		t.Fatalf("expected stopped, got %s", st.State)
+	}
+
+	evs := h.playEvents()
+	if len(evs) != 2 || !evs[0].Completed || evs[1].Item.Path != "b.jpg" || evs[1].Completed {
+		t.Fatalf("expected a.mp4 completed then b.jpg interrupted, got %+v", evs)
+	}
+	if h.clock.Waiters() != 0 {
+		t.Errorf("expected no pending timers after stop, got %d", h.clock.Waiters())
+	}
+}
+
+// TestEmptyPlaylistWaits: a zone without content polls on the clock and
+// never calls PlayAll until items arrive.
+func TestEmptyPlaylistWaits(t *testing.T) {
+	h := newHarness(t)
+	h.play()
+
+	for i := 0; i < 3; i++ {
+		h.clock.BlockUntil(1)
+		h.clock.Advance(2 * time.Second)
+	}
+	h.clock.BlockUntil(1)
+	for _, c := range h.backend.Calls() {
+		if strings.HasPrefix(c, "PlayAll") {
+			t.Fatalf("unexpected %q while empty", c)
+		}
+	}
+	if st := h.state(); st.State != vlc.StateIdle {
+		t.Fatalf("expected idle, got %s", st.State)
+	}
+
+	// New content is picked up on the next poll.
+	h.engine.SetPlaylist("main", []string{"a.mp4"})
+	h.clock.Advance(2 * time.Second)
+	if got := paths(h.backend.WaitPlayAll()); got != "a.mp4" {
+		t.Fatalf("played %s", got)
+	}
+	h.stop(t)
+}
+
+// TestErrorRestart: a failing PlayAll is retried after a short backoff
+// and the error is visible in the meantime; LastError keeps it after
+// the zone recovers.
+func TestErrorRestart(t *testing.T) {
+	h := newHarness(t)
+	h.backend.FailNext(errors.New("vlc crashed"))
+	h.engine.SetPlaylist("main", []string{"a.mp4"})
+	h.play()
+
+	h.backend.WaitPlayAll() // fails
+	h.clock.BlockUntil(1)   // parked in the backoff
+	st := h.state()
+	if st.State != vlc.StateError || st.LastError != "vlc crashed" {
+		t.Fatalf("expected error state, got %+v", st)
+	}
+
+	h.clock.Advance(500 * time.Millisecond)
+	if got := paths(h.backend.WaitPlayAll()); got != "a.mp4" {
+		t.Fatalf("retry played %s", got)
+	}
+	h.clock.BlockUntil(1)
+	if st := h.state(); st.State != vlc.StatePlaying || st.PlaylistHash == "" {
+		t.Fatalf("expected playing after retry, got %+v", st)
+	}
+	le, ok := h.engine.LastError()
+	if !ok || le.ZoneID != "main" || le.Err.Error() != "vlc crashed" || !le.At.Equal(h.clock.Now().Add(-500*time.Millisecond)) {
+		t.Fatalf("unexpected last error %+v", le)
+	}
+	h.stop(t)
+
+	var plays int
+	for _, c := range h.backend.Calls() {
+		if strings.HasPrefix(c, "PlayAll") {
+			plays++
+		}
+	}
+	if plays != 2 {
+		t.Fatalf("expected 2 PlayAll calls, got %d: %v", plays, h.backend.Calls())
+	}
+}
+
+// TestRestackByZindex: when a zone starts, it and every zone above it
+// are raised bottom to top, so a restarted lower zone cannot cover an
+// overlay.
+func TestRestackByZindex(t *testing.T) {
+	clock := vlctest.NewClock(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+	f := vlctest.NewFactory(clock)
+	tmpl := &template.Template{Name: "logo-bug", Zones: []template.Zone{
+		{ID: "logo", X: 85, Y: 5, Width: 10, Height: 10, Zindex: 1, PlaylistDir: "/logo"},
+		{ID: "main", X: 0, Y: 0, Width: 100, Height: 100, PlaylistDir: "/main"},
+	}}
+	e, err := vlc.NewEngine(tmpl, 1920, 1080, vlc.WithBackendFactory(f.New), vlc.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Release()
+
+	e.SetPlaylist("main", []string{"a.mp4"})
+	errCh := e.Play()
+	f.Backend("main").WaitPlayAll()
+	waitRaises(t, f, "main,logo")
+
+	e.SetPlaylist("logo", []string{"logo.png"})
+	clock.BlockUntil(2) // main playing, logo polling for content
+	clock.Advance(2 * time.Second)
+	f.Backend("logo").WaitPlayAll()
+	waitRaises(t, f, "main,logo,logo")
+
+	e.Stop()
+	for range tmpl.Zones {
+		<-errCh
+	}
+}
+
+func waitRaises(t *testing.T, f *vlctest.Factory, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := strings.Join(f.Raises(), ",")
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("raises = %q, want %q", got, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestUpdateTemplate: a template update keeps unchanged zones playing,
+// releases removed ones and gives resized and new zones fresh players
+// that start once they have content.
+func TestUpdateTemplate(t *testing.T) {
+	clock := vlctest.NewClock(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+	f := vlctest.NewFactory(clock)
+	tmpl := &template.Template{Name: "v1", Zones: []template.Zone{
+		{ID: "main", Width: 75, Height: 100, PlaylistDir: "/main"},
+		{ID: "side", X: 75, Width: 25, Height: 100, PlaylistDir: "/side"},
+		{ID: "promo", X: 75, Y: 50, Width: 25, Height: 50, Zindex: 1, PlaylistDir: "/promo"},
+	}}
+	e, err := vlc.NewEngine(tmpl, 1920, 1080, vlc.WithBackendFactory(f.New), vlc.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Release()
+	for _, z := range tmpl.Zones {
+		e.SetPlaylist(z.ID, []string{z.ID + ".mp4"})
+	}
+	errCh := e.Play()
+	main, side, promo := f.Backend("main"), f.Backend("side"), f.Backend("promo")
+	for _, b := range []*vlctest.Backend{main, side, promo} {
+		b.WaitPlayAll()
+	}
+
+	c, err := e.UpdateTemplate(&template.Template{Name: "v2", Zones: []template.Zone{
+		{ID: "main", Width: 75, Height: 100, PlaylistDir: "/main-evening"},
+		{ID: "side", X: 75, Width: 25, Height: 90, PlaylistDir: "/side"},
+		{ID: "ticker", Y: 90, X: 75, Width: 25, Height: 10, PlaylistDir: "/ticker"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Added) != 1 || len(c.Removed) != 1 || len(c.Replaced) != 1 || len(c.Content) != 1 {
+		t.Fatalf("unexpected changes %+v", c)
+	}
+
+	// promo is gone, side has a new player, main kept its own.
+	if calls := strings.Join(promo.Calls(), ","); !strings.HasSuffix(calls, "Stop,Release") {
+		t.Errorf("removed zone calls: %s", calls)
+	}
+	if calls := strings.Join(side.Calls(), ","); !strings.HasSuffix(calls, "Release") {
+		t.Errorf("replaced zone calls: %s", calls)
+	}
+	for _, call := range main.Calls() {
+		if call == "Stop" || call == "Release" {
+			t.Fatalf("unchanged zone was stopped: %v", main.Calls())
+		}
+	}
+	if got := strings.Join(e.Zones(), ","); got != "main,side,ticker" || e.TemplateName() != "v2" {
+		t.Fatalf("zones = %s (%s)", got, e.TemplateName())
+	}
+	if st := e.Status()[0]; st.Zone.PlaylistDir != "/main-evening" || st.State != vlc.StatePlaying {
+		t.Errorf("main status = %+v", st)
+	}
+
+	// New players start once their feeds deliver content.
+	clock.BlockUntil(3) // main playing, side and ticker waiting
+	e.SetPlaylist("side", []string{"side.mp4"})
+	e.SetPlaylist("ticker", []string{"ticker.png"})
+	clock.Advance(2 * time.Second)
+	if got := paths(f.Backend("side").WaitPlayAll()); got != "side.mp4" {
+		t.Fatalf("new side player played %s", got)
+	}
+	f.Backend("ticker").WaitPlayAll()
+
+	if err := e.RemoveZone("nope"); !errors.Is(err, vlc.ErrZoneNotFound) {
+		t.Errorf("expected ErrZoneNotFound, got %v", err)
+	}
+	if err := e.AddZone(template.Zone{ID: "main", Width: 10, Height: 10}); err == nil {
+		t.Error("expected a duplicate zone error")
+	}
+
+	e.Stop()
+	for range e.Zones() {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
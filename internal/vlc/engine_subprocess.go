@@ -0,0 +1,9 @@
+//go:build !libvlc || !cgo
+
+package vlc
+
+// newBackend returns the subprocess backend. Build with -tags libvlc
+// (and CGO enabled) to play through libVLC in-process instead.
+func newBackend() (Backend, error) {
+	return newSubprocessBackend(), nil
+}
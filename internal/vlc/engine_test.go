@@ -0,0 +1,112 @@
+package vlc
+
+import (
+	"testing"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+)
+
+// TestResumeIndex checks where a hot-swapped playlist continues.
+func TestResumeIndex(t *testing.T) {
+	list := func(names ...string) []media.Item {
+		return media.ItemsFromPaths(names)
+	}
+	old := list("a", "b", "c", "d")
+
+	cases := []struct {
+		name    string
+		playing string
+		next    []media.Item
+		want    int
+	}{
+		{"insert after current", "b", list("a", "b", "x", "c", "d"), 2},
+		{"current is last", "d", list("a", "b", "c", "d"), 0},
+		{"current removed", "b", list("a", "c", "d"), 1},
+		{"current and successor removed", "b", list("a", "d"), 1},
+		{"successors wrap", "d", list("c", "b"), 1},
+		{"nothing in common", "b", list("x", "y"), 0},
+		{"unknown current", "zz", list("a", "b"), 0},
+	}
+	for _, c := range cases {
+		if got := resumeIndex(old, c.playing, c.next); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	if got := media.Paths(rotate(list("a", "b", "c"), 1)); got[0] != "b" || got[2] != "a" {
+		t.Errorf("rotate: got %v", got)
+	}
+}
+
+// kmsBackend stands in for the libVLC backend: it claims the DRM/KMS
+// output like libvlcBackend.Init does.
+type kmsBackend struct {
+	zone string
+	kms  bool
+}
+
+func (b *kmsBackend) Init(zone template.Zone, screenW, screenH int) error {
+	kms, err := claimKMS(zone, screenW, screenH)
+	if err != nil {
+		return err
+	}
+	b.zone, b.kms = zone.ID, kms
+	return nil
+}
+
+func (b *kmsBackend) PlayAll([]media.Item, <-chan struct{}) error { return nil }
+func (b *kmsBackend) Stop()                                       {}
+
+func (b *kmsBackend) Release() {
+	if b.kms {
+		releaseKMS(b.zone)
+	}
+}
+
+// fallbackBackend stands in for the VLC subprocess.
+type fallbackBackend struct{}
+
+func (fallbackBackend) Init(template.Zone, int, int) error          { return nil }
+func (fallbackBackend) PlayAll([]media.Item, <-chan struct{}) error { return nil }
+func (fallbackBackend) Stop()                                       {}
+func (fallbackBackend) Release()                                    {}
+
+// TestKMSFallback checks that a multi-zone template starts without a
+// display server: the full-screen zone gets DRM/KMS and the others fall
+// back instead of failing the engine.
+func TestKMSFallback(t *testing.T) {
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	tmpl := &template.Template{Name: "split", Zones: []template.Zone{
+		{ID: "main", Width: 100, Height: 100},
+		{ID: "logo", X: 85, Y: 5, Width: 10, Height: 10, Zindex: 1},
+		{ID: "overlay", Width: 100, Height: 100, Zindex: 2},
+	}}
+	var fallbacks []string
+	e, err := NewEngine(tmpl, 1920, 1080,
+		WithBackendFactory(func(template.Zone) (Backend, error) { return &kmsBackend{}, nil }),
+		WithFallback(func(z template.Zone) (Backend, error) {
+			fallbacks = append(fallbacks, z.ID)
+			return fallbackBackend{}, nil
+		}))
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if len(e.zones) != 3 {
+		t.Fatalf("got %d zones, want 3", len(e.zones))
+	}
+	if b, ok := e.zones[0].backend.(*kmsBackend); !ok || !b.kms {
+		t.Errorf("main: got backend %#v, want DRM/KMS", e.zones[0].backend)
+	}
+	if len(fallbacks) != 2 || fallbacks[0] != "logo" || fallbacks[1] != "overlay" {
+		t.Errorf("fallback zones: got %v, want [logo overlay]", fallbacks)
+	}
+
+	e.Release()
+	if kms, err := claimKMS(tmpl.Zones[2], 1920, 1080); err != nil || !kms {
+		t.Errorf("after Release: claimKMS = %v, %v; want the output free", kms, err)
+	}
+	releaseKMS(tmpl.Zones[2].ID)
+}
@@ -7,21 +7,33 @@
 //   - Hardware acceleration via --avcodec-hw=any
 //   - No CGO needed (cross-compilable)
 //   - --no-video-deco works because Qt interface is loaded in CLI VLC
+//   - Item-level control and state via the RC interface on a per-zone
+//     unix socket (TCP on localhost on Windows)
 package vlc
 
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"player-native/internal/media"
 	"player-native/internal/template"
 )
 
+// RC connection tuning.
+const (
+	rcConnectTimeout = 10 * time.Second
+	rcPollInterval   = 500 * time.Millisecond
+)
+
 type vlcBackend struct {
 	mu         sync.Mutex
 	vlcPath    string
@@ -30,10 +42,20 @@ type vlcBackend struct {
 	screenW    int
 	screenH    int
 	isFullZone bool
+
+	rc      *rcClient    // nil until VLC's RC interface is connected
+	tracker *playTracker // item boundaries of the current PlayAll
+	emit    EventFunc
+	items   []media.Item // playlist VLC is working through
+
+	// After a hot swap the item that was playing stays in VLC's
+	// playlist until it finishes; it is deleted once the input moves on.
+	retireID    int
+	retireInput string
 }
 
-func newBackend() (Backend, error) {
-	return &vlcBackend{}, nil
+func newSubprocessBackend() Backend {
+	return &vlcBackend{}
 }
 
 func (b *vlcBackend) Init(zone template.Zone, screenW, screenH int) error {
@@ -45,20 +67,23 @@ func (b *vlcBackend) Init(zone template.Zone, screenW, screenH int) error {
 	b.zone = zone
 	b.screenW = screenW
 	b.screenH = screenH
-	b.isFullZone = zone.X == 0 && zone.Y == 0 && zone.Width >= 100 && zone.Height >= 100
+	b.isFullZone = isFullZone(zone, screenW, screenH)
 
 	log.Printf("[vlc:%s] using %s (screen %dx%d, fullzone=%v)", zone.ID, path, screenW, screenH, b.isFullZone)
+	if zone.Transparent {
+		log.Printf("[vlc:%s] warning: transparent zones are not supported by this backend", zone.ID)
+	}
 	return nil
 }
 
-func (b *vlcBackend) PlayAll(files []string, stopCh <-chan struct{}) error {
-	if len(files) == 0 {
+func (b *vlcBackend) PlayAll(items []media.Item, stopCh <-chan struct{}) error {
+	if len(items) == 0 {
 		return fmt.Errorf("empty playlist")
 	}
 
 	var videos, images int
-	for _, f := range files {
-		switch media.Detect(f) {
+	for _, it := range items {
+		switch media.Detect(it.Path) {
 		case media.Video:
 			videos++
 		case media.Image:
@@ -67,9 +92,18 @@ func (b *vlcBackend) PlayAll(files []string, stopCh <-chan struct{}) error {
 	}
 	log.Printf("[vlc:%s] playing %d videos + %d images (looped)", b.zone.ID, videos, images)
 
-	args := b.buildArgs(files)
+	network, addr, rcArgs, err := b.rcEndpoint()
+	if err != nil {
+		return err
+	}
+	args := append(b.buildArgs(items), rcArgs...)
+	tracker := newPlayTracker(b.zone.ID, items)
 
 	b.mu.Lock()
+	tracker.setEmit(b.emit)
+	b.tracker = tracker
+	b.items = items
+	b.retireID = 0
 	b.cmd = exec.Command(b.vlcPath, args...)
 	b.cmd.Stdout = os.Stdout
 	b.cmd.Stderr = os.Stderr
@@ -97,16 +131,296 @@ func (b *vlcBackend) PlayAll(files []string, stopCh <-chan struct{}) error {
 		doneCh <- cmd.Wait()
 	}()
 
+	exited := make(chan struct{})
+	go b.attachRC(network, addr, tracker, exited)
+
 	select {
 	case <-stopCh:
 		b.kill()
-		return nil
 	case <-doneCh:
+	}
+	close(exited)
+	tracker.finish(time.Now(), false)
+	if network == "unix" {
+		os.Remove(addr)
+	}
+	return nil
+}
+
+// rcEndpoint picks the per-zone RC address and the VLC arguments that
+// enable it. Unix sockets are used where available so no port is
+// exposed; Windows VLC only supports TCP.
+func (b *vlcBackend) rcEndpoint() (network, addr string, args []string, err error) {
+	if runtime.GOOS == "windows" {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", "", nil, fmt.Errorf("rc port: %w", err)
+		}
+		addr = l.Addr().String()
+		l.Close()
+		return "tcp", addr, []string{"--extraintf=rc", "--rc-host=" + addr, "--rc-quiet"}, nil
+	}
+
+	addr = zoneSocketPath(b.zone.ID, "")
+	os.Remove(addr)
+	return "unix", addr, []string{"--extraintf=rc", "--rc-unix=" + addr}, nil
+}
+
+// zoneSocketPath returns the control socket path for a zone's player
+// process, $TMPDIR/n-compasstv-<pid>-<zone><suffix>.sock, with the zone
+// ID reduced to safe characters.
+func zoneSocketPath(zoneID, suffix string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, zoneID)
+	if len(id) > 32 {
+		id = id[:32]
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("n-compasstv-%d-%s%s.sock", os.Getpid(), id, suffix))
+}
+
+// attachRC connects to the RC interface of a freshly started VLC and
+// polls it until exited is closed. Without a connection the zone still
+// plays; only item-level control and reporting are lost.
+func (b *vlcBackend) attachRC(network, addr string, tracker *playTracker, exited <-chan struct{}) {
+	rc, err := dialRC(network, addr, rcConnectTimeout)
+	if err != nil {
+		select {
+		case <-exited:
+		default:
+			log.Printf("[vlc:%s] rc interface unavailable: %v", b.zone.ID, err)
+		}
+		return
+	}
+	defer rc.close()
+
+	rc.onChange = func(st rcState) {
+		tracker.observe(st, time.Now())
+		b.retireFinished(rc, st)
+	}
+	go rc.readLoop()
+
+	b.mu.Lock()
+	b.rc = rc
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		if b.rc == rc {
+			b.rc = nil
+		}
+		b.mu.Unlock()
+	}()
+	log.Printf("[vlc:%s] rc interface connected (%s)", b.zone.ID, addr)
+
+	ticker := time.NewTicker(rcPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := rc.poll(); err != nil {
+			return
+		}
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// remote returns the RC connection and tracker of the running player.
+func (b *vlcBackend) remote() (*rcClient, *playTracker, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rc == nil {
+		return nil, nil, fmt.Errorf("%w: rc interface not connected", ErrUnsupported)
+	}
+	return b.rc, b.tracker, nil
+}
+
+// Next implements Skipper.
+func (b *vlcBackend) Next() error {
+	rc, tracker, err := b.remote()
+	if err != nil {
+		return err
+	}
+	tracker.interrupt()
+	return rc.send("next")
+}
+
+// Pause implements Pauser. VLC's "pause" toggles, so it is only sent
+// while playing.
+func (b *vlcBackend) Pause() error {
+	rc, _, err := b.remote()
+	if err != nil {
+		return err
+	}
+	if rc.state().state == "paused" {
 		return nil
 	}
+	return rc.send("pause")
+}
+
+// Resume implements Pauser.
+func (b *vlcBackend) Resume() error {
+	rc, _, err := b.remote()
+	if err != nil {
+		return err
+	}
+	return rc.send("play")
+}
+
+// SetVolume implements VolumeSetter. VLC's scale is 0..512 with 256
+// meaning 100%.
+func (b *vlcBackend) SetVolume(percent int) error {
+	rc, _, err := b.remote()
+	if err != nil {
+		return err
+	}
+	return rc.send("volume " + strconv.Itoa(percent*256/100))
+}
+
+// NowPlaying implements Monitor.
+func (b *vlcBackend) NowPlaying() (NowPlaying, bool) {
+	rc, _, err := b.remote()
+	if err != nil {
+		return NowPlaying{}, false
+	}
+	st := rc.state()
+	if st.input == "" {
+		return NowPlaying{}, false
+	}
+	return NowPlaying{
+		File:     mrlPath(st.input),
+		Position: float64(st.time),
+		Duration: float64(st.length),
+		State:    st.state,
+		Volume:   (st.volume*100 + 128) / 256,
+	}, true
 }
 
-func (b *vlcBackend) buildArgs(files []string) []string {
+// UpdatePlaylist implements PlaylistUpdater. Every queued entry except
+// the one on screen is deleted and the new list is enqueued behind it,
+// rotated to the resume position, so VLC moves straight on to the right
+// item when the current one ends. If VLC's playlist cannot be read the
+// list is replaced immediately instead, still without restarting VLC.
+func (b *vlcBackend) UpdatePlaylist(items []media.Item) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+	rc, tracker, err := b.remote()
+	if err != nil {
+		return err
+	}
+
+	st := rc.state()
+	b.mu.Lock()
+	old := b.items
+	b.items = items
+	b.mu.Unlock()
+
+	var current *rcEntry
+	entries, err := rc.playlist(2 * time.Second)
+	for i := range entries {
+		if entries[i].current {
+			current = &entries[i]
+		}
+	}
+
+	start := 0
+	if st.input != "" {
+		start = resumeIndex(absItems(old), mrlPath(st.input), absItems(items))
+	}
+
+	tracker.setItems(items)
+	if st.input == "" || current == nil {
+		if err == nil {
+			err = fmt.Errorf("no current item")
+		}
+		log.Printf("[vlc:%s] hot swap unavailable (%v), replacing playlist now", b.zone.ID, err)
+		tracker.interrupt()
+		cmds := []string{"clear"}
+		for i, it := range rotate(items, start) {
+			cmds = append(cmds, rcEnqueue(it, i == 0))
+		}
+		return rc.send(cmds...)
+	}
+
+	var cmds []string
+	for _, e := range entries {
+		if e.id != current.id {
+			cmds = append(cmds, "delete "+strconv.Itoa(e.id))
+		}
+	}
+	for _, it := range rotate(items, start) {
+		cmds = append(cmds, rcEnqueue(it, false))
+	}
+
+	b.mu.Lock()
+	b.retireID = current.id
+	b.retireInput = st.input
+	b.mu.Unlock()
+
+	log.Printf("[vlc:%s] playlist queued for next item (%d items, resuming at #%d)", b.zone.ID, len(items), start+1)
+	return rc.send(cmds...)
+}
+
+// retireFinished deletes the pre-swap item once VLC has moved past it,
+// so it does not come round again when the playlist loops.
+func (b *vlcBackend) retireFinished(rc *rcClient, st rcState) {
+	b.mu.Lock()
+	id := b.retireID
+	if id == 0 || st.input == "" || st.input == b.retireInput {
+		b.mu.Unlock()
+		return
+	}
+	b.retireID = 0
+	b.mu.Unlock()
+
+	// Sent from a new goroutine: this runs on the RC read loop.
+	go rc.send("delete " + strconv.Itoa(id))
+}
+
+// absItems returns items with absolute paths, matching what VLC reports.
+func absItems(items []media.Item) []media.Item {
+	out := make([]media.Item, len(items))
+	for i, it := range items {
+		out[i] = it
+		if p, err := filepath.Abs(it.Path); err == nil {
+			out[i].Path = p
+		}
+	}
+	return out
+}
+
+// Windows implements Stacker.
+func (b *vlcBackend) Windows() ([]string, error) {
+	b.mu.Lock()
+	cmd := b.cmd
+	b.mu.Unlock()
+	if cmd == nil || cmd.Process == nil {
+		return nil, fmt.Errorf("%w: not playing", ErrUnsupported)
+	}
+	return x11Windows(cmd.Process.Pid, b.zone)
+}
+
+// Raise implements Stacker.
+func (b *vlcBackend) Raise(windows []string) error {
+	return x11Raise(windows)
+}
+
+// SetEventFunc implements EventSource.
+func (b *vlcBackend) SetEventFunc(fn EventFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit = fn
+	if b.tracker != nil {
+		b.tracker.setEmit(fn)
+	}
+}
+
+func (b *vlcBackend) buildArgs(items []media.Item) []string {
 	args := []string{
 		// === KIOSK: nothing visible except video ===
 		"--no-video-deco",        // No window title bar or borders
@@ -152,27 +466,64 @@ func (b *vlcBackend) buildArgs(files []string) []string {
 		args = append(args, "--aout=alsa")
 	}
 
-	// Zone positioning: fullscreen OR exact window placement.
-	if b.isFullZone {
-		args = append(args, "--fullscreen")
-	} else {
-		pixelX := b.zone.X * b.screenW / 100
-		pixelY := b.zone.Y * b.screenH / 100
-		pixelW := b.zone.Width * b.screenW / 100
-		pixelH := b.zone.Height * b.screenH / 100
-
-		args = append(args,
-			"--width="+strconv.Itoa(pixelW),
-			"--height="+strconv.Itoa(pixelH),
-			"--video-x="+strconv.Itoa(pixelX),
-			"--video-y="+strconv.Itoa(pixelY),
-		)
-	}
+	args = append(args, rotateArgs(b.zone)...)
+	args = append(args, windowArgs(b.zone, b.screenW, b.screenH)...)
 
-	args = append(args, files...)
+	for _, it := range items {
+		args = append(args, it.Path)
+		args = append(args, itemOptions(it)...)
+	}
 	return args
 }
 
+// isFullZone reports whether zone covers the whole screen.
+func isFullZone(zone template.Zone, screenW, screenH int) bool {
+	r := zone.Rect(screenW, screenH)
+	return r.X <= 0 && r.Y <= 0 && r.X+r.W >= screenW && r.Y+r.H >= screenH
+}
+
+// rotateArgs returns the VLC options that turn a zone's content
+// clockwise by zone.Rotate degrees.
+func rotateArgs(zone template.Zone) []string {
+	if zone.Rotate == 0 {
+		return nil
+	}
+	return []string{"--video-filter=transform", "--transform-type=" + strconv.Itoa(zone.Rotate)}
+}
+
+// windowArgs returns the VLC options that place a zone's video output:
+// fullscreen OR exact window placement.
+func windowArgs(zone template.Zone, screenW, screenH int) []string {
+	if isFullZone(zone, screenW, screenH) {
+		return []string{"--fullscreen"}
+	}
+	r := zone.Rect(screenW, screenH)
+	return []string{
+		"--width=" + strconv.Itoa(r.W),
+		"--height=" + strconv.Itoa(r.H),
+		"--video-x=" + strconv.Itoa(r.X),
+		"--video-y=" + strconv.Itoa(r.Y),
+	}
+}
+
+// itemOptions returns VLC per-input options (":opt" arguments that
+// follow an MRL) for an item's manifest settings.
+func itemOptions(it media.Item) []string {
+	var opts []string
+	if it.Duration > 0 {
+		secs := strconv.FormatFloat(it.Duration.Seconds(), 'f', -1, 64)
+		if media.Detect(it.Path) == media.Image {
+			opts = append(opts, ":image-duration="+secs)
+		} else {
+			opts = append(opts, ":stop-time="+secs)
+		}
+	}
+	if it.Mute {
+		opts = append(opts, ":no-audio")
+	}
+	return opts
+}
+
 func (b *vlcBackend) Stop() {
 	b.kill()
 }
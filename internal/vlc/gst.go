@@ -0,0 +1,119 @@
+package vlc
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"player-native/internal/template"
+)
+
+// Pipeline descriptions for the GStreamer backend (engine_gst.go). They
+// need no cgo, so they build and are tested without GStreamer.
+
+// gstFrameRate is the frame rate of every zone and of the output.
+const gstFrameRate = 30
+
+// gstHoldFrame is the intervideosrc timeout, in nanoseconds, after
+// which it would show black instead of the zone's last frame: never,
+// so that a zone holds its picture between items.
+const gstHoldFrame = "18446744073709551615"
+
+// gstOutputDesc describes the shared pipeline: a compositor drawing the
+// zones onto a screenW x screenH surface and a mixer for their audio.
+// Zones link into both at run time. A tiny transparent test source
+// keeps the compositor running, and a silent one the mixer, while no
+// zone plays.
+func gstOutputDesc(screenW, screenH int, kms bool) string {
+	sink := "autovideosink"
+	if kms {
+		sink = "kmssink"
+	}
+	return fmt.Sprintf("compositor name=comp background=black sink_0::alpha=0 "+
+		"! video/x-raw,width=%d,height=%d,framerate=%d/1 ! videoconvert ! queue ! %s "+
+		"videotestsrc is-live=true pattern=black ! video/x-raw,width=16,height=16,framerate=%d/1 ! comp.sink_0 "+
+		"audiomixer name=mix ! audioconvert ! audioresample ! autoaudiosink "+
+		"audiotestsrc is-live=true wave=silence ! mix.",
+		screenW, screenH, gstFrameRate, sink, gstFrameRate)
+}
+
+// gstZoneCaps are the raw video caps of a zone's picture: its size on
+// screen, with an alpha channel for transparent zones.
+func gstZoneCaps(zone template.Zone, screenW, screenH int) string {
+	r := zone.Rect(screenW, screenH)
+	format := "I420"
+	if zone.Transparent {
+		format = "BGRA"
+	}
+	return fmt.Sprintf("video/x-raw,format=%s,width=%d,height=%d,framerate=%d/1,pixel-aspect-ratio=1/1",
+		format, r.W, r.H, gstFrameRate)
+}
+
+// gstVideoSinkDesc is the video sink of a zone's playbin. It rotates and
+// scales the picture to the zone and hands it to the shared pipeline
+// over an inter channel.
+func gstVideoSinkDesc(zone template.Zone, screenW, screenH int, channel string) string {
+	var desc string
+	if flip := gstFlip[zone.Rotate]; flip != "" {
+		desc = "videoflip method=" + flip + " ! "
+	}
+	return desc + "videoconvert ! videoscale ! videorate ! " + gstZoneCaps(zone, screenW, screenH) +
+		" ! intervideosink channel=" + channel
+}
+
+// gstVideoSourceDesc is the zone's branch into the compositor.
+func gstVideoSourceDesc(zone template.Zone, screenW, screenH int, channel string) string {
+	return "intervideosrc channel=" + channel + " timeout=" + gstHoldFrame +
+		" ! " + gstZoneCaps(zone, screenW, screenH) + " ! queue"
+}
+
+// gstAudioSinkDesc is the audio sink of a zone's playbin.
+func gstAudioSinkDesc(channel string) string {
+	return "audioconvert ! audioresample ! interaudiosink channel=" + channel
+}
+
+// gstAudioSourceDesc is the zone's branch into the mixer.
+func gstAudioSourceDesc(channel string) string {
+	return "interaudiosrc channel=" + channel + " ! audioconvert ! audioresample ! queue"
+}
+
+// gstPadProps returns the compositor pad properties that place a zone,
+// as name and value pairs.
+func gstPadProps(zone template.Zone, screenW, screenH int) [][2]string {
+	r := zone.Rect(screenW, screenH)
+	return [][2]string{
+		{"xpos", strconv.Itoa(r.X)},
+		{"ypos", strconv.Itoa(r.Y)},
+		{"width", strconv.Itoa(r.W)},
+		{"height", strconv.Itoa(r.H)},
+		{"alpha", strconv.FormatFloat(zone.Alpha(), 'f', -1, 64)},
+	}
+}
+
+// gstBelow reports whether zone a is stacked below zone b: by Zindex,
+// ties broken by zone ID, so that the order does not depend on which
+// zone started first.
+func gstBelow(a, b template.Zone) bool {
+	if a.Zindex != b.Zindex {
+		return a.Zindex < b.Zindex
+	}
+	return a.ID < b.ID
+}
+
+// gstFlip maps zone rotations to videoflip methods.
+var gstFlip = map[int]string{90: "clockwise", 180: "rotate-180", 270: "counterclockwise"}
+
+// gstURI returns the file URI playbin needs for a local path.
+func gstURI(path string) string {
+	p, err := filepath.Abs(path)
+	if err != nil {
+		p = path
+	}
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows drive letter
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
@@ -0,0 +1,54 @@
+package vlc
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"player-native/internal/template"
+)
+
+// Without a display server the libVLC backend renders straight to
+// DRM/KMS. That output is full screen and has room for one player, so
+// it goes to the first zone that covers the whole screen; every other
+// zone falls back to the VLC subprocess, which plays on DISPLAY=:0 as
+// before libVLC.
+
+var (
+	kmsMu   sync.Mutex
+	kmsZone string // zone holding the output, "" if none
+)
+
+// noDisplay reports whether no display server is configured.
+func noDisplay() bool {
+	return os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+}
+
+// claimKMS decides how zone is shown. It reports false when a display
+// server is running, and true when the zone gets the DRM/KMS output.
+// A zone that cannot have the output gets an error wrapping
+// ErrFallback.
+func claimKMS(zone template.Zone, screenW, screenH int) (bool, error) {
+	if !noDisplay() {
+		return false, nil
+	}
+	if !isFullZone(zone, screenW, screenH) {
+		return false, fmt.Errorf("%w: DRM/KMS output is full screen and zone %q is not", ErrFallback, zone.ID)
+	}
+	kmsMu.Lock()
+	defer kmsMu.Unlock()
+	if kmsZone != "" && kmsZone != zone.ID {
+		return false, fmt.Errorf("%w: DRM/KMS output already used by zone %q", ErrFallback, kmsZone)
+	}
+	kmsZone = zone.ID
+	return true, nil
+}
+
+// releaseKMS gives up the DRM/KMS output held by zoneID.
+func releaseKMS(zoneID string) {
+	kmsMu.Lock()
+	defer kmsMu.Unlock()
+	if kmsZone == zoneID {
+		kmsZone = ""
+	}
+}
@@ -0,0 +1,206 @@
+package vlc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// mpv's JSON IPC is one JSON object per line in each direction. Commands
+// carry a request_id that mpv echoes in the reply; everything else mpv
+// sends is an event:
+//
+//	{"command":["set_property","pause",true],"request_id":3}
+//	{"request_id":3,"error":"success","data":null}
+//	{"event":"start-file","playlist_entry_id":2}
+//	{"event":"property-change","id":2,"name":"time-pos","data":12.5}
+
+// mpvObserved lists the properties the client observes, indexed by the
+// observe ID used for them.
+var mpvObserved = []string{"path", "time-pos", "duration", "pause", "volume"}
+
+// mpvMessage is any line received from mpv.
+type mpvMessage struct {
+	RequestID int             `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+
+	Event   string `json:"event"`
+	Name    string `json:"name"`
+	EntryID int    `json:"playlist_entry_id"`
+	Reason  string `json:"reason"`
+}
+
+// mpvState is the last known player state from observed properties.
+type mpvState struct {
+	path     string
+	pos      float64
+	duration float64
+	paused   bool
+	volume   float64
+}
+
+// mpvClient is a connection to one mpv process's IPC socket.
+type mpvClient struct {
+	conn net.Conn
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan mpvMessage
+	st      mpvState
+	onEvent func(mpvMessage) // called for events other than property changes
+}
+
+// dialMPV connects to mpv's IPC socket, retrying until mpv has opened it
+// or the timeout expires.
+func dialMPV(addr string, timeout time.Duration) (*mpvClient, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("unix", addr, time.Second)
+		if err == nil {
+			return newMPVClient(conn), nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("mpv ipc connect %s: %w", addr, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func newMPVClient(conn net.Conn) *mpvClient {
+	return &mpvClient{conn: conn, pending: make(map[int]chan mpvMessage)}
+}
+
+// command sends a command and waits for its reply. cmd is either a
+// positional argument list or a map for mpv's named-argument form.
+func (c *mpvClient) command(cmd any) (json.RawMessage, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan mpvMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	line, err := json.Marshal(map[string]any{"command": cmd, "request_id": id})
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.conn.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != "success" {
+			return nil, fmt.Errorf("mpv: %s", msg.Error)
+		}
+		return msg.Data, nil
+	case <-time.After(2 * time.Second):
+		return nil, fmt.Errorf("mpv: no reply")
+	}
+}
+
+// observe subscribes to the properties in mpvObserved.
+func (c *mpvClient) observe() error {
+	for i, name := range mpvObserved {
+		if _, err := c.command([]any{"observe_property", i + 1, name}); err != nil {
+			return fmt.Errorf("observe %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readLoop dispatches replies and events until the connection closes.
+func (c *mpvClient) readLoop() {
+	sc := bufio.NewScanner(c.conn)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		c.handleLine(sc.Bytes())
+	}
+}
+
+func (c *mpvClient) handleLine(line []byte) {
+	var msg mpvMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	if msg.Event == "" {
+		if ch, ok := c.pending[msg.RequestID]; ok {
+			ch <- msg
+		}
+		c.mu.Unlock()
+		return
+	}
+	if msg.Event == "property-change" {
+		c.setPropertyLocked(msg.Name, msg.Data)
+		c.mu.Unlock()
+		return
+	}
+	fn := c.onEvent
+	c.mu.Unlock()
+
+	if fn != nil {
+		fn(msg)
+	}
+}
+
+// setPropertyLocked folds an observed property into the state. A
+// missing value (e.g. between files) resets it.
+func (c *mpvClient) setPropertyLocked(name string, data json.RawMessage) {
+	switch name {
+	case "path":
+		c.st.path = ""
+		json.Unmarshal(data, &c.st.path)
+	case "time-pos":
+		c.st.pos = 0
+		json.Unmarshal(data, &c.st.pos)
+	case "duration":
+		c.st.duration = 0
+		json.Unmarshal(data, &c.st.duration)
+	case "pause":
+		json.Unmarshal(data, &c.st.paused)
+	case "volume":
+		json.Unmarshal(data, &c.st.volume)
+	}
+}
+
+// playlist returns mpv's playlist entries.
+func (c *mpvClient) playlist() ([]mpvEntry, error) {
+	data, err := c.command([]any{"get_property", "playlist"})
+	if err != nil {
+		return nil, err
+	}
+	var entries []mpvEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("mpv playlist: %w", err)
+	}
+	return entries, nil
+}
+
+// mpvEntry is one element of mpv's "playlist" property.
+type mpvEntry struct {
+	Filename string `json:"filename"`
+	ID       int    `json:"id"`
+	Current  bool   `json:"current"`
+}
+
+func (c *mpvClient) state() mpvState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.st
+}
+
+func (c *mpvClient) close() {
+	c.conn.Close()
+}
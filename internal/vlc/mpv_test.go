@@ -0,0 +1,207 @@
+package vlc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+)
+
+// fakeMPV answers IPC commands over a pipe: "get_property playlist" with
+// a canned playlist, everything else with success. Commands other than
+// observe_property are recorded as JSON.
+type fakeMPV struct {
+	conn     net.Conn
+	playlist string
+	commands chan string
+}
+
+func newFakeMPV(t *testing.T, playlist string) (*fakeMPV, *mpvClient) {
+	t.Helper()
+	mpvSide, goSide := net.Pipe()
+	f := &fakeMPV{conn: mpvSide, playlist: playlist, commands: make(chan string, 64)}
+	c := newMPVClient(goSide)
+	go c.readLoop()
+	go func() {
+		sc := bufio.NewScanner(mpvSide)
+		for sc.Scan() {
+			var req struct {
+				Command   json.RawMessage `json:"command"`
+				RequestID int             `json:"request_id"`
+			}
+			json.Unmarshal(sc.Bytes(), &req)
+			data := "null"
+			cmd := string(req.Command)
+			switch {
+			case cmd == `["get_property","playlist"]`:
+				data = f.playlist
+			case !strings.HasPrefix(cmd, `["observe_property"`):
+				f.commands <- cmd
+			}
+			reply, _ := json.Marshal(map[string]any{"request_id": req.RequestID, "error": "success", "data": json.RawMessage(data)})
+			mpvSide.Write(append(reply, '\n'))
+		}
+	}()
+	t.Cleanup(func() {
+		c.close()
+		mpvSide.Close()
+	})
+	return f, c
+}
+
+func (f *fakeMPV) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case cmd := <-f.commands:
+		return cmd
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a command")
+		return ""
+	}
+}
+
+// send writes an unsolicited line (an event) from mpv.
+func (f *fakeMPV) send(line string) {
+	f.conn.Write([]byte(line + "\n"))
+}
+
+// TestMPVClientRepliesAndEvents checks request/reply pairing, property
+// observation and event delivery.
+func TestMPVClientRepliesAndEvents(t *testing.T) {
+	f, c := newFakeMPV(t, `[{"filename":"/p/a.mp4","id":1,"current":true}]`)
+	events := make(chan mpvMessage, 4)
+	c.onEvent = func(msg mpvMessage) { events <- msg }
+
+	if err := c.observe(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := c.playlist()
+	if err != nil || len(entries) != 1 || entries[0].Filename != "/p/a.mp4" || !entries[0].Current {
+		t.Fatalf("playlist = %+v, %v", entries, err)
+	}
+
+	f.send(`{"event":"property-change","id":1,"name":"path","data":"/p/a.mp4"}`)
+	f.send(`{"event":"property-change","id":2,"name":"time-pos","data":12.5}`)
+	f.send(`{"event":"property-change","id":5,"name":"volume","data":80}`)
+	f.send(`{"event":"end-file","reason":"eof","playlist_entry_id":1}`)
+
+	select {
+	case ev := <-events:
+		if ev.Event != "end-file" || ev.Reason != "eof" || ev.EntryID != 1 {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+	if st := c.state(); st.path != "/p/a.mp4" || st.pos != 12.5 || st.volume != 80 {
+		t.Fatalf("unexpected state %+v", st)
+	}
+
+	// A property becoming unavailable resets it.
+	f.send(`{"event":"property-change","id":1,"name":"path"}`)
+	f.send(`{"event":"idle"}`)
+	<-events
+	if st := c.state(); st.path != "" {
+		t.Fatalf("expected path reset, got %q", st.path)
+	}
+}
+
+// TestMPVArgs covers zone geometry and per-file option groups.
+func TestMPVArgs(t *testing.T) {
+	zone := template.Zone{ID: "side", X: 75, Y: 0, Width: 25, Height: 50}
+	items := []media.Item{
+		{Path: "/p/a.mp4"},
+		{Path: "/p/b.jpg", Duration: 5 * time.Second, Mute: true},
+	}
+	args := strings.Join(mpvArgs(zone, 1920, 1080, items, "/tmp/s.sock", false), " ")
+
+	for _, want := range []string{
+		"--geometry=480x540+1440+0",
+		"--loop-playlist=inf",
+		"--input-ipc-server=/tmp/s.sock",
+		"--image-display-duration=10",
+		" /p/a.mp4 --{ --image-display-duration=5 --aid=no /p/b.jpg --}",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in %s", want, args)
+		}
+	}
+
+	full := strings.Join(mpvArgs(template.Zone{ID: "main", Width: 100, Height: 100}, 1920, 1080, items, "", true), " ")
+	if !strings.Contains(full, "--gpu-context=drm") || strings.Contains(full, "--input-ipc-server") || strings.Contains(full, "--geometry") {
+		t.Errorf("unexpected KMS args: %s", full)
+	}
+}
+
+// TestMPVHotSwap checks that a playlist update keeps the current entry,
+// appends the new list from the resume position and removes the old
+// entry once mpv starts the next one.
+func TestMPVHotSwap(t *testing.T) {
+	a, _ := filepath.Abs("a.mp4")
+	b, _ := filepath.Abs("b.mp4")
+	n, _ := filepath.Abs("n.mp4")
+	f, c := newFakeMPV(t, `[{"filename":"`+filepath.ToSlash(b)+`","id":5,"current":true}]`)
+	c.mu.Lock()
+	c.st.path = b
+	c.mu.Unlock()
+
+	tracker := newPlayTracker("main", nil)
+	be := &mpvBackend{
+		ipc:     c,
+		tracker: tracker,
+		items:   media.ItemsFromPaths([]string{"a.mp4", "b.mp4", "c.mp4"}),
+	}
+	if err := be.UpdatePlaylist(media.ItemsFromPaths([]string{"a.mp4", "b.mp4", "n.mp4"})); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := f.next(t); cmd != `["playlist-clear"]` {
+		t.Fatalf("expected playlist-clear, got %s", cmd)
+	}
+	var urls []string
+	for i := 0; i < 3; i++ {
+		var lf struct{ Name, URL, Flags string }
+		json.Unmarshal([]byte(f.next(t)), &lf)
+		if lf.Name != "loadfile" || lf.Flags != "append" {
+			t.Fatalf("unexpected command %+v", lf)
+		}
+		urls = append(urls, lf.URL)
+	}
+	if urls[0] != n || urls[1] != a || urls[2] != b {
+		t.Fatalf("expected the new list rotated to start after b.mp4, got %q", urls)
+	}
+
+	// mpv moves on to the first new entry: the old one is removed.
+	f.playlist = `[{"filename":"` + filepath.ToSlash(b) + `","id":5},{"filename":"` + filepath.ToSlash(n) + `","id":6,"current":true}]`
+	be.startFile(c, tracker, 6, time.Now())
+	if cmd := f.next(t); cmd != `["playlist-remove",0]` {
+		t.Fatalf("expected the pre-swap entry to be removed, got %s", cmd)
+	}
+}
+
+// TestBackendFactory checks per-zone overrides and unknown names.
+func TestBackendFactory(t *testing.T) {
+	if _, err := NewBackendFactory("gstreamer9"); err == nil {
+		t.Fatal("expected an error for an unknown default")
+	}
+	f, err := NewBackendFactory("vlc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := f(template.Zone{ID: "main", Backend: "mpv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*mpvBackend); !ok {
+		t.Fatalf("expected the zone override to pick mpv, got %T", b)
+	}
+	if _, err := f(template.Zone{ID: "main", Backend: "nope"}); err == nil || !strings.Contains(err.Error(), `zone "main"`) {
+		t.Fatalf("expected a zone error, got %v", err)
+	}
+}
@@ -0,0 +1,423 @@
+package vlc
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"player-native/internal/media"
+)
+
+// VLC's RC ("remote control") interface is a line-based text protocol.
+// Replies carry no request IDs, so the client does not pair commands
+// with responses; it parses every line it reads into a running state:
+//
+//	( new input: file:///playlist/a.mp4 )   status / status change
+//	( audio volume: 256 )
+//	( state playing )
+//	12                                      get_time / get_length
+//
+// Bare numbers are matched to outstanding get_time/get_length queries
+// in the order they were sent.
+//
+// The "playlist" command replies with a tree framed by header lines;
+// entries carry playlist item IDs and the current one is starred:
+//
+//	+----[ Playlist - playlist ]
+//	| 1 - Playlist
+//	|   4 - a.mp4 (00:00:15) [played 1 time]
+//	|  *5 - b.jpg (00:00:10)
+//	| 2 - Media Library
+//	+----[ End of playlist ]
+var (
+	rcInputRe  = regexp.MustCompile(`new input:\s*(.+?)\s*\)`)
+	rcStateRe  = regexp.MustCompile(`\(\s*state\s+(\w+)\s*\)`)
+	rcVolumeRe = regexp.MustCompile(`audio volume:\s*(\d+)`)
+	rcEntryRe  = regexp.MustCompile(`^\|([ *]*)(\d+) - `)
+)
+
+// rcEntry is one item of VLC's playlist.
+type rcEntry struct {
+	id      int
+	current bool
+}
+
+// rcListing accumulates a "playlist" reply. Only children of the first
+// top-level node (the playlist itself, not the media library) are kept.
+type rcListing struct {
+	topCol  int
+	nodes   int
+	entries []rcEntry
+}
+
+// rcState is the last known VLC state as reported over RC.
+type rcState struct {
+	input  string // MRL of the current input
+	state  string // playing, paused, stopped
+	volume int    // 0..512, 256 = 100%
+	time   int    // position in seconds
+	length int    // current input length in seconds
+}
+
+// rcClient is a connection to one VLC process's RC interface.
+type rcClient struct {
+	conn net.Conn
+
+	mu       sync.Mutex
+	st       rcState
+	pending  []string // "time"/"length" queries awaiting a numeric reply
+	onChange func(rcState)
+
+	listMu  sync.Mutex // one playlist query at a time
+	listing *rcListing // reply being parsed
+	listCh  chan []rcEntry
+}
+
+// dialRC connects to the RC interface, retrying until VLC has opened
+// its socket or the timeout expires.
+func dialRC(network, addr string, timeout time.Duration) (*rcClient, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout(network, addr, time.Second)
+		if err == nil {
+			return newRCClient(conn), nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("rc connect %s: %w", addr, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func newRCClient(conn net.Conn) *rcClient {
+	return &rcClient{conn: conn}
+}
+
+// send writes one or more commands.
+func (c *rcClient) send(cmds ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var sb strings.Builder
+	for _, cmd := range cmds {
+		switch cmd {
+		case "get_time":
+			c.pending = append(c.pending, "time")
+		case "get_length":
+			c.pending = append(c.pending, "length")
+		}
+		sb.WriteString(cmd)
+		sb.WriteByte('\n')
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	_, err := c.conn.Write([]byte(sb.String()))
+	return err
+}
+
+// poll requests a status refresh.
+func (c *rcClient) poll() error {
+	return c.send("status", "get_time", "get_length")
+}
+
+// readLoop parses replies until the connection closes.
+func (c *rcClient) readLoop() {
+	sc := bufio.NewScanner(c.conn)
+	for sc.Scan() {
+		c.handleLine(sc.Text())
+	}
+}
+
+// handleLine folds one reply line into the state and notifies onChange
+// when it carried state.
+func (c *rcClient) handleLine(line string) {
+	line = strings.TrimSpace(strings.TrimLeft(line, "> "))
+	if line == "" {
+		return
+	}
+
+	c.mu.Lock()
+	if c.handleListingLocked(line) {
+		c.mu.Unlock()
+		return
+	}
+	matched := true
+	switch {
+	case rcInputRe.MatchString(line):
+		if in := rcInputRe.FindStringSubmatch(line)[1]; in != c.st.input {
+			c.st.input = in
+			c.st.time, c.st.length = 0, 0
+		}
+	case rcStateRe.MatchString(line):
+		c.st.state = rcStateRe.FindStringSubmatch(line)[1]
+	case rcVolumeRe.MatchString(line):
+		c.st.volume, _ = strconv.Atoi(rcVolumeRe.FindStringSubmatch(line)[1])
+	default:
+		n, err := strconv.Atoi(line)
+		if err != nil || len(c.pending) == 0 {
+			matched = false
+			break
+		}
+		switch c.pending[0] {
+		case "time":
+			c.st.time = n
+		case "length":
+			c.st.length = n
+		}
+		c.pending = c.pending[1:]
+	}
+	st := c.st
+	fn := c.onChange
+	c.mu.Unlock()
+
+	if matched && fn != nil {
+		fn(st)
+	}
+}
+
+// handleListingLocked consumes lines belonging to a playlist reply and
+// reports whether line was one of them.
+func (c *rcClient) handleListingLocked(line string) bool {
+	switch {
+	case strings.HasPrefix(line, "+----[ End of"):
+		if c.listing != nil {
+			if c.listCh != nil {
+				c.listCh <- c.listing.entries
+				c.listCh = nil
+			}
+			c.listing = nil
+		}
+		return true
+	case strings.HasPrefix(line, "+----["):
+		c.listing = &rcListing{topCol: -1}
+		return true
+	case c.listing == nil:
+		return false
+	}
+
+	m := rcEntryRe.FindStringSubmatch(line)
+	if m == nil {
+		return true
+	}
+	l := c.listing
+	col := len(m[1])
+	if l.topCol < 0 || col <= l.topCol {
+		l.topCol = col
+		l.nodes++
+		return true
+	}
+	if l.nodes == 1 {
+		id, _ := strconv.Atoi(m[2])
+		l.entries = append(l.entries, rcEntry{id: id, current: strings.Contains(m[1], "*")})
+	}
+	return true
+}
+
+// playlist lists VLC's playlist entries.
+func (c *rcClient) playlist(timeout time.Duration) ([]rcEntry, error) {
+	c.listMu.Lock()
+	defer c.listMu.Unlock()
+
+	ch := make(chan []rcEntry, 1)
+	c.mu.Lock()
+	c.listCh = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.listCh = nil
+		c.mu.Unlock()
+	}()
+
+	if err := c.send("playlist"); err != nil {
+		return nil, err
+	}
+	select {
+	case entries := <-ch:
+		return entries, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("playlist query timed out")
+	}
+}
+
+func (c *rcClient) state() rcState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.st
+}
+
+func (c *rcClient) close() {
+	c.conn.Close()
+}
+
+// rcEnqueue returns the RC command that adds item to the playlist,
+// starting playback when play is set. The MRL is quoted so paths with
+// spaces survive VLC's argument parsing; per-item options follow it.
+func rcEnqueue(it media.Item, play bool) string {
+	cmd := "enqueue"
+	if play {
+		cmd = "add"
+	}
+	path, err := filepath.Abs(it.Path)
+	if err != nil {
+		path = it.Path
+	}
+	quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+	parts := append([]string{cmd, quoted}, itemOptions(it)...)
+	return strings.Join(parts, " ")
+}
+
+// mrlPath converts a file:// MRL reported by VLC back to a local path.
+func mrlPath(mrl string) string {
+	u, err := url.Parse(mrl)
+	if err != nil || u.Scheme != "file" {
+		return mrl
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.Clean(filepath.FromSlash(p))
+}
+
+// replayGuard ignores position jumps right after an input change, when
+// a get_time reply may still describe the previous input.
+const replayGuard = 2 * time.Second
+
+// playTracker turns RC state updates into item-level play events. An
+// item ends when the input changes, or when the position jumps back to
+// the start of the same input (a single-item loop or repeated entry).
+// Transitions the player requested (skip, replace, stop) are reported
+// as interrupted; everything else completed naturally.
+type playTracker struct {
+	mu     sync.Mutex
+	zoneID string
+	byPath map[string]media.Item
+	emit   EventFunc
+
+	input    string
+	item     media.Item
+	start    time.Time
+	lastTime int
+	cut      bool // the next boundary was caused by us
+}
+
+func newPlayTracker(zoneID string, items []media.Item) *playTracker {
+	t := &playTracker{zoneID: zoneID}
+	t.setItems(items)
+	return t
+}
+
+// setItems updates the path → item index used to recover per-item
+// metadata from the MRLs VLC reports.
+func (t *playTracker) setItems(items []media.Item) {
+	byPath := make(map[string]media.Item, len(items))
+	for _, it := range items {
+		p, err := filepath.Abs(it.Path)
+		if err != nil {
+			p = it.Path
+		}
+		if _, ok := byPath[p]; !ok {
+			byPath[p] = it
+		}
+	}
+	t.mu.Lock()
+	t.byPath = byPath
+	t.mu.Unlock()
+}
+
+func (t *playTracker) setEmit(fn EventFunc) {
+	t.mu.Lock()
+	t.emit = fn
+	t.mu.Unlock()
+}
+
+// interrupt marks the current item as cut short by the player.
+func (t *playTracker) interrupt() {
+	t.mu.Lock()
+	t.cut = true
+	t.mu.Unlock()
+}
+
+// observe processes a state update received at now.
+func (t *playTracker) observe(st rcState, now time.Time) {
+	t.mu.Lock()
+	var ev *PlayEvent
+	switch {
+	case st.input == "" || st.state == "stopped":
+		// Between inputs (e.g. after "clear"); the next input ends
+		// the current item.
+	case st.input != t.input:
+		ev = t.endLocked(now)
+		t.beginLocked(st.input, now)
+	case st.time+1 < t.lastTime && now.Sub(t.start) >= replayGuard:
+		ev = t.endLocked(now)
+		t.beginLocked(st.input, now)
+		t.lastTime = st.time
+	default:
+		t.lastTime = st.time
+	}
+	t.unlockEmit(ev)
+}
+
+// advance ends the current item and starts input, for backends that are
+// told about every item start (including repeats of the same input).
+func (t *playTracker) advance(input string, now time.Time) {
+	t.mu.Lock()
+	ev := t.endLocked(now)
+	t.beginLocked(input, now)
+	t.unlockEmit(ev)
+}
+
+// finish ends the current item, e.g. when playback stops.
+func (t *playTracker) finish(now time.Time, completed bool) {
+	t.mu.Lock()
+	if !completed {
+		t.cut = true
+	}
+	ev := t.endLocked(now)
+	t.input = ""
+	t.unlockEmit(ev)
+}
+
+func (t *playTracker) beginLocked(input string, now time.Time) {
+	t.input = input
+	t.start = now
+	t.lastTime = 0
+	path := mrlPath(input)
+	if it, ok := t.byPath[path]; ok {
+		t.item = it
+	} else {
+		t.item = media.Item{Path: path}
+	}
+}
+
+func (t *playTracker) endLocked(now time.Time) *PlayEvent {
+	if t.input == "" {
+		t.cut = false
+		return nil
+	}
+	ev := &PlayEvent{
+		ZoneID:    t.zoneID,
+		Item:      t.item,
+		Start:     t.start,
+		End:       now,
+		Completed: !t.cut,
+	}
+	t.cut = false
+	return ev
+}
+
+// unlockEmit releases the lock and delivers ev outside it.
+func (t *playTracker) unlockEmit(ev *PlayEvent) {
+	fn := t.emit
+	t.mu.Unlock()
+	if ev != nil && fn != nil {
+		fn(*ev)
+	}
+}
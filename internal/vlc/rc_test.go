@@ -0,0 +1,224 @@
+package vlc
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"player-native/internal/media"
+)
+
+// TestRCClientParsesReplies drives the client over a pipe with the
+// reply format of VLC 3's RC interface.
+func TestRCClientParsesReplies(t *testing.T) {
+	vlcSide, goSide := net.Pipe()
+	defer vlcSide.Close()
+	c := newRCClient(goSide)
+	defer c.close()
+
+	updates := make(chan rcState, 16)
+	c.onChange = func(st rcState) { updates <- st }
+	go c.readLoop()
+
+	// Fake VLC: answer a poll.
+	go func() {
+		r := bufio.NewReader(vlcSide)
+		for i := 0; i < 3; i++ {
+			r.ReadString('\n')
+		}
+		vlcSide.Write([]byte("VLC media player 3.0.20 Vetinari\n" +
+			"> ( new input: file:///playlist/a%20b.mp4 )\n" +
+			"( audio volume: 256 )\n" +
+			"( state playing )\n" +
+			"> 12\n" +
+			"> 30\n"))
+	}()
+	if err := c.poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case st := <-updates:
+			if st.length != 30 {
+				continue
+			}
+			if st.input != "file:///playlist/a%20b.mp4" || st.state != "playing" || st.volume != 256 || st.time != 12 {
+				t.Fatalf("unexpected state: %+v", st)
+			}
+			if got := mrlPath(st.input); got != filepath.FromSlash("/playlist/a b.mp4") {
+				t.Errorf("mrlPath = %q", got)
+			}
+			return
+		case <-deadline:
+			t.Fatalf("timed out, last state %+v", c.state())
+		}
+	}
+}
+
+// TestRCEnqueueQuotes ensures paths with spaces and quotes survive
+// VLC's argument parsing and per-item options follow the MRL.
+func TestRCEnqueueQuotes(t *testing.T) {
+	it := media.Item{Path: `/p/my "best" ad.jpg`, Duration: 5 * time.Second, Mute: true}
+	got := rcEnqueue(it, true)
+	if !strings.HasPrefix(got, `add "`) || !strings.Contains(got, `my \"best\" ad.jpg"`) {
+		t.Errorf("bad quoting: %s", got)
+	}
+	if !strings.HasSuffix(got, ":image-duration=5 :no-audio") {
+		t.Errorf("missing options: %s", got)
+	}
+	if !strings.HasPrefix(rcEnqueue(it, false), "enqueue ") {
+		t.Error("expected enqueue when not playing")
+	}
+}
+
+// TestPlayTrackerBoundaries covers natural advances, skips, same-item
+// loops and stale positions right after an input change.
+func TestPlayTrackerBoundaries(t *testing.T) {
+	a, _ := filepath.Abs("a.mp4")
+	b, _ := filepath.Abs("b.jpg")
+	items := []media.Item{{Path: "a.mp4", Metadata: map[string]string{"campaign": "x"}}, {Path: "b.jpg"}}
+	uri := func(p string) string { return "file://" + filepath.ToSlash(p) }
+
+	var events []PlayEvent
+	tr := newPlayTracker("main", items)
+	tr.setEmit(func(ev PlayEvent) { events = append(events, ev) })
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	tr.observe(rcState{input: uri(a), state: "playing"}, at(0))
+	tr.observe(rcState{input: uri(a), state: "playing", time: 14}, at(14))
+	tr.observe(rcState{input: uri(b), state: "playing"}, at(15))           // natural advance
+	tr.observe(rcState{input: uri(b), state: "playing", time: 14}, at(15)) // stale reply for a
+	tr.observe(rcState{input: uri(b), state: "playing", time: 1}, at(16))
+	tr.interrupt()
+	tr.observe(rcState{input: uri(a), state: "playing"}, at(17)) // skip
+	tr.observe(rcState{input: uri(a), state: "playing", time: 9}, at(26))
+	tr.observe(rcState{input: uri(a), state: "playing", time: 0}, at(30)) // looped
+	tr.finish(at(31), false)
+
+	want := []struct {
+		path      string
+		completed bool
+		secs      int
+	}{
+		{"a.mp4", true, 15},
+		{"b.jpg", false, 2},
+		{"a.mp4", true, 13},
+		{"a.mp4", false, 1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		ev := events[i]
+		if ev.Item.Path != w.path || ev.Completed != w.completed || ev.End.Sub(ev.Start) != time.Duration(w.secs)*time.Second {
+			t.Errorf("event %d: got %s completed=%v %s, want %+v", i, ev.Item.Path, ev.Completed, ev.End.Sub(ev.Start), w)
+		}
+		if ev.ZoneID != "main" {
+			t.Errorf("event %d: zone %q", i, ev.ZoneID)
+		}
+	}
+	if events[0].Item.Metadata["campaign"] != "x" {
+		t.Error("expected item metadata to be recovered from the MRL")
+	}
+}
+
+// fakeVLC answers RC commands over a pipe: "playlist" with a canned
+// listing, everything else is recorded.
+type fakeVLC struct {
+	conn     net.Conn
+	listing  string
+	commands chan string
+}
+
+func newFakeVLC(t *testing.T, listing string) (*fakeVLC, *rcClient) {
+	t.Helper()
+	vlcSide, goSide := net.Pipe()
+	f := &fakeVLC{conn: vlcSide, listing: listing, commands: make(chan string, 64)}
+	c := newRCClient(goSide)
+	go c.readLoop()
+	go func() {
+		sc := bufio.NewScanner(vlcSide)
+		for sc.Scan() {
+			if sc.Text() == "playlist" {
+				vlcSide.Write([]byte(f.listing))
+				continue
+			}
+			f.commands <- sc.Text()
+		}
+	}()
+	t.Cleanup(func() {
+		c.close()
+		vlcSide.Close()
+	})
+	return f, c
+}
+
+func (f *fakeVLC) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case cmd := <-f.commands:
+		return cmd
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a command")
+		return ""
+	}
+}
+
+// TestHotSwapQueuesBehindCurrent checks that a playlist update leaves
+// the current item alone, queues the new list from the resume position
+// and retires the old entry once VLC moves on.
+func TestHotSwapQueuesBehindCurrent(t *testing.T) {
+	f, rc := newFakeVLC(t, "+----[ Playlist - playlist ]\n"+
+		"| 1 - Playlist\n"+
+		"|   4 - a.mp4 (00:00:15)\n"+
+		"|  *5 - b.mp4 (00:00:10)\n"+
+		"|   6 - c.mp4 (00:00:10)\n"+
+		"| 2 - Media Library\n"+
+		"|   9 - old.mp4\n"+
+		"+----[ End of playlist ]\n")
+
+	a, _ := filepath.Abs("a.mp4")
+	b, _ := filepath.Abs("b.mp4")
+	cur := "file://" + filepath.ToSlash(b)
+	rc.handleLine("( new input: " + cur + " )")
+	rc.handleLine("( state playing )")
+
+	be := &vlcBackend{
+		rc:      rc,
+		tracker: newPlayTracker("main", nil),
+		items:   media.ItemsFromPaths([]string{"a.mp4", "b.mp4", "c.mp4"}),
+	}
+	rc.onChange = func(st rcState) { be.retireFinished(rc, st) }
+
+	if err := be.UpdatePlaylist(media.ItemsFromPaths([]string{"a.mp4", "b.mp4", "n.mp4", "c.mp4"})); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, f.next(t))
+	}
+	want := []string{"delete 4", "delete 6", "enqueue", "enqueue", "enqueue", "enqueue"}
+	for i, w := range want {
+		if !strings.HasPrefix(got[i], w) {
+			t.Fatalf("command %d: got %q, want %q… (all: %q)", i, got[i], w, got)
+		}
+	}
+	if !strings.Contains(got[2], "n.mp4") || !strings.Contains(got[5], `b.mp4"`) {
+		t.Errorf("expected the new list rotated to start after b.mp4, got %q", got[2:])
+	}
+
+	// Still on b: nothing retired. Moving on deletes the old entry.
+	rc.handleLine("( new input: " + cur + " )")
+	rc.handleLine("( new input: file://" + filepath.ToSlash(a) + " )")
+	if cmd := f.next(t); cmd != "delete 5" {
+		t.Fatalf("expected the pre-swap entry to be retired, got %q", cmd)
+	}
+}
@@ -0,0 +1,341 @@
+// Package vlctest provides a simulated playback backend and a virtual
+// clock for testing vlc.Engine without a VLC binary. The fake backend
+// "plays" each item for its duration on the virtual clock, loops like
+// the real backends, reports play events, and records every call.
+package vlctest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"player-native/internal/media"
+	"player-native/internal/template"
+	"player-native/internal/vlc"
+)
+
+// DefaultItemDuration is how long the fake backend shows an item that
+// has no duration of its own.
+const DefaultItemDuration = 10 * time.Second
+
+// waitTimeout bounds the real time a test helper waits for the engine.
+const waitTimeout = 5 * time.Second
+
+// --- Clock ---
+
+type clockTimer struct {
+	clock *Clock
+	at    time.Time
+	ch    chan time.Time
+}
+
+// Clock is a virtual vlc.Clock. Time only moves when Advance is called.
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*clockTimer
+}
+
+// NewClock returns a clock set to start.
+func NewClock(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the virtual time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer that fires once the clock has been advanced
+// by at least d.
+func (c *Clock) NewTimer(d time.Duration) vlc.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &clockTimer{clock: c, at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// C implements vlc.Timer.
+func (t *clockTimer) C() <-chan time.Time { return t.ch }
+
+// Stop implements vlc.Timer. It reports whether the timer was pending.
+func (t *clockTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, p := range c.timers {
+		if p == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by d and fires every timer that falls
+// due, in deadline order.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	kept := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			kept = append(kept, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = kept
+}
+
+// Waiters returns the number of pending timers.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil waits (in real time) until at least n timers are pending,
+// i.e. until the goroutines under test are parked on the clock. It
+// panics after a few seconds so a broken test fails instead of hanging.
+func (c *Clock) BlockUntil(n int) {
+	deadline := time.AfterFunc(waitTimeout, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer deadline.Stop()
+	start := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		if time.Since(start) >= waitTimeout {
+			panic(fmt.Sprintf("vlctest: timed out waiting for %d timers (have %d)", n, len(c.timers)))
+		}
+		c.cond.Wait()
+	}
+}
+
+// --- Backend ---
+
+// Backend is a simulated vlc.Backend.
+type Backend struct {
+	clock *Clock
+
+	mu       sync.Mutex
+	zone     template.Zone
+	calls    []string
+	failNext error
+	stop     chan struct{} // closed by Stop during PlayAll
+	emit     vlc.EventFunc
+	started  chan []media.Item
+	onRaise  func(zoneID string)
+}
+
+// NewBackend returns a fake backend driven by clock.
+func NewBackend(clock *Clock) *Backend {
+	return &Backend{
+		clock:   clock,
+		started: make(chan []media.Item, 64),
+	}
+}
+
+// Init implements vlc.Backend.
+func (b *Backend) Init(zone template.Zone, screenW, screenH int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.zone = zone
+	b.record("Init %s %dx%d", zone.ID, screenW, screenH)
+	return nil
+}
+
+// PlayAll implements vlc.Backend. Items play in order, each for its
+// Duration (DefaultItemDuration if unset), looping until stopCh is
+// closed or Stop is called. After FailNext it returns the error
+// immediately instead.
+func (b *Backend) PlayAll(items []media.Item, stopCh <-chan struct{}) error {
+	b.mu.Lock()
+	b.record("PlayAll %s", strings.Join(media.Paths(items), ","))
+	if err := b.failNext; err != nil {
+		b.failNext = nil
+		b.mu.Unlock()
+		b.started <- items
+		return err
+	}
+	stop := make(chan struct{})
+	b.stop = stop
+	b.mu.Unlock()
+
+	b.started <- items
+	if len(items) == 0 {
+		return fmt.Errorf("empty playlist")
+	}
+
+	for i := 0; ; i = (i + 1) % len(items) {
+		it := items[i]
+		d := it.Duration
+		if d <= 0 {
+			d = DefaultItemDuration
+		}
+		start := b.clock.Now()
+		t := b.clock.NewTimer(d)
+		select {
+		case <-stopCh:
+			t.Stop()
+			b.report(it, start, false)
+			return nil
+		case <-stop:
+			t.Stop()
+			b.report(it, start, false)
+			return nil
+		case <-t.C():
+			b.report(it, start, true)
+		}
+	}
+}
+
+// Stop implements vlc.Backend.
+func (b *Backend) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record("Stop")
+	if b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+}
+
+// Release implements vlc.Backend.
+func (b *Backend) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record("Release")
+}
+
+// SetEventFunc implements vlc.EventSource.
+func (b *Backend) SetEventFunc(fn vlc.EventFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit = fn
+}
+
+// Windows implements vlc.Stacker. The zone ID stands for its window.
+func (b *Backend) Windows() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return []string{b.zone.ID}, nil
+}
+
+// Raise implements vlc.Stacker.
+func (b *Backend) Raise(windows []string) error {
+	b.mu.Lock()
+	b.record("Raise")
+	fn := b.onRaise
+	zoneID := b.zone.ID
+	b.mu.Unlock()
+	if fn != nil {
+		fn(zoneID)
+	}
+	return nil
+}
+
+// FailNext makes the next PlayAll return err without playing.
+func (b *Backend) FailNext(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failNext = err
+}
+
+// Calls returns the recorded calls, e.g. "PlayAll a.mp4,b.jpg".
+func (b *Backend) Calls() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.calls...)
+}
+
+// WaitPlayAll waits (in real time) for the next PlayAll call and
+// returns its items. It panics if none arrives within a few seconds.
+func (b *Backend) WaitPlayAll() []media.Item {
+	select {
+	case items := <-b.started:
+		return items
+	case <-time.After(waitTimeout):
+		panic("vlctest: timed out waiting for PlayAll")
+	}
+}
+
+// record appends a call. b.mu must be held.
+func (b *Backend) record(format string, args ...any) {
+	b.calls = append(b.calls, fmt.Sprintf(format, args...))
+}
+
+func (b *Backend) report(it media.Item, start time.Time, completed bool) {
+	b.mu.Lock()
+	fn := b.emit
+	zoneID := b.zone.ID
+	b.mu.Unlock()
+	if fn != nil {
+		fn(vlc.PlayEvent{ZoneID: zoneID, Item: it, Start: start, End: b.clock.Now(), Completed: completed})
+	}
+}
+
+// --- Factory ---
+
+// Factory creates fake backends for an engine and keeps them by zone.
+type Factory struct {
+	clock *Clock
+
+	mu       sync.Mutex
+	backends map[string]*Backend
+	raises   []string
+}
+
+// NewFactory returns a factory whose backends share clock.
+func NewFactory(clock *Clock) *Factory {
+	return &Factory{clock: clock, backends: make(map[string]*Backend)}
+}
+
+// New is a vlc.BackendFactory.
+func (f *Factory) New(zone template.Zone) (vlc.Backend, error) {
+	b := NewBackend(f.clock)
+	b.onRaise = func(zoneID string) {
+		f.mu.Lock()
+		f.raises = append(f.raises, zoneID)
+		f.mu.Unlock()
+	}
+	f.mu.Lock()
+	f.backends[zone.ID] = b
+	f.mu.Unlock()
+	return b, nil
+}
+
+// Raises returns the zone IDs passed to Raise across all backends, in
+// call order.
+func (f *Factory) Raises() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.raises...)
+}
+
+// Backend returns the backend created for zoneID, or nil.
+func (f *Factory) Backend(zoneID string) *Backend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.backends[zoneID]
+}
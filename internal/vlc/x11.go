@@ -0,0 +1,74 @@
+package vlc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"player-native/internal/template"
+)
+
+// Zones on subprocess backends are separate X11 windows. Stacking and
+// opacity are applied to them with xdotool and xprop; opacity needs a
+// compositing window manager to have a visible effect.
+
+// x11WindowTimeout bounds the wait for a new player's window to map.
+const x11WindowTimeout = 10 * time.Second
+
+// x11Command runs an X11 helper against the display the players use.
+func x11Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if os.Getenv("DISPLAY") == "" {
+		cmd.Env = append(os.Environ(), "DISPLAY=:0")
+	}
+	return cmd
+}
+
+// x11Windows returns the windows of process pid, waiting for it to map
+// one, and applies the zone's opacity to them if it has one.
+func x11Windows(pid int, zone template.Zone) ([]string, error) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return nil, fmt.Errorf("%w: window stacking needs X11", ErrUnsupported)
+	}
+	if _, err := exec.LookPath("xdotool"); err != nil {
+		return nil, fmt.Errorf("%w: xdotool not installed", ErrUnsupported)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), x11WindowTimeout)
+	defer cancel()
+	out, err := x11Command(ctx, "xdotool", "search", "--sync", "--onlyvisible", "--pid", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("find window of pid %d: %w", pid, err)
+	}
+
+	windows := strings.Fields(string(out))
+	if zone.Opacity != nil {
+		// _NET_WM_WINDOW_OPACITY is a 32-bit fraction of 0xffffffff.
+		v := uint32(zone.Alpha() * 0xffffffff)
+		for _, wid := range windows {
+			err := x11Command(ctx, "xprop", "-id", wid, "-f", "_NET_WM_WINDOW_OPACITY", "32c",
+				"-set", "_NET_WM_WINDOW_OPACITY", strconv.FormatUint(uint64(v), 10)).Run()
+			if err != nil {
+				return nil, fmt.Errorf("set opacity of window %s: %w", wid, err)
+			}
+		}
+	}
+	return windows, nil
+}
+
+// x11Raise raises windows above all others, in order.
+func x11Raise(windows []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), x11WindowTimeout)
+	defer cancel()
+	for _, wid := range windows {
+		if err := x11Command(ctx, "xdotool", "windowraise", wid).Run(); err != nil {
+			return fmt.Errorf("raise window %s: %w", wid, err)
+		}
+	}
+	return nil
+}